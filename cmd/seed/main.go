@@ -0,0 +1,39 @@
+package main
+
+import (
+	"auth-service/internal/api"
+	"context"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/xinyi-chong/common-lib/logger"
+	"go.uber.org/zap"
+	"os"
+)
+
+// Reconciles the roles in the seed file and bootstraps the first
+// administrator, for deployments that set seed.on_startup to false.
+func main() {
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "" {
+		appEnv = "local"
+	}
+
+	if appEnv == "local" {
+		err := godotenv.Load()
+		if err != nil {
+			fmt.Println("Error loading .env file")
+		}
+	}
+
+	if err := logger.Init(); err != nil {
+		fmt.Printf("FATAL: logger init failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if err := api.Seed(context.Background()); err != nil {
+		logger.Fatal("Failed to seed roles", zap.Error(err))
+	}
+
+	logger.Info("Seed complete")
+}
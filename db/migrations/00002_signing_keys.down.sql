@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS auth.signing_keys CASCADE;
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE auth.signing_keys
+(
+    id                    UUID PRIMARY KEY      DEFAULT uuid_generate_v7(),
+    kid                   VARCHAR(100) NOT NULL,
+    algorithm             VARCHAR(10)  NOT NULL CHECK (
+        algorithm IN ('HS256', 'RS256', 'ES256', 'EdDSA')
+        ),
+    private_key_encrypted TEXT         NOT NULL,
+    created_at            TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    retired_at            TIMESTAMPTZ, -- No longer used for signing
+    expires_at            TIMESTAMPTZ, -- No longer accepted for verification
+    UNIQUE (kid)
+);
+
+CREATE INDEX idx_signing_keys_expires ON auth.signing_keys (expires_at) WHERE expires_at IS NOT NULL;
+
+COMMIT;
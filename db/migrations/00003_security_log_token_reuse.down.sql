@@ -0,0 +1,14 @@
+BEGIN;
+
+DELETE FROM auth.security_logs WHERE action = 'token_reuse';
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change')
+        );
+
+COMMIT;
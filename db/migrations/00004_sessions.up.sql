@@ -0,0 +1,20 @@
+BEGIN;
+
+-- A session is a refresh token family: its id is the family id carried in tokens
+CREATE TABLE auth.sessions
+(
+    id           UUID PRIMARY KEY,
+    user_id      UUID        NOT NULL REFERENCES auth.users (id) ON DELETE CASCADE,
+    device_name  VARCHAR(255),
+    user_agent   TEXT,
+    ip_address   INET,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_sessions_user_active ON auth.sessions (user_id) WHERE revoked_at IS NULL;
+CREATE INDEX idx_sessions_expires ON auth.sessions (expires_at);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset')
+        );
+
+COMMIT;
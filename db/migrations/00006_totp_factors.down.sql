@@ -0,0 +1,17 @@
+BEGIN;
+
+DELETE FROM auth.security_logs WHERE action IN ('mfa_enabled', 'mfa_disabled');
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset')
+        );
+
+DROP TABLE IF EXISTS auth.totp_factors CASCADE;
+
+COMMIT;
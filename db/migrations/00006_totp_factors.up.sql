@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE auth.totp_factors
+(
+    id               UUID PRIMARY KEY     DEFAULT uuid_generate_v7(),
+    user_id          UUID        NOT NULL REFERENCES auth.users (id) ON DELETE CASCADE,
+    secret_encrypted TEXT        NOT NULL,
+    last_used_step   BIGINT, -- Newest accepted time step, to stop code replays
+    confirmed_at     TIMESTAMPTZ, -- Enrollment not finished while NULL
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id)
+);
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled')
+        );
+
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE auth.recovery_codes
+(
+    id         UUID PRIMARY KEY     DEFAULT uuid_generate_v7(),
+    user_id    UUID        NOT NULL REFERENCES auth.users (id) ON DELETE CASCADE,
+    code_hash  TEXT        NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_recovery_codes_user_unused ON auth.recovery_codes (user_id) WHERE used_at IS NULL;
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled',
+                   'recovery_codes_generated', 'recovery_code_used')
+        );
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+DELETE FROM auth.security_logs WHERE action IN ('passkey_added', 'passkey_removed');
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled',
+                   'recovery_codes_generated', 'recovery_code_used')
+        );
+
+DELETE FROM auth.auth_methods WHERE method_type = 'webauthn';
+
+DROP TABLE IF EXISTS auth.webauthn_credentials CASCADE;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Every credential is also an auth method, with the credential id as provider_id
+CREATE TABLE auth.webauthn_credentials
+(
+    id             UUID PRIMARY KEY      DEFAULT uuid_generate_v7(),
+    user_id        UUID         NOT NULL REFERENCES auth.users (id) ON DELETE CASCADE,
+    auth_method_id UUID         NOT NULL UNIQUE REFERENCES auth.auth_methods (id) ON DELETE CASCADE,
+    credential_id  BYTEA        NOT NULL UNIQUE,
+    name           VARCHAR(255),
+    credential     JSONB        NOT NULL,
+    last_used_at   TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON auth.webauthn_credentials (user_id);
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled',
+                   'recovery_codes_generated', 'recovery_code_used',
+                   'passkey_added', 'passkey_removed')
+        );
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+DELETE FROM auth.security_logs WHERE action IN ('auth_method_linked', 'auth_method_unlinked');
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled',
+                   'recovery_codes_generated', 'recovery_code_used',
+                   'passkey_added', 'passkey_removed')
+        );
+
+DROP INDEX IF EXISTS auth.idx_auth_methods_provider;
+DROP INDEX IF EXISTS auth.idx_auth_methods_user_password;
+
+DELETE FROM auth.auth_methods WHERE method_type = 'password';
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Passwords become auth methods like any other, so every way to sign in is listed
+INSERT INTO auth.auth_methods (user_id, method_type)
+SELECT id, 'password'
+FROM auth.users
+WHERE password_hash IS NOT NULL
+  AND NOT EXISTS (SELECT 1
+                  FROM auth.auth_methods m
+                  WHERE m.user_id = users.id
+                    AND m.method_type = 'password');
+
+CREATE UNIQUE INDEX idx_auth_methods_user_password ON auth.auth_methods (user_id) WHERE method_type = 'password';
+
+-- A provider account signs in to one user only
+CREATE UNIQUE INDEX idx_auth_methods_provider ON auth.auth_methods (method_type, provider_id) WHERE provider_id IS NOT NULL;
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled',
+                   'recovery_codes_generated', 'recovery_code_used',
+                   'passkey_added', 'passkey_removed',
+                   'auth_method_linked', 'auth_method_unlinked')
+        );
+
+COMMIT;
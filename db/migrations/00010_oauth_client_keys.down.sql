@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE auth.oauth_clients
+    DROP COLUMN IF EXISTS jwks;
+
+COMMIT;
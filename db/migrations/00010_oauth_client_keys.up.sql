@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Public keys of clients that authenticate with private_key_jwt (RFC 7523)
+ALTER TABLE auth.oauth_clients
+    ADD COLUMN jwks JSONB;
+
+COMMIT;
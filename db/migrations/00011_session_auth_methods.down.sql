@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE auth.sessions
+    DROP COLUMN IF EXISTS auth_methods;
+
+COMMIT;
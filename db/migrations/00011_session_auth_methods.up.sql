@@ -0,0 +1,7 @@
+BEGIN;
+
+-- How the session's user authenticated, as RFC 8176 amr values for ID tokens
+ALTER TABLE auth.sessions
+    ADD COLUMN auth_methods VARCHAR(20)[] NOT NULL DEFAULT '{}'::VARCHAR[];
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE auth.users
+    DROP COLUMN IF EXISTS password_reset_required;
+
+COMMIT;
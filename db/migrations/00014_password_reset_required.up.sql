@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Set by administrators; the user must reset their password before logging in
+ALTER TABLE auth.users
+    ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE auth.signing_keys
+    DROP COLUMN IF EXISTS activates_at;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- A rotated key is published for verification before it starts signing
+ALTER TABLE auth.signing_keys
+    ADD COLUMN activates_at TIMESTAMPTZ;
+
+UPDATE auth.signing_keys
+SET activates_at = created_at;
+
+ALTER TABLE auth.signing_keys
+    ALTER COLUMN activates_at SET NOT NULL,
+    ALTER COLUMN activates_at SET DEFAULT NOW();
+
+COMMIT;
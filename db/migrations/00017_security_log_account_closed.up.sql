@@ -0,0 +1,24 @@
+BEGIN;
+
+ALTER TABLE auth.security_logs
+    DROP CONSTRAINT security_logs_action_check;
+
+ALTER TABLE auth.security_logs
+    ADD CONSTRAINT security_logs_action_check CHECK (
+        action IN ('login', 'login_failed', 'logout', 'token_refresh',
+                   'password_change', 'email_change', 'token_reuse',
+                   'password_reset', 'mfa_enabled', 'mfa_disabled',
+                   'recovery_codes_generated', 'recovery_code_used',
+                   'passkey_added', 'passkey_removed',
+                   'auth_method_linked', 'auth_method_unlinked',
+                   'oauth_client_created', 'oauth_client_updated',
+                   'oauth_client_secret_rotated', 'oauth_client_deleted',
+                   'admin_bootstrapped',
+                   'role_created', 'role_updated', 'role_deleted',
+                   'permission_created', 'permission_updated',
+                   'permission_deleted', 'permission_granted',
+                   'permission_revoked', 'role_assigned', 'role_unassigned',
+                   'account_closed')
+        );
+
+COMMIT;
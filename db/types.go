@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql/driver"
+	"github.com/jackc/pgx/v5/pgtype"
+	"strings"
+)
+
+// StringArray maps a Postgres TEXT[] or VARCHAR[] column.
+type StringArray []string
+
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+
+	quoted := make([]string, len(a))
+	for i, s := range a {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+		quoted[i] = `"` + s + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+func (a *StringArray) Scan(value interface{}) error {
+	var s []string
+	if err := pgtype.NewMap().SQLScanner(&s).Scan(value); err != nil {
+		return err
+	}
+	*a = s
+	return nil
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"auth-service/db"
+	"auth-service/internal/config"
+	"auth-service/internal/role"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/user"
+	"context"
+	"github.com/xinyi-chong/common-lib/logger"
+)
+
+// Seed reconciles the seeded roles and bootstraps the first administrator
+// without starting the server.
+func Seed(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	gormDB, err := db.Init(cfg.Postgres.Config)
+	if err != nil {
+		return err
+	}
+
+	log := logger.Get()
+	userSvc := user.NewService(user.NewRepository(gormDB), log)
+	securityLogSvc := securitylog.NewService(securitylog.NewRepository(gormDB), log)
+	roleSvc := role.NewService(role.NewRepository(gormDB), userSvc, securityLogSvc, log)
+
+	return seedRoles(ctx, cfg, roleSvc)
+}
+
+func seedRoles(ctx context.Context, cfg *config.Config, roleSvc role.Service) error {
+	if cfg.Seed.RolesFile != "" {
+		seed, err := role.LoadSeed(cfg.Seed.RolesFile)
+		if err != nil {
+			return err
+		}
+
+		err = roleSvc.ReconcileSeed(ctx, seed)
+		if err != nil {
+			return err
+		}
+	}
+
+	param := role.BootstrapAdminParam{
+		Email:    cfg.BootstrapAdmin.Email,
+		Password: cfg.BootstrapAdmin.Password,
+		Role:     cfg.BootstrapAdmin.Role,
+	}
+	if cfg.BootstrapAdmin.Username != "" {
+		param.Username = &cfg.BootstrapAdmin.Username
+	}
+	return roleSvc.BootstrapAdmin(ctx, param)
+}
@@ -3,9 +3,26 @@ package api
 import (
 	"auth-service/db"
 	"auth-service/internal/auth"
+	"auth-service/internal/authmethod"
 	"auth-service/internal/config"
+	"auth-service/internal/mfa"
+	authmiddleware "auth-service/internal/middleware"
+	"auth-service/internal/oauth"
+	"auth-service/internal/passkey"
+	"auth-service/internal/reauth"
+	"auth-service/internal/role"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/session"
+	authconsts "auth-service/internal/shared/consts"
+	"auth-service/internal/signingkey"
+	"auth-service/internal/social"
 	"auth-service/internal/user"
+	"auth-service/internal/verification"
+	"auth-service/internal/wellknown"
 	token "auth-service/pkg/jwt"
+	"auth-service/pkg/mailer"
+	"auth-service/pkg/ratelimit"
+	"auth-service/pkg/secretbox"
 	locale "github.com/xinyi-chong/common-lib/i18n"
 	"github.com/xinyi-chong/common-lib/logger"
 	"github.com/xinyi-chong/common-lib/middleware"
@@ -16,8 +33,10 @@ import (
 	"strings"
 
 	"context"
+	"errors"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -32,7 +51,24 @@ type Server struct {
 	config *config.Config
 	logger *zap.Logger
 
-	authCtrl *auth.Controller
+	authCtrl         *auth.Controller
+	sessionCtrl      *session.Controller
+	wellKnownCtrl    *wellknown.Controller
+	signingKeyCtrl   *signingkey.Controller
+	verificationCtrl *verification.Controller
+	mfaCtrl          *mfa.Controller
+	passkeyCtrl      *passkey.Controller
+	oauthCtrl        *oauth.Controller
+	roleCtrl         *role.Controller
+
+	// requireAuth guards every route that needs an authenticated user
+	requireAuth gin.HandlerFunc
+	// permissions guards administration routes, after requireAuth
+	permissions *authmiddleware.PermissionChecker
+	// requireRecentAuth guards changes to sign-in methods, after requireAuth
+	requireRecentAuth gin.HandlerFunc
+
+	limiter ratelimit.Limiter
 }
 
 func NewServer() (*Server, error) {
@@ -69,19 +105,92 @@ func NewServer() (*Server, error) {
 		return nil, err
 	}
 
+	box, err := secretbox.New(cfg.Encryption.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKeyRepo := signingkey.NewRepository(gormDB, box)
+	err = token.UseKeyStore(ctx, signingKeyRepo)
+	if err != nil {
+		return nil, err
+	}
+	token.StartKeyRotation(context.Background(), cfg.JWT.RotationInterval, cfg.JWT.KeySyncInterval)
+
 	log := logger.Get()
 	userRepo := user.NewRepository(gormDB)
 	userSvc := user.NewService(userRepo, log)
-	authSvc := auth.NewService(userSvc, log)
+	securityLogRepo := securitylog.NewRepository(gormDB)
+	securityLogSvc := securitylog.NewService(securityLogRepo, log)
+	sessionRepo := session.NewRepository(gormDB)
+	sessionSvc := session.NewService(sessionRepo, log)
+	sessionCtrl := session.NewController(sessionSvc, log)
+	mail, err := newMailer(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	verificationSvc := verification.NewService(cfg, userSvc, mail, log)
+	verificationCtrl := verification.NewController(verificationSvc, log)
+	mfaRepo := mfa.NewRepository(gormDB, box)
+	mfaSvc := mfa.NewService(cfg, mfaRepo, userSvc, securityLogSvc, log)
+	mfaCtrl := mfa.NewController(mfaSvc, log)
+	passkeyRepo := passkey.NewRepository(gormDB)
+	passkeySvc, err := passkey.NewService(cfg, passkeyRepo, userSvc, securityLogSvc, log)
+	if err != nil {
+		return nil, err
+	}
+	passkeyCtrl := passkey.NewController(passkeySvc, log)
+	socialSvc, err := social.NewService(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	authMethodRepo := authmethod.NewRepository(gormDB)
+	authMethodSvc := authmethod.NewService(authMethodRepo, log)
+	reauthStore := reauth.NewStore(cfg)
+	roleRepo := role.NewRepository(gormDB)
+	roleSvc := role.NewService(roleRepo, userSvc, securityLogSvc, log)
+	if cfg.Seed.OnStartup {
+		err = seedRoles(ctx, cfg, roleSvc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	roleCtrl := role.NewController(roleSvc, log)
+	authSvc := auth.NewService(cfg, userSvc, sessionSvc, securityLogSvc, verificationSvc, mfaSvc, passkeySvc, socialSvc, authMethodSvc, roleSvc, reauthStore, mail, log)
 	authCtrl := auth.NewController(authSvc, log)
+	oauthRepo := oauth.NewRepository(gormDB)
+	oauthSvc := oauth.NewService(cfg, oauthRepo, userSvc, sessionSvc, securityLogSvc, log)
+	oauthCtrl := oauth.NewController(oauthSvc, log)
+	wellKnownCtrl := wellknown.NewController(log)
+	signingKeyCtrl := signingkey.NewController(log)
+
+	router := gin.New()
+	err = router.SetTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
 
 	s := &Server{
-		router:   gin.New(),
-		db:       gormDB,
-		redis:    redisClient,
-		config:   cfg,
-		logger:   log,
-		authCtrl: authCtrl,
+		router:           router,
+		db:               gormDB,
+		redis:            redisClient,
+		config:           cfg,
+		logger:           log,
+		authCtrl:         authCtrl,
+		sessionCtrl:      sessionCtrl,
+		wellKnownCtrl:    wellKnownCtrl,
+		signingKeyCtrl:   signingKeyCtrl,
+		verificationCtrl: verificationCtrl,
+		mfaCtrl:          mfaCtrl,
+		passkeyCtrl:      passkeyCtrl,
+		oauthCtrl:        oauthCtrl,
+		roleCtrl:         roleCtrl,
+
+		requireAuth:       authmiddleware.AuthMiddleware(log),
+		permissions:       newPermissionChecker(roleSvc, log),
+		requireRecentAuth: authmiddleware.RequireRecentAuth(reauthStore, log),
+
+		limiter: newRateLimiter(cfg, redisClient),
 	}
 
 	s.setupMiddleware()
@@ -110,6 +219,7 @@ func (s *Server) setupMiddleware() {
 		middleware.CORSMiddleware(),
 		middleware.LocaleMiddleware(),
 		middleware.ContextMiddleware(),
+		authmiddleware.ClientInfoMiddleware(),
 	)
 }
 
@@ -120,22 +230,199 @@ func (s *Server) setupRoutes() {
 
 	s.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	s.router.GET("/.well-known/jwks.json", s.wellKnownCtrl.JWKS)
+	s.router.GET("/.well-known/openid-configuration", s.oauthCtrl.Discovery)
+
 	api := s.router.Group("/api")
 	{
 		v1 := api.Group("/v1")
 		{
 			s.registerAuthRoutes(v1)
+			s.registerOAuthRoutes(v1)
+			s.registerProfileRoutes(v1)
+			s.registerUserRoutes(v1)
+			s.registerAdminRoutes(v1)
+		}
+	}
+}
+
+func newRateLimiter(cfg *config.Config, redisClient *redis.Client) ratelimit.Limiter {
+	if cfg.RateLimit.Backend == "memory" {
+		return ratelimit.NewMemoryLimiter()
+	}
+	return ratelimit.NewRedisLimiter(redisClient)
+}
+
+func newMailer(cfg *config.Config, log *zap.Logger) (mailer.Mailer, error) {
+	switch cfg.Mailer.Backend {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.Mailer.SMTP.SMTPConfig, cfg.Mailer.From), nil
+	case "outbox":
+		// Messages, with their codes and reset links, are kept in plain text
+		// on disk, so it must be chosen on purpose too
+		if !cfg.Mailer.AllowOutbox {
+			return nil, errors.New("mailer.backend outbox stores email unencrypted on disk; set mailer.allow_outbox to use it")
+		}
+		from := cfg.Mailer.From
+		if from == "" {
+			// Nothing is delivered, so any sender will do
+			from = "no-reply@localhost"
 		}
+		return mailer.NewOutboxMailer(cfg.Mailer.OutboxDir, from)
+	default:
+		// Nobody would receive their codes, so it must be chosen on purpose
+		if !cfg.Mailer.AllowLog {
+			return nil, errors.New("mailer.backend log delivers no email; set mailer.allow_log to use it")
+		}
+		return mailer.NewLogMailer(log), nil
+	}
+}
+
+// newPermissionChecker checks permissions embedded in access tokens, looking
+// them up for tokens that do not carry them.
+func newPermissionChecker(roleSvc role.Service, log *zap.Logger) *authmiddleware.PermissionChecker {
+	lookup := func(ctx context.Context, userID uuid.UUID) ([]string, error) {
+		authz, err := roleSvc.UserAuthorization(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return authz.Permissions, nil
+	}
+	return authmiddleware.NewPermissionChecker(lookup, log)
+}
+
+// requirePermission returns the middleware that allows only users holding the
+// permission. It must run after requireAuth.
+func (s *Server) requirePermission(permission string) gin.HandlerFunc {
+	return s.permissions.Require(permission)
+}
+
+// rateLimit returns the middleware for the named policy in config.yaml. Routes
+// whose policy is missing, or when rate limiting is disabled, are not limited.
+func (s *Server) rateLimit(policyName string) gin.HandlerFunc {
+	policy, ok := s.config.RateLimit.Policies[policyName]
+	if !s.config.RateLimit.Enabled || !ok {
+		return authmiddleware.NoopMiddleware()
 	}
+	return authmiddleware.RateLimitMiddleware(s.limiter, policyName, policy, s.logger)
 }
 
 func (s *Server) registerAuthRoutes(rg *gin.RouterGroup) {
 	g := rg.Group("/auth")
 	{
-		g.POST("/register", s.authCtrl.Register)
-		g.POST("/login", s.authCtrl.Login)
-		g.POST("/refresh", s.authCtrl.RefreshToken)
-		g.PATCH("/change-password", s.authCtrl.ChangePassword)
-		g.POST("/logout", s.authCtrl.Logout)
+		g.POST("/register", s.rateLimit("register"), s.authCtrl.Register)
+		g.POST("/login", s.rateLimit("login"), s.authCtrl.Login)
+		g.POST("/login/recovery", s.rateLimit("login"), s.authCtrl.LoginWithRecoveryCode)
+		g.POST("/refresh", s.rateLimit("refresh"), s.authCtrl.RefreshToken)
+		g.POST("/mfa/verify", s.rateLimit("mfa_verify"), s.authCtrl.VerifyMFA)
+		g.POST("/mfa/webauthn/begin", s.rateLimit("mfa_verify"), s.authCtrl.BeginMFAPasskey)
+		g.POST("/mfa/webauthn/finish", s.rateLimit("mfa_verify"), s.authCtrl.VerifyMFAPasskey)
+		g.POST("/webauthn/login/begin", s.rateLimit("webauthn_login"), s.authCtrl.BeginPasskeyLogin)
+		g.POST("/webauthn/login/finish", s.rateLimit("webauthn_login"), s.authCtrl.LoginWithPasskey)
+		g.GET("/social/:provider", s.rateLimit("social_login"), s.authCtrl.SocialLogin)
+		g.GET("/social/:provider/callback", s.rateLimit("social_login"), s.authCtrl.SocialCallback)
+		g.POST("/social/:provider/callback", s.rateLimit("social_login"), s.authCtrl.SocialCallback)
+		g.POST("/password/forgot", s.rateLimit("password_forgot"), s.authCtrl.ForgotPassword)
+		g.POST("/password/reset", s.rateLimit("password_reset"), s.authCtrl.ResetPassword)
+		g.POST("/verify-email", s.rateLimit("verify_email"), s.verificationCtrl.VerifyEmail)
+		g.POST("/verify-email/resend", s.rateLimit("verify_email_resend"), s.verificationCtrl.ResendEmailVerification)
+	}
+
+	protected := g.Group("", s.requireAuth)
+	{
+		protected.PATCH("/change-password", s.rateLimit("change_password"), s.authCtrl.ChangePassword)
+		protected.POST("/logout", s.authCtrl.Logout)
+		protected.POST("/logout-all", s.authCtrl.LogoutAll)
+		protected.GET("/permissions", s.roleCtrl.CurrentAuthorization)
+
+		protected.GET("/sessions", s.sessionCtrl.ListSessions)
+		protected.DELETE("/sessions", s.sessionCtrl.RevokeOtherSessions)
+		protected.DELETE("/sessions/:id", s.sessionCtrl.RevokeSession)
+
+		protected.POST("/mfa/totp/enroll", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.mfaCtrl.EnrollTOTP)
+		protected.POST("/mfa/totp/confirm", s.rateLimit("mfa_manage"), s.mfaCtrl.ConfirmTOTP)
+		protected.DELETE("/mfa/totp", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.authCtrl.DisableTOTP)
+		protected.POST("/mfa/recovery-codes", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.mfaCtrl.GenerateRecoveryCodes)
+
+		protected.POST("/webauthn/register/begin", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.passkeyCtrl.BeginRegistration)
+		protected.POST("/webauthn/register/finish", s.rateLimit("mfa_manage"), s.passkeyCtrl.FinishRegistration)
+		protected.GET("/webauthn/credentials", s.passkeyCtrl.ListCredentials)
+		protected.DELETE("/webauthn/credentials/:id", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.passkeyCtrl.DeleteCredential)
+
+		protected.POST("/reauthenticate", s.rateLimit("reauthenticate"), s.authCtrl.Reauthenticate)
+		protected.GET("/methods", s.authCtrl.ListAuthMethods)
+		protected.POST("/methods/password", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.authCtrl.LinkPassword)
+		protected.POST("/methods/social/:provider", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.authCtrl.LinkProvider)
+		protected.POST("/methods/link", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.authCtrl.LinkIdentity)
+		protected.DELETE("/methods/:id", s.rateLimit("mfa_manage"), s.requireRecentAuth, s.authCtrl.UnlinkAuthMethod)
+	}
+}
+
+func (s *Server) registerOAuthRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/oauth")
+	{
+		g.GET("/authorize", s.rateLimit("oauth_authorize"), s.oauthCtrl.Authorize)
+		g.POST("/token", s.rateLimit("oauth_token"), s.oauthCtrl.Token)
+		g.GET("/userinfo", s.oauthCtrl.UserInfo)
+		g.POST("/userinfo", s.oauthCtrl.UserInfo)
+		g.GET("/consent", s.requireAuth, s.oauthCtrl.ConsentDetails)
+		g.POST("/consent", s.requireAuth, s.oauthCtrl.Consent)
+	}
+}
+
+func (s *Server) registerProfileRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/me", s.requireAuth)
+	{
+		g.GET("", s.authCtrl.GetProfile)
+		g.PATCH("", s.rateLimit("update_profile"), s.authCtrl.UpdateProfile)
+		g.DELETE("", s.rateLimit("close_account"), s.authCtrl.CloseAccount)
+	}
+}
+
+func (s *Server) registerUserRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/users", s.requireAuth)
+	{
+		g.GET("", s.requirePermission(authconsts.PermUsersRead), s.authCtrl.ListUsers)
+		g.GET("/:id", s.requirePermission(authconsts.PermUsersRead), s.authCtrl.GetUser)
+		g.PATCH("/:id", s.requirePermission(authconsts.PermUsersWrite), s.authCtrl.UpdateUser)
+		g.DELETE("/:id", s.requirePermission(authconsts.PermUsersDelete), s.authCtrl.DeleteUser)
+		g.POST("/:id/deactivate", s.requirePermission(authconsts.PermUsersWrite), s.authCtrl.DeactivateUser)
+		g.POST("/:id/reactivate", s.requirePermission(authconsts.PermUsersWrite), s.authCtrl.ReactivateUser)
+		g.POST("/:id/lock", s.requirePermission(authconsts.PermUsersLock), s.authCtrl.LockUser)
+		g.POST("/:id/unlock", s.requirePermission(authconsts.PermUsersLock), s.authCtrl.UnlockUser)
+		g.POST("/:id/password-reset", s.requirePermission(authconsts.PermUsersWrite), s.authCtrl.ForcePasswordReset)
+		g.GET("/:id/roles", s.requirePermission(authconsts.PermRolesRead), s.roleCtrl.ListUserRoles)
+		g.PUT("/:id/roles/:role_id", s.requirePermission(authconsts.PermRolesAssign), s.roleCtrl.AssignRole)
+		g.DELETE("/:id/roles/:role_id", s.requirePermission(authconsts.PermRolesAssign), s.roleCtrl.UnassignRole)
+	}
+}
+
+func (s *Server) registerAdminRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/admin", s.requireAuth)
+	{
+		g.GET("/keys", s.requirePermission(authconsts.PermKeysRead), s.signingKeyCtrl.ListKeys)
+		g.POST("/keys/rotate", s.requirePermission(authconsts.PermKeysRotate), s.signingKeyCtrl.RotateKey)
+
+		g.GET("/roles", s.requirePermission(authconsts.PermRolesRead), s.roleCtrl.ListRoles)
+		g.POST("/roles", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.CreateRole)
+		g.GET("/roles/:id", s.requirePermission(authconsts.PermRolesRead), s.roleCtrl.GetRole)
+		g.PATCH("/roles/:id", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.UpdateRole)
+		g.DELETE("/roles/:id", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.DeleteRole)
+		g.PUT("/roles/:id/permissions/:permission_id", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.GrantPermission)
+		g.DELETE("/roles/:id/permissions/:permission_id", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.RevokePermission)
+
+		g.GET("/permissions", s.requirePermission(authconsts.PermRolesRead), s.roleCtrl.ListPermissions)
+		g.POST("/permissions", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.CreatePermission)
+		g.PATCH("/permissions/:id", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.UpdatePermission)
+		g.DELETE("/permissions/:id", s.requirePermission(authconsts.PermRolesWrite), s.roleCtrl.DeletePermission)
+
+		g.GET("/oauth/clients", s.requirePermission(authconsts.PermOAuthClientsRead), s.oauthCtrl.ListClients)
+		g.POST("/oauth/clients", s.requirePermission(authconsts.PermOAuthClientsWrite), s.oauthCtrl.CreateClient)
+		g.GET("/oauth/clients/:id", s.requirePermission(authconsts.PermOAuthClientsRead), s.oauthCtrl.GetClient)
+		g.PATCH("/oauth/clients/:id", s.requirePermission(authconsts.PermOAuthClientsWrite), s.oauthCtrl.UpdateClient)
+		g.DELETE("/oauth/clients/:id", s.requirePermission(authconsts.PermOAuthClientsWrite), s.oauthCtrl.DeleteClient)
+		g.POST("/oauth/clients/:id/disable", s.requirePermission(authconsts.PermOAuthClientsWrite), s.oauthCtrl.DisableClient)
+		g.POST("/oauth/clients/:id/enable", s.requirePermission(authconsts.PermOAuthClientsWrite), s.oauthCtrl.EnableClient)
+		g.POST("/oauth/clients/:id/secret", s.requirePermission(authconsts.PermOAuthClientsWrite), s.oauthCtrl.RotateClientSecret)
 	}
 }
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/pkg/jwt"
+	"context"
+	"github.com/google/uuid"
+)
+
+const defaultMaxEmbeddedPermissions = 100
+
+// tokenAuthzPolicy decides what authorization goes into access tokens. Users
+// with more than maxPermissions permissions only get their roles embedded, so
+// their tokens stay small enough for headers.
+type tokenAuthzPolicy struct {
+	enabled        bool
+	maxPermissions int
+}
+
+func newTokenAuthzPolicy(cfg *config.Config) *tokenAuthzPolicy {
+	p := &tokenAuthzPolicy{
+		enabled:        cfg.JWT.EmbedAuthorization,
+		maxPermissions: cfg.JWT.MaxEmbeddedPermissions,
+	}
+
+	if p.maxPermissions <= 0 {
+		p.maxPermissions = defaultMaxEmbeddedPermissions
+	}
+	return p
+}
+
+// tokenAuthorization returns the roles and permissions to embed in the user's
+// access token, or nil when embedding is disabled.
+func (s *service) tokenAuthorization(ctx context.Context, userID uuid.UUID) (*token.Authorization, error) {
+	if !s.tokenAuthz.enabled {
+		return nil, nil
+	}
+
+	authz, err := s.roleSvc.UserAuthorization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(authz.Permissions) > s.tokenAuthz.maxPermissions {
+		return &token.Authorization{Roles: authz.Roles, PermissionsOmitted: true}, nil
+	}
+	return &token.Authorization{Roles: authz.Roles, Permissions: authz.Permissions}, nil
+}
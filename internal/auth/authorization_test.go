@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/role"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+)
+
+// fakeRoleService hands out fixed authorizations; other methods are not used.
+type fakeRoleService struct {
+	role.Service
+	authz map[uuid.UUID]*role.Authorization
+}
+
+func (s *fakeRoleService) UserAuthorization(_ context.Context, userID uuid.UUID) (*role.Authorization, error) {
+	if authz, ok := s.authz[userID]; ok {
+		return authz, nil
+	}
+	return &role.Authorization{Roles: []string{}, Permissions: []string{}}, nil
+}
+
+func newAuthorizationService(cfg *config.Config, authz map[uuid.UUID]*role.Authorization) *service {
+	return &service{
+		roleSvc:    &fakeRoleService{authz: authz},
+		tokenAuthz: newTokenAuthzPolicy(cfg),
+	}
+}
+
+func TestTokenAuthorization_Disabled(t *testing.T) {
+	userID := uuid.New()
+	s := newAuthorizationService(&config.Config{}, map[uuid.UUID]*role.Authorization{
+		userID: {Roles: []string{"admin"}, Permissions: []string{"*"}},
+	})
+
+	authz, err := s.tokenAuthorization(context.Background(), userID)
+	if err != nil || authz != nil {
+		t.Errorf("tokenAuthorization = %+v, %v, want nothing embedded", authz, err)
+	}
+}
+
+func TestTokenAuthorization_Embedded(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.EmbedAuthorization = true
+	userID := uuid.New()
+	s := newAuthorizationService(cfg, map[uuid.UUID]*role.Authorization{
+		userID: {Roles: []string{"support"}, Permissions: []string{"users:read", "users:lock"}},
+	})
+
+	authz, err := s.tokenAuthorization(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("tokenAuthorization: %v", err)
+	}
+	if !reflect.DeepEqual(authz.Roles, []string{"support"}) ||
+		!reflect.DeepEqual(authz.Permissions, []string{"users:read", "users:lock"}) || authz.PermissionsOmitted {
+		t.Errorf("authz = %+v", authz)
+	}
+}
+
+func TestTokenAuthorization_TooManyPermissions(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.EmbedAuthorization = true
+	cfg.JWT.MaxEmbeddedPermissions = 3
+
+	permissions := make([]string, 4)
+	for i := range permissions {
+		permissions[i] = fmt.Sprintf("resource%d:read", i)
+	}
+	userID := uuid.New()
+	s := newAuthorizationService(cfg, map[uuid.UUID]*role.Authorization{
+		userID: {Roles: []string{"auditor"}, Permissions: permissions},
+	})
+
+	authz, err := s.tokenAuthorization(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("tokenAuthorization: %v", err)
+	}
+	// Roles still fit; permissions are looked up instead
+	if !authz.PermissionsOmitted || authz.Permissions != nil || len(authz.Roles) != 1 {
+		t.Errorf("authz = %+v, want permissions omitted", authz)
+	}
+
+	s.tokenAuthz.maxPermissions = len(permissions)
+	if authz, _ := s.tokenAuthorization(context.Background(), userID); authz.PermissionsOmitted {
+		t.Error("permissions omitted at the limit")
+	}
+}
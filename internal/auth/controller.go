@@ -1,14 +1,20 @@
 package auth
 
 import (
+	"auth-service/internal/mfa"
+	authmiddleware "auth-service/internal/middleware"
 	authconsts "auth-service/internal/shared/consts"
+	authsuccess "auth-service/internal/shared/success"
+	"auth-service/internal/social"
 	token "auth-service/pkg/jwt"
 	"github.com/google/uuid"
 	"github.com/xinyi-chong/common-lib/consts"
 	apperrors "github.com/xinyi-chong/common-lib/errors"
 	"github.com/xinyi-chong/common-lib/response"
 	"github.com/xinyi-chong/common-lib/success"
+	"github.com/xinyi-chong/common-lib/validation"
 	"go.uber.org/zap"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -54,7 +60,7 @@ func (ctrl *Controller) Register(c *gin.Context) {
 
 // Login godoc
 // @Summary Login
-// @Description Login
+// @Description Login. Users with MFA get an MFA token to complete the login at /auth/mfa/verify or /auth/mfa/webauthn.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -74,13 +80,317 @@ func (ctrl *Controller) Login(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	resp, err := ctrl.service.Login(ctx, param.Email, param.Password)
+	resp, err := ctrl.service.Login(ctx, param)
 	if err != nil {
 		ctrl.logger.Error("Login error", zap.String("email", param.Email), zap.Error(err))
 		response.Error(c, err, apperrors.ErrLoginFailed)
 		return
 	}
 
+	if resp.MFARequired {
+		response.Success(c, authsuccess.MFARequired, resp)
+		return
+	}
+
+	setRefreshTokenCookie(c, resp.RefreshToken)
+
+	response.Success(c, success.LoggedIn, resp)
+}
+
+// LoginWithRecoveryCode godoc
+// @Summary Login with a recovery code
+// @Description Login with the password and a single-use recovery code instead of the second factor
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body RecoveryLoginParam true "Credentials and recovery code"
+// @Success 200 {object} response.Response{data=LoginResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/login/recovery [post]
+func (ctrl *Controller) LoginWithRecoveryCode(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[RecoveryLoginParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.LoginWithRecoveryCode(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("LoginWithRecoveryCode error", zap.String("email", param.Email), zap.Error(err))
+		response.Error(c, err, apperrors.ErrLoginFailed)
+		return
+	}
+
+	setRefreshTokenCookie(c, resp.RefreshToken)
+
+	response.Success(c, success.LoggedIn, resp)
+}
+
+// VerifyMFA godoc
+// @Summary Verify MFA
+// @Description Complete a login with the MFA token from /auth/login and an authenticator code
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body VerifyMFAParam true "MFA token and code"
+// @Success 200 {object} response.Response{data=LoginResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/verify [post]
+func (ctrl *Controller) VerifyMFA(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[VerifyMFAParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.VerifyMFA(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("VerifyMFA error", zap.Error(err))
+		response.Error(c, err, apperrors.ErrLoginFailed)
+		return
+	}
+
+	setRefreshTokenCookie(c, resp.RefreshToken)
+
+	response.Success(c, success.LoggedIn, resp)
+}
+
+// BeginMFAPasskey godoc
+// @Summary Start passkey MFA
+// @Description Get assertion options for the second login step with a passkey or security key
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body MFATokenParam true "MFA token"
+// @Success 201 {object} response.Response{data=passkey.BeginResponse} "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 404 {object} response.Response "No passkey registered"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/webauthn/begin [post]
+func (ctrl *Controller) BeginMFAPasskey(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[MFATokenParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.BeginMFAPasskey(ctx, param.MFAToken)
+	if err != nil {
+		ctrl.logger.Error("BeginMFAPasskey error", zap.Error(err))
+		response.Error(c, err, apperrors.ErrLoginFailed)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.ChallengeField), resp)
+}
+
+// VerifyMFAPasskey godoc
+// @Summary Verify passkey MFA
+// @Description Complete a login with the MFA token from /auth/login and a passkey assertion
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body VerifyMFAPasskeyParam true "MFA token and assertion"
+// @Success 200 {object} response.Response{data=LoginResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/webauthn/finish [post]
+func (ctrl *Controller) VerifyMFAPasskey(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[VerifyMFAPasskeyParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.VerifyMFAPasskey(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("VerifyMFAPasskey error", zap.Error(err))
+		response.Error(c, err, apperrors.ErrLoginFailed)
+		return
+	}
+
+	setRefreshTokenCookie(c, resp.RefreshToken)
+
+	response.Success(c, success.LoggedIn, resp)
+}
+
+// BeginPasskeyLogin godoc
+// @Summary Start passkey login
+// @Description Get assertion options for a usernameless login with a discoverable passkey
+// @Tags Authentication
+// @Produce json
+// @Success 201 {object} response.Response{data=passkey.BeginResponse} "Created"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/webauthn/login/begin [post]
+func (ctrl *Controller) BeginPasskeyLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.BeginPasskeyLogin(ctx)
+	if err != nil {
+		ctrl.logger.Error("BeginPasskeyLogin error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.ChallengeField), resp)
+}
+
+// LoginWithPasskey godoc
+// @Summary Login with a passkey
+// @Description Complete a usernameless login with the assertion from navigator.credentials.get
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body PasskeyLoginParam true "Assertion"
+// @Success 200 {object} response.Response{data=LoginResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/webauthn/login/finish [post]
+func (ctrl *Controller) LoginWithPasskey(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[PasskeyLoginParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.LoginWithPasskey(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("LoginWithPasskey error", zap.Error(err))
+		response.Error(c, err, apperrors.ErrLoginFailed)
+		return
+	}
+
+	setRefreshTokenCookie(c, resp.RefreshToken)
+
+	response.Success(c, success.LoggedIn, resp)
+}
+
+// SocialLogin godoc
+// @Summary Login with a provider
+// @Description Redirect to Google, GitHub or Apple to sign in. The provider redirects back to the callback.
+// @Tags Authentication
+// @Param provider path string true "Provider" Enums(google, github, apple)
+// @Param device_name query string false "Device name"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} response.Response "Provider not configured"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/social/{provider} [get]
+func (ctrl *Controller) SocialLogin(c *gin.Context) {
+	var param social.AuthorizeParam
+	if err := c.ShouldBindQuery(&param); err != nil {
+		ctrl.logger.Debug("Invalid request query", zap.Error(err))
+		response.Error(c, apperrors.ErrBadRequest.Wrap(err))
+		return
+	}
+	if err := validation.ValidateStruct(&param); err != nil {
+		ctrl.logger.Debug("Invalid request query", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	provider := c.Param("provider")
+	ctx := c.Request.Context()
+	authz, err := ctrl.service.SocialAuthorizationURL(ctx, provider, param)
+	if err != nil {
+		ctrl.logger.Error("SocialLogin error", zap.String("provider", provider), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	setSocialStateCookie(c, provider, authz.Binding)
+	c.Redirect(http.StatusFound, authz.URL)
+}
+
+// SocialCallback godoc
+// @Summary Provider callback
+// @Description Complete a login after the provider redirects back. Users with MFA get an MFA token as with /auth/login.
+// @Description When the provider's email belongs to an existing account, a link token is returned for /auth/methods/link instead.
+// @Description Links the provider when the user started at /auth/methods/social/{provider}.
+// @Tags Authentication
+// @Produce json
+// @Param provider path string true "Provider" Enums(google, github, apple)
+// @Param state query string true "State"
+// @Param code query string false "Authorization code"
+// @Param error query string false "Error from the provider"
+// @Success 200 {object} response.Response{data=LoginResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 404 {object} response.Response "Provider not configured"
+// @Failure 409 {object} response.Response "Provider account linked to another user"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/social/{provider}/callback [get]
+// @Router /auth/social/{provider}/callback [post]
+func (ctrl *Controller) SocialCallback(c *gin.Context) {
+	// Query string, or a posted form from Apple
+	var param social.CallbackParam
+	if err := c.ShouldBind(&param); err != nil {
+		ctrl.logger.Debug("Invalid callback", zap.Error(err))
+		response.Error(c, apperrors.ErrBadRequest.Wrap(err))
+		return
+	}
+	if err := validation.ValidateStruct(&param); err != nil {
+		ctrl.logger.Debug("Invalid callback", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	// Whatever the outcome, the state has been used
+	param.Binding, _ = c.Cookie(authconsts.CookieSocialState)
+	clearSocialStateCookie(c)
+
+	provider := c.Param("provider")
+	ctx := c.Request.Context()
+	result, err := ctrl.service.ProviderCallback(ctx, provider, param)
+	if err != nil {
+		ctrl.logger.Error("SocialCallback error", zap.String("provider", provider), zap.Error(err))
+		response.Error(c, err, apperrors.ErrLoginFailed)
+		return
+	}
+
+	if result.Linked != nil {
+		response.Success(c, success.XCreated.WithField(authconsts.AuthMethodField), result.Linked)
+		return
+	}
+
+	resp := result.Login
+	if resp.LinkRequired {
+		response.Success(c, authsuccess.LinkRequired, resp)
+		return
+	}
+
+	if resp.MFARequired {
+		response.Success(c, authsuccess.MFARequired, resp)
+		return
+	}
+
 	setRefreshTokenCookie(c, resp.RefreshToken)
 
 	response.Success(c, success.LoggedIn, resp)
@@ -100,17 +410,10 @@ func (ctrl *Controller) Login(c *gin.Context) {
 // @Failure 500 {object} response.Response "Internal Server Error"
 // @Router /auth/change-password [patch]
 func (ctrl *Controller) ChangePassword(c *gin.Context) {
-	userIDValue, exists := c.Get(consts.CtxUserID)
-	if !exists {
-		ctrl.logger.Debug("Missing user ID in context")
-		response.Error(c, apperrors.ErrUnauthorized)
-		return
-	}
-
-	userID, ok := userIDValue.(uuid.UUID)
-	if !ok {
-		ctrl.logger.Error("Invalid user ID type in context", zap.Any("user_id", userIDValue))
-		response.Error(c, apperrors.ErrInternalServerError)
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
 		return
 	}
 
@@ -122,7 +425,7 @@ func (ctrl *Controller) ChangePassword(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	err := ctrl.service.ChangePassword(ctx, userID, req.OldPassword, req.NewPassword)
+	err = ctrl.service.ChangePassword(ctx, userID, req.OldPassword, req.NewPassword)
 	if err != nil {
 		ctrl.logger.Error("ChangePassword error", zap.Error(err))
 		response.Error(c, err)
@@ -132,6 +435,70 @@ func (ctrl *Controller) ChangePassword(c *gin.Context) {
 	response.Success(c, success.XChanged.WithField(consts.PasswordField), nil)
 }
 
+// ForgotPassword godoc
+// @Summary Forgot Password
+// @Description Email a password reset link. The response is the same whether or not the email is registered.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body ForgotPasswordParam true "Email"
+// @Success 201 {object} response.Response "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/password/forgot [post]
+func (ctrl *Controller) ForgotPassword(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[ForgotPasswordParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.ForgotPassword(ctx, param.Email)
+	if err != nil {
+		ctrl.logger.Error("ForgotPassword error", zap.String("email", param.Email), zap.Error(err))
+		response.Error(c, err, apperrors.ErrRequestFailed)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.TokenField), nil)
+}
+
+// ResetPassword godoc
+// @Summary Reset Password
+// @Description Set a new password with a reset token and end every session
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body ResetPasswordParam true "Reset token and new password"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/password/reset [post]
+func (ctrl *Controller) ResetPassword(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[ResetPasswordParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.ResetPassword(ctx, param.Token, param.NewPassword)
+	if err != nil {
+		ctrl.logger.Error("ResetPassword error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+
+	response.Success(c, success.XChanged.WithField(consts.PasswordField), nil)
+}
+
 // RefreshToken godoc
 // @Summary Refresh Token
 // @Description Refresh Token
@@ -180,31 +547,830 @@ func (ctrl *Controller) RefreshToken(c *gin.Context) {
 // @Failure 401 {object} response.Response "Unauthorized"
 // @Router /auth/logout [post]
 func (ctrl *Controller) Logout(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		ctrl.logger.Warn("missing or invalid Authorization header")
+	accessToken := c.GetString(consts.CtxAccessToken)
+	if accessToken == "" {
+		ctrl.logger.Debug("Missing access token in context")
 		response.Error(c, apperrors.ErrUnauthorized)
 		return
 	}
-	accessToken := strings.TrimPrefix(authHeader, "Bearer ")
 
 	clearRefreshTokenCookie(c)
 
 	ctx := c.Request.Context()
-	refreshToken, err := c.Cookie(authconsts.CookieRefreshToken)
-	if err != nil {
-		ctrl.logger.Warn("Get Refresh Token error", zap.Error(err))
+	userID, userErr := authmiddleware.UserIDFromContext(c)
+	sessionID, hasSession := authmiddleware.SessionIDFromContext(c)
+	if userErr == nil && hasSession {
+		err := ctrl.service.Logout(ctx, userID, sessionID)
+		if err != nil {
+			ctrl.logger.Warn("Revoke Session error", zap.Error(err))
+		}
 	} else {
-		err = token.InvalidateToken(ctx, refreshToken)
+		refreshToken, err := c.Cookie(authconsts.CookieRefreshToken)
 		if err != nil {
-			ctrl.logger.Warn("Invalidate Refresh Token error", zap.Error(err))
+			ctrl.logger.Warn("Get Refresh Token error", zap.Error(err))
+		} else {
+			err = token.RevokeRefreshToken(ctx, refreshToken)
+			if err != nil {
+				ctrl.logger.Warn("Revoke Refresh Token error", zap.Error(err))
+			}
 		}
 	}
 
-	err = token.InvalidateToken(ctx, accessToken)
+	err := token.InvalidateToken(ctx, accessToken)
 	if err != nil {
 		ctrl.logger.Warn("Invalidate Access Token error", zap.Error(err))
 	}
 
 	response.Success(c, success.LoggedOut, nil)
 }
+
+// LogoutAll godoc
+// @Summary Logout everywhere
+// @Description Revoke every access and refresh token issued to the current user
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/logout-all [post]
+func (ctrl *Controller) LogoutAll(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.LogoutAll(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("LogoutAll error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+
+	response.Success(c, success.LoggedOut, nil)
+}
+
+// GetProfile godoc
+// @Summary Current user's profile
+// @Description Get the profile of the current user
+// @Tags Profile
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=user.Response} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /me [get]
+func (ctrl *Controller) GetProfile(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := ctrl.service.GetUser(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("GetProfile error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(consts.UserField), user)
+}
+
+// UpdateProfile godoc
+// @Summary Update profile
+// @Description Change the current user's username
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body UpdateProfileParam true "Changes"
+// @Success 200 {object} response.Response{data=user.Response} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 409 {object} response.Response "Username taken"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /me [patch]
+func (ctrl *Controller) UpdateProfile(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[UpdateProfileParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := ctrl.service.UpdateProfile(ctx, userID, param)
+	if err != nil {
+		ctrl.logger.Error("UpdateProfile error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), user)
+}
+
+// CloseAccount godoc
+// @Summary Close account
+// @Description Delete the current user's account and end all of its sessions. Users with a password confirm it; users without one re-authenticate first.
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body CloseAccountParam false "Password"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request or incorrect password"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /me [delete]
+func (ctrl *Controller) CloseAccount(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	sessionID, ok := authmiddleware.SessionIDFromContext(c)
+	if !ok {
+		ctrl.logger.Debug("Missing session in context")
+		response.Error(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var param CloseAccountParam
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&param); err != nil {
+			ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+			response.Error(c, apperrors.ErrBadRequest)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.CloseAccount(ctx, userID, sessionID, param)
+	if err != nil {
+		ctrl.logger.Error("CloseAccount error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	clearRefreshTokenCookie(c)
+
+	response.Success(c, success.XDeleted.WithField(consts.UserField), nil)
+}
+
+// LockUser godoc
+// @Summary Lock user
+// @Description Lock a user account and end all of its sessions
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Param body body LockUserParam false "Lock until"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/lock [post]
+func (ctrl *Controller) LockUser(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	var param LockUserParam
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&param); err != nil {
+			ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+			response.Error(c, apperrors.ErrBadRequest)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.LockUser(ctx, adminID, userID, param.Until)
+	if err != nil {
+		ctrl.logger.Error("LockUser error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), nil)
+}
+
+// UnlockUser godoc
+// @Summary Unlock user
+// @Description Unlock a user account and reset its failed login counter
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/unlock [post]
+func (ctrl *Controller) UnlockUser(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.UnlockUser(ctx, adminID, userID)
+	if err != nil {
+		ctrl.logger.Error("UnlockUser error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), nil)
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description List users matching exact filters, sorted and paged. The total counts matches on every page.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param username query string false "Username"
+// @Param email query string false "Email"
+// @Param is_active query bool false "Active"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit, 50 by default"
+// @Param order_by query string false "Sort field" Enums(username, email, is_active, created_at, last_login)
+// @Param sort_dir query string false "Sort direction" Enums(asc, desc)
+// @Success 200 {object} response.Response{data=UserList} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users [get]
+func (ctrl *Controller) ListUsers(c *gin.Context) {
+	var param ListUsersParam
+	if err := c.ShouldBindQuery(&param); err != nil {
+		ctrl.logger.Debug("Invalid request query", zap.Error(err))
+		response.Error(c, apperrors.ErrBadRequest.Wrap(err))
+		return
+	}
+	if err := validation.ValidateStruct(&param); err != nil {
+		ctrl.logger.Debug("Invalid request query", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	users, err := ctrl.service.ListUsers(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("ListUsers error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(consts.UserField), users)
+}
+
+// GetUser godoc
+// @Summary Get user
+// @Description Get a user account
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response{data=user.Response} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id} [get]
+func (ctrl *Controller) GetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := ctrl.service.GetUser(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("GetUser error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(consts.UserField), user)
+}
+
+// UpdateUser godoc
+// @Summary Update user
+// @Description Change a user's username, email or email verification
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Param body body UpdateUserParam true "Changes"
+// @Success 200 {object} response.Response{data=user.Response} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 409 {object} response.Response "Username or email taken"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id} [patch]
+func (ctrl *Controller) UpdateUser(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[UpdateUserParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := ctrl.service.UpdateUser(ctx, adminID, userID, param)
+	if err != nil {
+		ctrl.logger.Error("UpdateUser error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), user)
+}
+
+// DeactivateUser godoc
+// @Summary Deactivate user
+// @Description Stop a user from logging in and end all of their sessions
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/deactivate [post]
+func (ctrl *Controller) DeactivateUser(c *gin.Context) {
+	ctrl.setUserActive(c, false)
+}
+
+// ReactivateUser godoc
+// @Summary Reactivate user
+// @Description Let a deactivated user log in again
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/reactivate [post]
+func (ctrl *Controller) ReactivateUser(c *gin.Context) {
+	ctrl.setUserActive(c, true)
+}
+
+func (ctrl *Controller) setUserActive(c *gin.Context, active bool) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.SetUserActive(ctx, adminID, userID, active)
+	if err != nil {
+		ctrl.logger.Error("SetUserActive error", zap.String("user_id", userID.String()), zap.Bool("active", active), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), nil)
+}
+
+// ForcePasswordReset godoc
+// @Summary Force password reset
+// @Description End all of a user's sessions and email them a password reset link. Their current password stops working.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request or user has no email"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/password-reset [post]
+func (ctrl *Controller) ForcePasswordReset(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.ForcePasswordReset(ctx, adminID, userID)
+	if err != nil {
+		ctrl.logger.Error("ForcePasswordReset error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XReset.WithField(consts.PasswordField), nil)
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Delete a user account and end all of its sessions
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden, or the user holds a permission the caller lacks"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id} [delete]
+func (ctrl *Controller) DeleteUser(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.DeleteUser(ctx, adminID, userID)
+	if err != nil {
+		ctrl.logger.Error("DeleteUser error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(consts.UserField), nil)
+}
+
+// Reauthenticate godoc
+// @Summary Reauthenticate
+// @Description Confirm the password, or an authenticator code for accounts without one, before changing sign-in methods
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body ReauthenticateParam true "Password or code"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/reauthenticate [post]
+func (ctrl *Controller) Reauthenticate(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	sessionID, ok := authmiddleware.SessionIDFromContext(c)
+	if !ok {
+		ctrl.logger.Debug("Missing session in context")
+		response.Error(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[ReauthenticateParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.Reauthenticate(ctx, userID, sessionID, param)
+	if err != nil {
+		ctrl.logger.Error("Reauthenticate error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.SessionField), nil)
+}
+
+// DisableTOTP godoc
+// @Summary Disable TOTP
+// @Description Remove the authenticator. A current code is required once MFA is enabled, and wrong codes count towards the lockout. Requires a recent login.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body mfa.CodeParam true "Code"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 404 {object} response.Response "MFA not enabled"
+// @Failure 423 {object} response.Response "Account locked"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/totp [delete]
+func (ctrl *Controller) DisableTOTP(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[mfa.CodeParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.DisableTOTP(ctx, userID, param.Code)
+	if err != nil {
+		ctrl.logger.Error("DisableTOTP error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.MFAField), nil)
+}
+
+// ListAuthMethods godoc
+// @Summary List sign-in methods
+// @Description List the password, providers and passkeys the current user can sign in with
+// @Tags Authentication
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]authmethod.AuthMethod} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/methods [get]
+func (ctrl *Controller) ListAuthMethods(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	methods, err := ctrl.service.ListAuthMethods(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("ListAuthMethods error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.AuthMethodField), methods)
+}
+
+// LinkPassword godoc
+// @Summary Add a password
+// @Description Add a password to an account that signs in with providers or passkeys. Requires a recent login.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body LinkPasswordParam true "Password"
+// @Success 201 {object} response.Response "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 409 {object} response.Response "Password already set"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/methods/password [post]
+func (ctrl *Controller) LinkPassword(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[LinkPasswordParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.LinkPassword(ctx, userID, param.Password)
+	if err != nil {
+		ctrl.logger.Error("LinkPassword error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(consts.PasswordField), nil)
+}
+
+// LinkProvider godoc
+// @Summary Link a provider
+// @Description Get the URL to sign in at Google, GitHub or Apple. The provider is linked in the callback. Requires a recent login.
+// @Tags Authentication
+// @Produce json
+// @Security BearerTokenAuth
+// @Param provider path string true "Provider" Enums(google, github, apple)
+// @Success 200 {object} response.Response{data=LinkProviderResponse} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 404 {object} response.Response "Provider not configured"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/methods/social/{provider} [post]
+func (ctrl *Controller) LinkProvider(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	provider := c.Param("provider")
+	ctx := c.Request.Context()
+	authz, err := ctrl.service.LinkProviderURL(ctx, userID, provider)
+	if err != nil {
+		ctrl.logger.Error("LinkProvider error", zap.String("provider", provider), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	// Not a redirect, since the request carries the access token in a header
+	setSocialStateCookie(c, provider, authz.Binding)
+	response.Success(c, success.XFound.WithField(authconsts.ProviderField), LinkProviderResponse{AuthorizationURL: authz.URL})
+}
+
+// LinkIdentity godoc
+// @Summary Link a pending provider account
+// @Description Link the provider account from a social login that returned link_required. Requires a recent login.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body LinkIdentityParam true "Link token"
+// @Success 201 {object} response.Response{data=authmethod.AuthMethod} "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 409 {object} response.Response "Provider account already linked"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/methods/link [post]
+func (ctrl *Controller) LinkIdentity(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[LinkIdentityParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	method, err := ctrl.service.LinkPendingIdentity(ctx, userID, param.LinkToken)
+	if err != nil {
+		ctrl.logger.Error("LinkIdentity error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.AuthMethodField), method)
+}
+
+// UnlinkAuthMethod godoc
+// @Summary Unlink a sign-in method
+// @Description Remove a password, provider or passkey. The last method cannot be removed. Requires a recent login.
+// @Tags Authentication
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Method ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 404 {object} response.Response "Method not found"
+// @Failure 409 {object} response.Response "Last sign-in method"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/methods/{id} [delete]
+func (ctrl *Controller) UnlinkAuthMethod(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid method ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.AuthMethodField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.UnlinkAuthMethod(ctx, userID, id)
+	if err != nil {
+		ctrl.logger.Error("UnlinkAuthMethod error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.AuthMethodField), nil)
+}
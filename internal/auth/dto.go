@@ -1,6 +1,12 @@
 package auth
 
-import "github.com/google/uuid"
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/passkey"
+	userModel "auth-service/internal/user"
+	"github.com/google/uuid"
+	"time"
+)
 
 type (
 	RegisterParam struct {
@@ -10,18 +16,61 @@ type (
 	}
 
 	LoginParam struct {
-		Email    string `json:"email" validate:"required,email"`
-		Password string `json:"password" validate:"required,min=6"`
+		Email      string  `json:"email" validate:"required,email"`
+		Password   string  `json:"password" validate:"required,min=6"`
+		DeviceName *string `json:"device_name" validate:"omitempty,max=255"`
+	}
+
+	RecoveryLoginParam struct {
+		Email        string  `json:"email" validate:"required,email"`
+		Password     string  `json:"password" validate:"required,min=6"`
+		RecoveryCode string  `json:"recovery_code" validate:"required,max=32"`
+		DeviceName   *string `json:"device_name" validate:"omitempty,max=255"`
 	}
 
 	Tokens struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
+		AccessToken  string `json:"access_token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+	}
+
+	PasskeyLoginParam struct {
+		passkey.FinishParam
+		DeviceName *string `json:"device_name" validate:"omitempty,max=255"`
 	}
 
+	// LoginResponse carries either a session, or an MFA token when the user
+	// still has to pass one of MFAMethods as a second factor. After a social
+	// login whose email belongs to an existing account, it carries a link
+	// token for /auth/methods/link instead.
 	LoginResponse struct {
 		Tokens
-		User UserClaims `json:"user"`
+		User         *UserClaims `json:"user,omitempty"`
+		MFARequired  bool        `json:"mfa_required,omitempty"`
+		MFAToken     string      `json:"mfa_token,omitempty"`
+		MFAMethods   []string    `json:"mfa_methods,omitempty"`
+		LinkRequired bool        `json:"link_required,omitempty"`
+		LinkToken    string      `json:"link_token,omitempty"`
+	}
+
+	// ProviderCallbackResult is a login, or the linked method when the user
+	// started at /auth/methods/social/{provider}.
+	ProviderCallbackResult struct {
+		Login  *LoginResponse
+		Linked *authmethod.AuthMethod
+	}
+
+	VerifyMFAParam struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+		Code     string `json:"code" validate:"required,len=6,numeric"`
+	}
+
+	MFATokenParam struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+	}
+
+	VerifyMFAPasskeyParam struct {
+		passkey.FinishParam
+		MFAToken string `json:"mfa_token" validate:"required"`
 	}
 
 	UserClaims struct {
@@ -34,4 +83,75 @@ type (
 		OldPassword string `json:"old_password" validate:"required"`
 		NewPassword string `json:"new_password" validate:"required,min=6"`
 	}
+
+	ForgotPasswordParam struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	ResetPasswordParam struct {
+		Token       string `json:"token" validate:"required"`
+		NewPassword string `json:"new_password" validate:"required,min=6"`
+	}
+
+	// ReauthenticateParam confirms the user with their password or, for
+	// accounts without one, an authenticator code.
+	ReauthenticateParam struct {
+		Password *string `json:"password" validate:"required_without=Code"`
+		Code     *string `json:"code" validate:"omitempty,len=6,numeric"`
+	}
+
+	LinkPasswordParam struct {
+		Password string `json:"password" validate:"required,min=6"`
+	}
+
+	LinkProviderResponse struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+
+	LinkIdentityParam struct {
+		LinkToken string `json:"link_token" validate:"required"`
+	}
+
+	LockUserParam struct {
+		Until *time.Time `json:"until" validate:"omitempty"` // locked indefinitely when empty
+	}
+
+	// UpdateProfileParam changes the current user's profile. Usernames are 3
+	// to 50 letters, digits, dots, dashes and underscores.
+	UpdateProfileParam struct {
+		Username string `json:"username" validate:"required,min=3,max=50"`
+	}
+
+	// CloseAccountParam confirms closing the current user's account. Users
+	// without a password re-authenticate first instead.
+	CloseAccountParam struct {
+		Password *string `json:"password" validate:"omitempty"`
+	}
+
+	// ListUsersParam filters, sorts and pages the user list. Filters match
+	// exactly.
+	ListUsersParam struct {
+		Username *string `form:"username" validate:"omitempty,max=50"`
+		Email    *string `form:"email" validate:"omitempty,max=255"`
+		IsActive *bool   `form:"is_active"`
+		Offset   *int    `form:"offset" validate:"omitempty,min=0"`
+		Limit    *int    `form:"limit" validate:"omitempty,min=1,max=1000"`
+		OrderBy  *string `form:"order_by" validate:"omitempty,oneof=username email is_active created_at last_login"`
+		SortDir  *string `form:"sort_dir" validate:"omitempty,oneof=asc desc"`
+	}
+
+	UserList struct {
+		Users  []userModel.Response `json:"users"`
+		Total  int64                `json:"total"` // matching users on all pages
+		Offset int                  `json:"offset"`
+		Limit  int                  `json:"limit"`
+	}
+
+	// UpdateUserParam changes the fields that are set. An empty username
+	// removes it, and a new email is unverified unless email_verified is set.
+	UpdateUserParam struct {
+		Username      *string `json:"username" validate:"omitempty,max=50"`
+		Email         *string `json:"email" validate:"omitempty,email,max=255"`
+		EmailVerified *bool   `json:"email_verified"`
+	}
 )
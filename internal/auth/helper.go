@@ -2,6 +2,8 @@ package auth
 
 import (
 	"auth-service/internal/shared/consts"
+	"auth-service/internal/social"
+	userModel "auth-service/internal/user"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
@@ -21,6 +23,32 @@ func isPasswordMatch(hashedPassword, password string) (bool, error) {
 	return true, nil
 }
 
+// Second factors listed in LoginResponse.MFAMethods
+const (
+	mfaMethodTOTP     = "totp"
+	mfaMethodWebAuthn = "webauthn"
+)
+
+// Authentication method references (RFC 8176) recorded on the session
+const (
+	amrPassword    = "pwd"
+	amrOTP         = "otp"
+	amrHardwareKey = "hwk"
+	amrFederated   = "fed"
+	amrMFA         = "mfa"
+)
+
+// permanentLock is the lock time used when an administrator locks an account
+// without an end date.
+var permanentLock = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// socialCallbackPath scopes the social state cookie to the callbacks
+const socialCallbackPath = "/api/v1/auth/social/"
+
+func isAccountLocked(user *userModel.User) bool {
+	return user.AccountLockedUntil != nil && user.AccountLockedUntil.After(time.Now())
+}
+
 func setRefreshTokenCookie(c *gin.Context, value string) {
 	c.SetSameSite(http.SameSiteLaxMode)
 	c.SetCookie(
@@ -34,6 +62,37 @@ func setRefreshTokenCookie(c *gin.Context, value string) {
 	)
 }
 
+// setSocialStateCookie keeps the state binding of a social login for the
+// callback. Apple posts the callback cross-site, which Lax cookies miss.
+func setSocialStateCookie(c *gin.Context, provider, binding string) {
+	if provider == social.ProviderApple {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(
+		consts.CookieSocialState,
+		binding,
+		0, // gone with the browser session; the state itself expires sooner
+		socialCallbackPath,
+		"",
+		true,
+		true,
+	)
+}
+
+func clearSocialStateCookie(c *gin.Context) {
+	c.SetCookie(
+		consts.CookieSocialState,
+		"",
+		-1,
+		socialCallbackPath,
+		"",
+		true,
+		true,
+	)
+}
+
 func clearRefreshTokenCookie(c *gin.Context) {
 	c.SetCookie(
 		consts.CookieRefreshToken,
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"auth-service/internal/shared/consts"
+	"auth-service/internal/social"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetSocialStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := map[string]http.SameSite{
+		social.ProviderGoogle: http.SameSiteLaxMode,
+		social.ProviderGitHub: http.SameSiteLaxMode,
+		// Apple posts the callback from its own site
+		social.ProviderApple: http.SameSiteNoneMode,
+	}
+	for provider, sameSite := range tests {
+		t.Run(provider, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			setSocialStateCookie(c, provider, "binding")
+
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("got %d cookies, want 1", len(cookies))
+			}
+			cookie := cookies[0]
+			if cookie.Name != consts.CookieSocialState || cookie.Value != "binding" {
+				t.Errorf("cookie = %s=%s", cookie.Name, cookie.Value)
+			}
+			if !cookie.HttpOnly || !cookie.Secure {
+				t.Errorf("HttpOnly = %v, Secure = %v, want both", cookie.HttpOnly, cookie.Secure)
+			}
+			if cookie.SameSite != sameSite {
+				t.Errorf("SameSite = %v, want %v", cookie.SameSite, sameSite)
+			}
+			if cookie.Path != socialCallbackPath {
+				t.Errorf("Path = %q, want %q", cookie.Path, socialCallbackPath)
+			}
+		})
+	}
+}
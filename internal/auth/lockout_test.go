@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func newTestLockoutPolicy() *lockoutPolicy {
+	cfg := &config.Config{}
+	cfg.Lockout.MaxAttempts = 3
+	cfg.Lockout.AttemptWindow = time.Minute
+	cfg.Lockout.BaseDuration = time.Minute
+	cfg.Lockout.MaxDuration = 10 * time.Minute
+	return newLockoutPolicy(cfg)
+}
+
+// failUntilLocked records failures until the account locks and returns how
+// long it is locked for.
+func failUntilLocked(t *testing.T, p *lockoutPolicy, userID uuid.UUID) time.Duration {
+	t.Helper()
+	for i := int64(1); i <= p.maxAttempts; i++ {
+		before := time.Now()
+		until, err := p.recordFailure(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("recordFailure: %v", err)
+		}
+		if i < p.maxAttempts {
+			if until != nil {
+				t.Fatalf("locked after %d of %d failures", i, p.maxAttempts)
+			}
+			continue
+		}
+		if until == nil {
+			t.Fatalf("not locked after %d failures", i)
+		}
+		return until.Sub(before).Round(time.Second)
+	}
+	return 0
+}
+
+func TestNewLockoutPolicy_Defaults(t *testing.T) {
+	p := newLockoutPolicy(&config.Config{})
+	if p.maxAttempts != defaultMaxAttempts || p.attemptWindow != defaultAttemptWindow ||
+		p.baseDuration != defaultLockBase || p.maxDuration != defaultLockMax {
+		t.Errorf("policy = %+v, want the defaults", p)
+	}
+
+	cfg := &config.Config{}
+	cfg.Lockout.BaseDuration = 48 * time.Hour
+	if p := newLockoutPolicy(cfg); p.maxDuration < p.baseDuration {
+		t.Errorf("max duration %v below base duration %v", p.maxDuration, p.baseDuration)
+	}
+}
+
+func TestLockDuration(t *testing.T) {
+	p := newTestLockoutPolicy()
+	for lockouts, want := range map[int64]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		4:  8 * time.Minute,
+		5:  10 * time.Minute,
+		60: 10 * time.Minute,
+	} {
+		if got := p.lockDuration(lockouts); got != want {
+			t.Errorf("lockDuration(%d) = %v, want %v", lockouts, got, want)
+		}
+	}
+}
+
+func TestRecordFailure_Backoff(t *testing.T) {
+	p := newTestLockoutPolicy()
+	userID := uuid.New()
+
+	// The failure count starts over after each lockout, the lock doubles
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if got := failUntilLocked(t, p, userID); got != want {
+			t.Errorf("locked for %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRecordFailure_PerAccount(t *testing.T) {
+	p := newTestLockoutPolicy()
+	ctx := context.Background()
+	userID := uuid.New()
+
+	for range p.maxAttempts - 1 {
+		if _, err := p.recordFailure(ctx, userID); err != nil {
+			t.Fatalf("recordFailure: %v", err)
+		}
+	}
+
+	until, err := p.recordFailure(ctx, uuid.New())
+	if err != nil {
+		t.Fatalf("recordFailure: %v", err)
+	}
+	if until != nil {
+		t.Error("another account's failures locked this one")
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := newTestLockoutPolicy()
+	ctx := context.Background()
+	userID := uuid.New()
+	failUntilLocked(t, p, userID)
+
+	for range p.maxAttempts - 1 {
+		if _, err := p.recordFailure(ctx, userID); err != nil {
+			t.Fatalf("recordFailure: %v", err)
+		}
+	}
+	if err := p.reset(ctx, userID); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	// Both the pending failures and the backoff are forgotten
+	if got := failUntilLocked(t, p, userID); got != p.baseDuration {
+		t.Errorf("locked for %v after reset, want %v", got, p.baseDuration)
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	autherrors "auth-service/internal/shared/errors"
+	"auth-service/internal/social"
+	userModel "auth-service/internal/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"strings"
+)
+
+func (s *service) Reauthenticate(ctx context.Context, userID, sessionID uuid.UUID, param ReauthenticateParam) error {
+	const op = "service.Reauthenticate"
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if isAccountLocked(user) {
+		return autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	// Wrong answers count towards the lockout like failed logins, so a stolen
+	// session cannot be used to guess the password
+	if param.Password != nil {
+		if user.PasswordHash == nil {
+			return s.handleFailedLogin(ctx, op, userID, consts.PasswordField)
+		}
+
+		isValid, err := isPasswordMatch(*user.PasswordHash, *param.Password)
+		if err != nil {
+			return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		} else if !isValid {
+			return s.handleFailedLogin(ctx, op, userID, consts.PasswordField)
+		}
+	} else {
+		err = s.mfaSvc.Verify(ctx, userID, *param.Code)
+		if apperrors.Is(err, apperrors.ErrInvalidX) || apperrors.Is(err, apperrors.ErrXNotFound) {
+			return s.handleFailedLogin(ctx, op, userID, authconsts.CodeField)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	err = s.reauth.Mark(ctx, sessionID)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	return nil
+}
+
+func (s *service) DisableTOTP(ctx context.Context, userID uuid.UUID, code string) error {
+	const op = "service.DisableTOTP"
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if isAccountLocked(user) {
+		return autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	// Wrong codes count towards the lockout, as in Reauthenticate
+	err = s.mfaSvc.Disable(ctx, userID, code)
+	if apperrors.Is(err, apperrors.ErrInvalidX) {
+		return s.handleFailedLogin(ctx, op, userID, authconsts.CodeField)
+	}
+	return err
+}
+
+func (s *service) ListAuthMethods(ctx context.Context, userID uuid.UUID) ([]authmethod.AuthMethod, error) {
+	return s.authMethodSvc.ListMethods(ctx, userID)
+}
+
+func (s *service) LinkPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	const op = "service.LinkPassword"
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	} else if user.PasswordHash != nil {
+		// Changing it goes through /auth/change-password
+		return apperrors.ErrXConflict.WithField(consts.PasswordField).WithOp(op)
+	}
+
+	err = s.userSvc.UpdateUser(ctx, userID, &userModel.UpdateUserParam{Password: &password})
+	if err != nil {
+		return err
+	}
+
+	s.recordMethodChange(ctx, securitylog.ActionAuthMethodLinked, userID, authmethod.TypePassword)
+	return nil
+}
+
+func (s *service) LinkProviderURL(ctx context.Context, userID uuid.UUID, provider string) (*social.Authorization, error) {
+	return s.socialSvc.LinkAuthorizationURL(ctx, provider, userID)
+}
+
+func (s *service) LinkPendingIdentity(ctx context.Context, userID uuid.UUID, linkToken string) (*authmethod.AuthMethod, error) {
+	const op = "service.LinkPendingIdentity"
+
+	identity, err := s.socialSvc.TakePendingLink(ctx, linkToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token was issued for the account that owns the provider's email
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if user.Email == nil || !strings.EqualFold(*user.Email, identity.Email) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.TokenField).WithOp(op)
+	}
+
+	return s.linkIdentity(ctx, userID, identity)
+}
+
+func (s *service) linkIdentity(ctx context.Context, userID uuid.UUID, identity *social.Identity) (*authmethod.AuthMethod, error) {
+	method, err := s.authMethodSvc.Link(ctx, userID, identity.MethodType, identity.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordMethodChange(ctx, securitylog.ActionAuthMethodLinked, userID, identity.MethodType)
+	return method, nil
+}
+
+func (s *service) UnlinkAuthMethod(ctx context.Context, userID, id uuid.UUID) error {
+	method, err := s.authMethodSvc.Unlink(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	s.recordMethodChange(ctx, securitylog.ActionAuthMethodUnlinked, userID, method.MethodType)
+	return nil
+}
+
+func (s *service) recordMethodChange(ctx context.Context, action securitylog.Action, userID uuid.UUID, methodType authmethod.Type) {
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   action,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"method_type": methodType},
+	})
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/securitylog"
+	autherrors "auth-service/internal/shared/errors"
+	"auth-service/internal/social"
+	"context"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"testing"
+)
+
+// fakeSocialService hands out one pending identity.
+type fakeSocialService struct {
+	social.Service
+	identity *social.Identity
+}
+
+func (f fakeSocialService) TakePendingLink(context.Context, string) (*social.Identity, error) {
+	return f.identity, nil
+}
+
+// fakeAuthMethodService records the methods it links.
+type fakeAuthMethodService struct {
+	authmethod.Service
+	linked []authmethod.AuthMethod
+}
+
+func (f *fakeAuthMethodService) Link(_ context.Context, userID uuid.UUID, methodType authmethod.Type, providerID string) (*authmethod.AuthMethod, error) {
+	method := authmethod.AuthMethod{ID: uuid.New(), UserID: userID, MethodType: methodType, ProviderID: &providerID}
+	f.linked = append(f.linked, method)
+	return &method, nil
+}
+
+func TestLinkPendingIdentity(t *testing.T) {
+	tests := []struct {
+		name   string
+		email  string
+		linked bool
+	}{
+		{"same email", "ada@example.com", true},
+		{"email in another case", "Ada@Example.com", true},
+		{"another email", "grace@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, user := newLoginService(t)
+			methods := &fakeAuthMethodService{}
+			s.authMethodSvc = methods
+			s.socialSvc = fakeSocialService{identity: &social.Identity{
+				Profile:    social.Profile{ProviderID: "google-1", Email: tt.email},
+				MethodType: authmethod.TypeGoogle,
+			}}
+
+			_, err := s.LinkPendingIdentity(context.Background(), user.ID, "link-token")
+			if !tt.linked {
+				if !apperrors.Is(err, apperrors.ErrInvalidX) {
+					t.Errorf("err = %v, want invalid token", err)
+				}
+				if len(methods.linked) != 0 {
+					t.Error("linked an account with another email")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LinkPendingIdentity: %v", err)
+			}
+			if len(methods.linked) != 1 || methods.linked[0].UserID != user.ID {
+				t.Errorf("linked = %+v, want the provider linked to the user", methods.linked)
+			}
+			if !s.securityLogSvc.(*fakeSecurityLog).has(securitylog.ActionAuthMethodLinked) {
+				t.Error("link not recorded")
+			}
+		})
+	}
+}
+
+func TestDisableTOTP_WrongCodesLockAccount(t *testing.T) {
+	s, user := newLoginService(t)
+	ctx := context.Background()
+
+	for i := int64(1); i < s.lockout.maxAttempts; i++ {
+		err := s.DisableTOTP(ctx, user.ID, "000000")
+		if !apperrors.Is(err, apperrors.ErrIncorrectX) {
+			t.Fatalf("attempt %d: err = %v, want incorrect code", i, err)
+		}
+	}
+
+	err := s.DisableTOTP(ctx, user.ID, "000000")
+	if !apperrors.Is(err, autherrors.ErrAccountLocked) {
+		t.Fatalf("last attempt: err = %v, want account locked", err)
+	}
+
+	// The right code no longer helps once the account is locked
+	err = s.DisableTOTP(ctx, user.ID, validCode)
+	if !apperrors.Is(err, autherrors.ErrAccountLocked) {
+		t.Errorf("valid code while locked: err = %v, want account locked", err)
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	s, user := newLoginService(t)
+
+	if err := s.DisableTOTP(context.Background(), user.ID, validCode); err != nil {
+		t.Errorf("DisableTOTP: %v", err)
+	}
+}
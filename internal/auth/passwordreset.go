@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"time"
+)
+
+const (
+	redisPasswordResetPrefix         = "auth:password_reset:"
+	redisPasswordResetUserPrefix     = "auth:password_reset_user:"
+	redisPasswordResetCooldownPrefix = "auth:password_reset_cooldown:"
+
+	resetTokenBytes      = 32
+	defaultResetTokenTTL = 30 * time.Minute
+	defaultResetCooldown = time.Minute
+	resetMailTimeout     = 30 * time.Second // the mail is sent in the background
+)
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// resetTokenStore keeps password reset tokens hashed in Redis. A user has at
+// most one outstanding token; issuing a new one invalidates the previous.
+type resetTokenStore struct {
+	ttl      time.Duration
+	cooldown time.Duration
+}
+
+func newResetTokenStore(cfg *config.Config) *resetTokenStore {
+	s := &resetTokenStore{
+		ttl:      cfg.PasswordReset.TokenTTL,
+		cooldown: cfg.PasswordReset.RequestCooldown,
+	}
+
+	if s.ttl <= 0 {
+		s.ttl = defaultResetTokenTTL
+	}
+	if s.cooldown <= 0 {
+		s.cooldown = defaultResetCooldown
+	}
+
+	return s
+}
+
+func hashResetToken(resetToken string) string {
+	sum := sha256.Sum256([]byte(resetToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// allowRequest reports whether a new token may be sent to the user, so the
+// endpoint cannot be used to flood a mailbox.
+func (s *resetTokenStore) allowRequest(ctx context.Context, userID uuid.UUID) (bool, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return false, err
+	}
+	return client.SetNX(ctx, redisPasswordResetCooldownPrefix+userID.String(), 1, s.cooldown).Result()
+}
+
+func (s *resetTokenStore) issue(ctx context.Context, userID uuid.UUID) (string, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(b)
+	hash := hashResetToken(resetToken)
+
+	userKey := redisPasswordResetUserPrefix + userID.String()
+	previous, err := client.Get(ctx, userKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != "" {
+			pipe.Del(ctx, redisPasswordResetPrefix+previous)
+		}
+		pipe.Set(ctx, redisPasswordResetPrefix+hash, userID.String(), s.ttl)
+		pipe.Set(ctx, userKey, hash, s.ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return resetToken, nil
+}
+
+// consume deletes the token and returns the user it was issued to. Only the
+// first caller gets the user; the token cannot be used again.
+func (s *resetTokenStore) consume(ctx context.Context, resetToken string) (uuid.UUID, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	hash := hashResetToken(resetToken)
+	value, err := client.GetDel(ctx, redisPasswordResetPrefix+hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return uuid.Nil, errInvalidResetToken
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, errInvalidResetToken
+	}
+
+	userKey := redisPasswordResetUserPrefix + userID.String()
+	current, err := client.Get(ctx, userKey).Result()
+	if err == nil && current == hash {
+		client.Del(ctx, userKey)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/testutil"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"testing"
+	"time"
+)
+
+func TestResetPassword(t *testing.T) {
+	s, user := newLoginService(t)
+	users := s.userSvc.(*fakeUserService)
+	sessions := s.sessionSvc.(*fakeSessionService)
+	ctx := context.Background()
+
+	if err := sessions.CreateSession(ctx, user.ID, uuid.New(), nil, nil); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	resetToken, err := s.resetTokens.issue(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	if err := s.ResetPassword(ctx, resetToken, "new password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if hash := users.users[user.ID].PasswordHash; hash == nil || *hash != "new password" {
+		t.Error("password not changed")
+	}
+	if sessions.count(user.ID) != 0 {
+		t.Error("sessions survived the reset")
+	}
+	if !s.securityLogSvc.(*fakeSecurityLog).has(securitylog.ActionPasswordReset) {
+		t.Error("reset not recorded")
+	}
+
+	// The token is single use
+	err = s.ResetPassword(ctx, resetToken, "another password")
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("second use: err = %v, want invalid token", err)
+	}
+	if *users.users[user.ID].PasswordHash != "new password" {
+		t.Error("password changed by a used token")
+	}
+}
+
+func TestResetTokenStore(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.PasswordReset.TokenTTL = time.Minute
+	store := newResetTokenStore(cfg)
+
+	tests := []struct {
+		name string
+		// use returns the token to consume after one was issued
+		use     func(t *testing.T, userID uuid.UUID, issued string) string
+		wantErr bool
+	}{
+		{"issued token", func(_ *testing.T, _ uuid.UUID, issued string) string { return issued }, false},
+		{"unknown token", func(*testing.T, uuid.UUID, string) string { return "not-a-token" }, true},
+		{"expired", func(_ *testing.T, _ uuid.UUID, issued string) string {
+			testutil.FastForward(time.Minute + time.Second)
+			return issued
+		}, true},
+		{"replaced by a newer token", func(t *testing.T, userID uuid.UUID, issued string) string {
+			if _, err := store.issue(context.Background(), userID); err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+			return issued
+		}, true},
+		{"newer token", func(t *testing.T, userID uuid.UUID, _ string) string {
+			newer, err := store.issue(context.Background(), userID)
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+			return newer
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			userID := uuid.New()
+			issued, err := store.issue(ctx, userID)
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+
+			got, err := store.consume(ctx, tt.use(t, userID, issued))
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidResetToken) {
+					t.Errorf("err = %v, want invalid token", err)
+				}
+				return
+			}
+			if err != nil || got != userID {
+				t.Errorf("consume = %v, %v, want %v", got, err, userID)
+			}
+		})
+	}
+}
+
+func TestResetTokenStore_Cooldown(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.PasswordReset.RequestCooldown = time.Minute
+	store := newResetTokenStore(cfg)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	for i, want := range []bool{true, false} {
+		allowed, err := store.allowRequest(ctx, userID)
+		if err != nil {
+			t.Fatalf("allowRequest: %v", err)
+		}
+		if allowed != want {
+			t.Errorf("request %d allowed = %v, want %v", i+1, allowed, want)
+		}
+	}
+
+	testutil.FastForward(time.Minute)
+	if allowed, _ := store.allowRequest(ctx, userID); !allowed {
+		t.Error("request after the cooldown refused")
+	}
+}
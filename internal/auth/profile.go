@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"auth-service/internal/securitylog"
+	autherrors "auth-service/internal/shared/errors"
+	userModel "auth-service/internal/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+)
+
+func (s *service) UpdateProfile(ctx context.Context, userID uuid.UUID, param UpdateProfileParam) (*userModel.Response, error) {
+	err := s.userSvc.UpdateUser(ctx, userID, &userModel.UpdateUserParam{
+		Username: &param.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+func (s *service) CloseAccount(ctx context.Context, userID, sessionID uuid.UUID, param CloseAccountParam) error {
+	const op = "service.CloseAccount"
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if isAccountLocked(user) {
+		return autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	// Wrong passwords count towards the lockout, as in Reauthenticate
+	if user.PasswordHash != nil {
+		if param.Password == nil {
+			return apperrors.ErrXIsRequired.WithField(consts.PasswordField).WithOp(op)
+		}
+
+		isValid, err := isPasswordMatch(*user.PasswordHash, *param.Password)
+		if err != nil {
+			return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		} else if !isValid {
+			return s.handleFailedLogin(ctx, op, userID, consts.PasswordField)
+		}
+	} else {
+		// Without a password, the session must have re-authenticated
+		recent, err := s.reauth.IsRecent(ctx, sessionID)
+		if err != nil {
+			return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		} else if !recent {
+			return autherrors.ErrReauthRequired.WithOp(op)
+		}
+	}
+
+	err = s.revokeAllSessions(ctx, op, userID)
+	if err != nil {
+		return err
+	}
+
+	// Recorded first, while the user still exists. Deleting them clears the
+	// log's user_id, so the ID is kept in the metadata too.
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionAccountClosed,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"user_id": userID.String()},
+	})
+
+	err = s.userSvc.DeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Account closed", zap.String("user_id", userID.String()))
+	return nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	userModel "auth-service/internal/user"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	"github.com/xinyi-chong/common-lib/success"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// profileRouter serves /me as the user signed in to sessionID, the way
+// AuthMiddleware would.
+func profileRouter(s *service, userID, sessionID uuid.UUID) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	ctrl := NewController(s, zap.NewNop())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(consts.CtxUserID, userID)
+		c.Set(authconsts.CtxSessionID, sessionID.String())
+		c.Next()
+	})
+	router.GET("/me", ctrl.GetProfile)
+	router.PATCH("/me", ctrl.UpdateProfile)
+	router.DELETE("/me", ctrl.CloseAccount)
+	return router
+}
+
+func serve(router *gin.Engine, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/me", strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProfile(t *testing.T) {
+	s, user := newLoginService(t)
+	router := profileRouter(s, user.ID, uuid.New())
+
+	w := serve(router, http.MethodPatch, `{"username":"ada.lovelace"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH /me: status = %d, body %s", w.Code, w.Body)
+	}
+
+	w = serve(router, http.MethodGet, "")
+	if w.Code != success.XFound.HTTPStatus {
+		t.Fatalf("GET /me: status = %d, body %s", w.Code, w.Body)
+	}
+	var body struct {
+		Data userModel.Response `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Data.ID != user.ID || body.Data.Username == nil || *body.Data.Username != "ada.lovelace" {
+		t.Errorf("profile = %+v", body.Data)
+	}
+
+	if w := serve(router, http.MethodPatch, `{"username":"a"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH /me with a short username: status = %d, want 400", w.Code)
+	}
+}
+
+func TestCloseAccount(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	passwordHash := string(hash)
+
+	tests := []struct {
+		name        string
+		hasPassword bool
+		reauthed    bool
+		body        string
+		wantStatus  int
+	}{
+		{"correct password", true, false, `{"password":"correct horse"}`, http.StatusOK},
+		{"wrong password", true, false, `{"password":"wrong"}`, http.StatusBadRequest},
+		{"missing password", true, true, "", http.StatusBadRequest},
+		{"no password, reauthenticated", false, true, "", http.StatusOK},
+		{"no password, not reauthenticated", false, false, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, user := newLoginService(t)
+			users := s.userSvc.(*fakeUserService)
+			sessions := s.sessionSvc.(*fakeSessionService)
+			log := s.securityLogSvc.(*fakeSecurityLog)
+			ctx := context.Background()
+
+			if tt.hasPassword {
+				users.users[user.ID].PasswordHash = &passwordHash
+			}
+			sessionID := uuid.New()
+			if err := sessions.CreateSession(ctx, user.ID, sessionID, nil, nil); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			if tt.reauthed {
+				if err := s.reauth.Mark(ctx, sessionID); err != nil {
+					t.Fatalf("Mark: %v", err)
+				}
+			}
+
+			w := serve(profileRouter(s, user.ID, sessionID), http.MethodDelete, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			_, err := users.GetUser(ctx, user.ID)
+			closed := err != nil
+			if closed != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("account closed = %v", closed)
+			}
+			if closed && sessions.count(user.ID) != 0 {
+				t.Error("sessions survived closing the account")
+			}
+			if closed != log.has(securitylog.ActionAccountClosed) {
+				t.Errorf("account_closed recorded = %v, want %v", !closed, closed)
+			}
+		})
+	}
+}
@@ -1,29 +1,132 @@
 package auth
 
 import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/config"
+	"auth-service/internal/mfa"
+	"auth-service/internal/passkey"
+	"auth-service/internal/reauth"
+	"auth-service/internal/role"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/session"
+	"auth-service/internal/shared/clientinfo"
+	authconsts "auth-service/internal/shared/consts"
+	autherrors "auth-service/internal/shared/errors"
+	"auth-service/internal/social"
 	userModel "auth-service/internal/user"
+	"auth-service/internal/verification"
 	"auth-service/pkg/jwt"
+	"auth-service/pkg/mailer"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/xinyi-chong/common-lib/consts"
 	apperrors "github.com/xinyi-chong/common-lib/errors"
 	"go.uber.org/zap"
+	"net/url"
+	"time"
 )
 
 type Service interface {
 	Register(ctx context.Context, param RegisterParam) error
-	Login(ctx context.Context, email, password string) (*LoginResponse, error)
+	Login(ctx context.Context, param LoginParam) (*LoginResponse, error)
 	ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, resetToken, newPassword string) error
+	LoginWithRecoveryCode(ctx context.Context, param RecoveryLoginParam) (*LoginResponse, error)
+	VerifyMFA(ctx context.Context, param VerifyMFAParam) (*LoginResponse, error)
+	BeginMFAPasskey(ctx context.Context, mfaToken string) (*passkey.BeginResponse, error)
+	VerifyMFAPasskey(ctx context.Context, param VerifyMFAPasskeyParam) (*LoginResponse, error)
+	BeginPasskeyLogin(ctx context.Context) (*passkey.BeginResponse, error)
+	LoginWithPasskey(ctx context.Context, param PasskeyLoginParam) (*LoginResponse, error)
+	SocialAuthorizationURL(ctx context.Context, provider string, param social.AuthorizeParam) (*social.Authorization, error)
+	ProviderCallback(ctx context.Context, provider string, param social.CallbackParam) (*ProviderCallbackResult, error)
+
+	// Reauthenticate confirms the password or an authenticator code, so the
+	// session may make changes that require a recent login.
+	Reauthenticate(ctx context.Context, userID, sessionID uuid.UUID, param ReauthenticateParam) error
+	// DisableTOTP removes the user's authenticator. Wrong codes count towards
+	// the lockout.
+	DisableTOTP(ctx context.Context, userID uuid.UUID, code string) error
+	ListAuthMethods(ctx context.Context, userID uuid.UUID) ([]authmethod.AuthMethod, error)
+	// LinkPassword adds a password to an account that signs in with providers.
+	LinkPassword(ctx context.Context, userID uuid.UUID, password string) error
+	// LinkProviderURL returns the provider URL to send the user to. The
+	// provider is linked in the callback.
+	LinkProviderURL(ctx context.Context, userID uuid.UUID, provider string) (*social.Authorization, error)
+	// LinkPendingIdentity links the provider account that could not sign in
+	// because its email belongs to the user.
+	LinkPendingIdentity(ctx context.Context, userID uuid.UUID, linkToken string) (*authmethod.AuthMethod, error)
+	UnlinkAuthMethod(ctx context.Context, userID, id uuid.UUID) error
+
 	RefreshToken(ctx context.Context, refreshToken string) (*Tokens, error)
+	Logout(ctx context.Context, userID, sessionID uuid.UUID) error
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
+	LockUser(ctx context.Context, adminID, userID uuid.UUID, until *time.Time) error
+	UnlockUser(ctx context.Context, adminID, userID uuid.UUID) error
+
+	// UpdateProfile changes the user's own username.
+	UpdateProfile(ctx context.Context, userID uuid.UUID, param UpdateProfileParam) (*userModel.Response, error)
+	// CloseAccount deletes the user's own account once they confirm their
+	// password, or re-authenticated recently if they have none.
+	CloseAccount(ctx context.Context, userID, sessionID uuid.UUID, param CloseAccountParam) error
+
+	ListUsers(ctx context.Context, param ListUsersParam) (*UserList, error)
+	GetUser(ctx context.Context, id uuid.UUID) (*userModel.Response, error)
+	// The methods below refuse to act on a user who holds a permission the
+	// administrator lacks.
+	UpdateUser(ctx context.Context, adminID, id uuid.UUID, param UpdateUserParam) (*userModel.Response, error)
+	// SetUserActive enables or disables an account. Disabling it ends its
+	// sessions. Administrators cannot disable themselves.
+	SetUserActive(ctx context.Context, adminID, id uuid.UUID, active bool) error
+	// ForcePasswordReset ends the user's sessions and mails them a reset
+	// link. Their password stops working until they use it.
+	ForcePasswordReset(ctx context.Context, adminID, id uuid.UUID) error
+	// DeleteUser deletes an account and ends its sessions. Administrators
+	// cannot delete themselves.
+	DeleteUser(ctx context.Context, adminID, id uuid.UUID) error
 }
 
 type service struct {
-	logger  *zap.Logger
-	userSvc userModel.Service
+	logger               *zap.Logger
+	userSvc              userModel.Service
+	sessionSvc           session.Service
+	securityLogSvc       securitylog.Service
+	verificationSvc      verification.Service
+	mfaSvc               mfa.Service
+	passkeySvc           passkey.Service
+	reauth               *reauth.Store
+	socialSvc            social.Service
+	authMethodSvc        authmethod.Service
+	roleSvc              role.Service
+	mailer               mailer.Mailer
+	lockout              *lockoutPolicy
+	resetTokens          *resetTokenStore
+	resetURL             string
+	allowUnverifiedLogin bool
+	tokenAuthz           *tokenAuthzPolicy
 }
 
-func NewService(userSvc userModel.Service, logger *zap.Logger) Service {
-	return &service{userSvc: userSvc, logger: logger}
+func NewService(cfg *config.Config, userSvc userModel.Service, sessionSvc session.Service, securityLogSvc securitylog.Service, verificationSvc verification.Service, mfaSvc mfa.Service, passkeySvc passkey.Service, socialSvc social.Service, authMethodSvc authmethod.Service, roleSvc role.Service, reauthStore *reauth.Store, mailer mailer.Mailer, logger *zap.Logger) Service {
+	return &service{
+		userSvc:              userSvc,
+		sessionSvc:           sessionSvc,
+		securityLogSvc:       securityLogSvc,
+		verificationSvc:      verificationSvc,
+		mfaSvc:               mfaSvc,
+		passkeySvc:           passkeySvc,
+		reauth:               reauthStore,
+		socialSvc:            socialSvc,
+		authMethodSvc:        authMethodSvc,
+		roleSvc:              roleSvc,
+		mailer:               mailer,
+		lockout:              newLockoutPolicy(cfg),
+		resetTokens:          newResetTokenStore(cfg),
+		resetURL:             cfg.PasswordReset.URL,
+		allowUnverifiedLogin: cfg.Verification.AllowUnverifiedLogin,
+		tokenAuthz:           newTokenAuthzPolicy(cfg),
+		logger:               logger,
+	}
 }
 
 func (s *service) Register(ctx context.Context, param RegisterParam) error {
@@ -42,49 +145,538 @@ func (s *service) Register(ctx context.Context, param RegisterParam) error {
 		Password: param.Password,
 	}
 
-	err = s.userSvc.CreateUser(ctx, user)
+	created, err := s.userSvc.CreateUser(ctx, user)
 	if err != nil {
 		return err
 	}
 
-	// TODO: Verify email (OTP)
+	// The account exists either way; the user can ask for a new code
+	err = s.verificationSvc.SendEmailVerification(ctx, created)
+	if err != nil {
+		s.logger.Warn("failed to send verification email", zap.String("user_id", created.ID.String()), zap.Error(err))
+	}
 
 	return nil
 }
 
-func (s *service) Login(ctx context.Context, email, password string) (*LoginResponse, error) {
+func (s *service) Login(ctx context.Context, param LoginParam) (*LoginResponse, error) {
 	const op = "service.Login"
 
+	user, err := s.authenticatePassword(ctx, op, param.Email, param.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeFirstFactor(ctx, op, user, param.DeviceName, []string{amrPassword})
+}
+
+// completeFirstFactor opens a session, or asks for a second factor when the
+// user has one. amr lists how the first factor was passed.
+func (s *service) completeFirstFactor(ctx context.Context, op string, user *userModel.User, deviceName *string, amr []string) (*LoginResponse, error) {
+	methods, err := s.mfaMethods(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	} else if len(methods) > 0 {
+		// Failed attempts keep counting until the second factor passes
+		mfaToken, err := token.GenerateMFAToken(user.ID, deviceName, amr)
+		if err != nil {
+			return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+		return &LoginResponse{MFARequired: true, MFAToken: mfaToken, MFAMethods: methods}, nil
+	}
+
+	s.resetLoginFailures(ctx, user.ID)
+
+	return s.issueTokens(ctx, user, deviceName, amr)
+}
+
+// mfaMethods lists the second factors the user can complete a login with.
+func (s *service) mfaMethods(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	var methods []string
+
+	totpEnabled, err := s.mfaSvc.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if totpEnabled {
+		methods = append(methods, mfaMethodTOTP)
+	}
+
+	hasPasskey, err := s.passkeySvc.HasCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if hasPasskey {
+		methods = append(methods, mfaMethodWebAuthn)
+	}
+
+	return methods, nil
+}
+
+// authenticatePassword is the first login step shared by every login path.
+func (s *service) authenticatePassword(ctx context.Context, op, email, password string) (*userModel.User, error) {
 	user, err := s.userSvc.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
 
+	if isAccountLocked(user) {
+		s.recordLoginFailure(ctx, user.ID, "account_locked")
+		return nil, autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	// Users who signed up with a provider have no password
+	if user.PasswordHash == nil {
+		return nil, s.handleFailedLogin(ctx, op, user.ID, consts.PasswordField)
+	}
+
 	isValid, err := isPasswordMatch(*user.PasswordHash, password)
 	if err != nil {
 		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 	} else if !isValid {
-		return nil, apperrors.ErrIncorrectX.WithField(consts.PasswordField).WithOp(op)
+		return nil, s.handleFailedLogin(ctx, op, user.ID, consts.PasswordField)
+	}
+
+	if !user.IsActive {
+		return nil, autherrors.ErrAccountDisabled.WithOp(op)
+	} else if user.PasswordResetRequired {
+		// Only said after the password matched, so it reveals nothing new
+		return nil, autherrors.ErrPasswordResetRequired.WithOp(op)
+	}
+
+	if !user.EmailVerified && !s.allowUnverifiedLogin {
+		return nil, autherrors.ErrEmailNotVerified.WithOp(op)
+	}
+
+	return user, nil
+}
+
+// LoginWithRecoveryCode logs in with the password and a recovery code in
+// place of the second factor, for users who lost their authenticator.
+func (s *service) LoginWithRecoveryCode(ctx context.Context, param RecoveryLoginParam) (*LoginResponse, error) {
+	const op = "service.LoginWithRecoveryCode"
+
+	user, err := s.authenticatePassword(ctx, op, param.Email, param.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mfaSvc.UseRecoveryCode(ctx, user.ID, param.RecoveryCode)
+	if apperrors.Is(err, apperrors.ErrInvalidX) {
+		return nil, s.handleFailedLogin(ctx, op, user.ID, authconsts.RecoveryCodeField)
+	} else if err != nil {
+		return nil, err
+	}
+
+	s.resetLoginFailures(ctx, user.ID)
+
+	return s.issueTokens(ctx, user, param.DeviceName, []string{amrPassword, amrOTP, amrMFA})
+}
+
+// VerifyMFA is the second login step: it exchanges an MFA token from Login and
+// a code from the user's authenticator for a session.
+func (s *service) VerifyMFA(ctx context.Context, param VerifyMFAParam) (*LoginResponse, error) {
+	const op = "service.VerifyMFA"
+
+	return s.completeMFA(ctx, op, param.MFAToken, amrOTP, authconsts.CodeField, func(userID uuid.UUID) error {
+		return s.mfaSvc.Verify(ctx, userID, param.Code)
+	})
+}
+
+// BeginMFAPasskey starts an assertion with one of the user's passkeys or
+// security keys, as the second login step.
+func (s *service) BeginMFAPasskey(ctx context.Context, mfaToken string) (*passkey.BeginResponse, error) {
+	const op = "service.BeginMFAPasskey"
+
+	claims, err := s.parseMFAToken(ctx, op, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := token.IsMFATokenClaimed(ctx, claims)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if used {
+		return nil, apperrors.ErrSessionExpired.WithOp(op)
+	}
+
+	user, err := s.pendingMFAUser(ctx, op, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.passkeySvc.BeginMFA(ctx, user.ID)
+}
+
+func (s *service) VerifyMFAPasskey(ctx context.Context, param VerifyMFAPasskeyParam) (*LoginResponse, error) {
+	const op = "service.VerifyMFAPasskey"
+
+	return s.completeMFA(ctx, op, param.MFAToken, amrHardwareKey, authconsts.PasskeyField, func(userID uuid.UUID) error {
+		return s.passkeySvc.FinishMFA(ctx, userID, param.FinishParam)
+	})
+}
+
+// parseMFAToken returns the claims of an MFA token that has not expired and
+// was issued after the user last logged out everywhere.
+func (s *service) parseMFAToken(ctx context.Context, op, mfaToken string) (*token.MFATokenClaims, error) {
+	claims, err := token.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, apperrors.ErrSessionExpired.WithOp(op).Wrap(err)
+	}
+
+	revoked, err := token.IsRevokedForUser(ctx, claims.UserID, claims.IssuedAt)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if revoked {
+		return nil, apperrors.ErrSessionExpired.WithOp(op)
+	}
+
+	return claims, nil
+}
+
+// pendingMFAUser returns the user an MFA token was issued to, unless they
+// can no longer log in.
+func (s *service) pendingMFAUser(ctx context.Context, op string, claims *token.MFATokenClaims) (*userModel.User, error) {
+	user, err := s.userSvc.GetUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if isAccountLocked(user) {
+		s.recordLoginFailure(ctx, user.ID, "account_locked")
+		return nil, autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	return user, nil
+}
+
+// completeMFA checks the second factor with verify and opens the session.
+// The MFA token is claimed before the factor is checked, so concurrent
+// requests with the same token cannot each open a session. A failed factor
+// hands the token back for another try and counts towards the lockout.
+func (s *service) completeMFA(ctx context.Context, op, mfaToken, factor string, field consts.Field, verify func(userID uuid.UUID) error) (*LoginResponse, error) {
+	claims, err := s.parseMFAToken(ctx, op, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed, err := token.ClaimMFAToken(ctx, claims)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if !claimed {
+		return nil, apperrors.ErrSessionExpired.WithOp(op)
+	}
+
+	user, err := s.pendingMFAUser(ctx, op, claims)
+	if err != nil {
+		s.releaseMFAToken(ctx, claims)
+		return nil, err
+	}
+
+	err = verify(user.ID)
+	if err != nil {
+		s.releaseMFAToken(ctx, claims)
+		if apperrors.Is(err, apperrors.ErrInvalidX) {
+			return nil, s.handleFailedLogin(ctx, op, user.ID, field)
+		}
+		return nil, err
+	}
+
+	s.resetLoginFailures(ctx, user.ID)
+
+	amr := append(claims.AMR, factor, amrMFA)
+
+	return s.issueTokens(ctx, user, claims.DeviceName, amr)
+}
+
+// releaseMFAToken hands the MFA token back after a failed attempt.
+func (s *service) releaseMFAToken(ctx context.Context, claims *token.MFATokenClaims) {
+	err := token.ReleaseMFAToken(ctx, claims)
+	if err != nil {
+		s.logger.Error("Release MFA token failed", zap.String("user_id", claims.UserID.String()), zap.Error(err))
+	}
+}
+
+func (s *service) BeginPasskeyLogin(ctx context.Context) (*passkey.BeginResponse, error) {
+	return s.passkeySvc.BeginLogin(ctx)
+}
+
+// LoginWithPasskey logs in with a discoverable passkey alone. The
+// authenticator verified the user, so no password or second factor is asked.
+func (s *service) LoginWithPasskey(ctx context.Context, param PasskeyLoginParam) (*LoginResponse, error) {
+	const op = "service.LoginWithPasskey"
+
+	userID, err := s.passkeySvc.FinishLogin(ctx, param.FinishParam)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if isAccountLocked(user) {
+		s.recordLoginFailure(ctx, user.ID, "account_locked")
+		return nil, autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	if !user.EmailVerified && !s.allowUnverifiedLogin {
+		return nil, autherrors.ErrEmailNotVerified.WithOp(op)
+	}
+
+	s.resetLoginFailures(ctx, user.ID)
+
+	return s.issueTokens(ctx, user, param.DeviceName, []string{amrHardwareKey})
+}
+
+func (s *service) SocialAuthorizationURL(ctx context.Context, provider string, param social.AuthorizeParam) (*social.Authorization, error) {
+	return s.socialSvc.AuthorizationURL(ctx, provider, param)
+}
+
+// ProviderCallback completes a sign-in at a provider. It logs the user in,
+// offers linking when the provider's email belongs to an existing account, or
+// links the provider for a user who started at /auth/methods/social.
+func (s *service) ProviderCallback(ctx context.Context, provider string, param social.CallbackParam) (*ProviderCallbackResult, error) {
+	const op = "service.ProviderCallback"
+
+	identity, err := s.socialSvc.Callback(ctx, provider, param)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.LinkUserID != nil {
+		method, err := s.linkIdentity(ctx, *identity.LinkUserID, identity)
+		if err != nil {
+			return nil, err
+		}
+		return &ProviderCallbackResult{Linked: method}, nil
+	}
+
+	resp, err := s.loginWithIdentity(ctx, op, identity)
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderCallbackResult{Login: resp}, nil
+}
+
+// loginWithIdentity finds the user by their ID at the provider, or creates
+// them on first sign-in.
+func (s *service) loginWithIdentity(ctx context.Context, op string, identity *social.Identity) (*LoginResponse, error) {
+	method, err := s.authMethodSvc.GetByProvider(ctx, identity.MethodType, identity.ProviderID)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		return s.signUpWithIdentity(ctx, op, identity)
+	} else if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userSvc.GetUser(ctx, method.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loginSocialUser(ctx, op, user, identity.DeviceName)
+}
+
+func (s *service) signUpWithIdentity(ctx context.Context, op string, identity *social.Identity) (*LoginResponse, error) {
+	if identity.Email == "" {
+		return nil, apperrors.ErrInvalidX.WithField(consts.EmailField).WithOp(op)
+	}
+
+	// A provider asserting the email of an existing account must not sign in
+	// to it. The owner can link the provider after logging in.
+	_, err := s.userSvc.GetUserByEmail(ctx, identity.Email)
+	if err == nil {
+		linkToken, err := s.socialSvc.SavePendingLink(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{LinkRequired: true, LinkToken: linkToken}, nil
+	} else if !apperrors.Is(err, apperrors.ErrXNotFound) {
+		return nil, err
+	}
+
+	user, err := s.userSvc.CreateExternalUser(ctx, &userModel.CreateExternalUserParam{
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		MethodType:    identity.MethodType,
+		ProviderID:    identity.ProviderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loginSocialUser(ctx, op, user, identity.DeviceName)
+}
+
+func (s *service) loginSocialUser(ctx context.Context, op string, user *userModel.User, deviceName *string) (*LoginResponse, error) {
+	if isAccountLocked(user) {
+		s.recordLoginFailure(ctx, user.ID, "account_locked")
+		return nil, autherrors.ErrAccountLocked.WithOp(op)
+	}
+
+	if !user.EmailVerified && !s.allowUnverifiedLogin {
+		return nil, autherrors.ErrEmailNotVerified.WithOp(op)
+	}
+
+	return s.completeFirstFactor(ctx, op, user, deviceName, []string{amrFederated})
+}
+
+func (s *service) resetLoginFailures(ctx context.Context, userID uuid.UUID) {
+	err := s.lockout.reset(ctx, userID)
+	if err != nil {
+		s.logger.Warn("failed to reset login failures", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+}
+
+// issueTokens opens a new session for an authenticated user. amr lists how
+// the user authenticated, as RFC 8176 values.
+func (s *service) issueTokens(ctx context.Context, user *userModel.User, deviceName *string, amr []string) (*LoginResponse, error) {
+	const op = "service.issueTokens"
+
+	// Every login path ends here, whatever the first factor was. Passkey and
+	// provider logins must not get around a required password reset either.
+	if !user.IsActive {
+		return nil, autherrors.ErrAccountDisabled.WithOp(op)
+	} else if user.PasswordResetRequired {
+		return nil, autherrors.ErrPasswordResetRequired.WithOp(op)
 	}
 
-	accessToken, err := token.GenerateAccessToken(user.ID, user.Email, user.Username)
+	refreshToken, familyID, err := token.NewRefreshTokenFamily(ctx, user.ID)
 	if err != nil {
 		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 	}
 
-	refreshToken, err := token.GenerateRefreshToken(user.ID)
+	// The refresh token family is the session
+	sessionID, err := uuid.Parse(familyID)
 	if err != nil {
 		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 	}
 
+	err = s.sessionSvc.CreateSession(ctx, user.ID, sessionID, deviceName, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logging in is a fresh authentication
+	err = s.reauth.Mark(ctx, sessionID)
+	if err != nil {
+		s.logger.Warn("failed to mark session as recently authenticated", zap.String("session_id", familyID), zap.Error(err))
+	}
+
+	authz, err := s.tokenAuthorization(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := token.GenerateAccessToken(user.ID, user.Username, user.Email, familyID, authz)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	err = s.userSvc.RecordLogin(ctx, user.ID)
+	if err != nil {
+		s.logger.Warn("failed to record last login", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &user.ID,
+		Action:   securitylog.ActionLogin,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"session_id": familyID},
+	})
+
 	return &LoginResponse{
-		Tokens{
+		Tokens: Tokens{
 			AccessToken:  accessToken,
 			RefreshToken: refreshToken,
-		}, UserClaims{UserID: user.ID, Email: user.Email, Username: user.Username},
+		},
+		User: &UserClaims{UserID: user.ID, Email: user.Email, Username: user.Username},
 	}, nil
 }
 
+// handleFailedLogin counts a wrong password or MFA code and locks the account
+// once the lockout threshold is reached.
+func (s *service) handleFailedLogin(ctx context.Context, op string, userID uuid.UUID, field consts.Field) error {
+	lockedUntil, err := s.lockout.recordFailure(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to record login failure", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+
+	if lockedUntil == nil {
+		s.recordLoginFailure(ctx, userID, "incorrect_"+string(field))
+		return apperrors.ErrIncorrectX.WithField(field).WithOp(op)
+	}
+
+	err = s.userSvc.SetAccountLockedUntil(ctx, userID, lockedUntil)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Warn("Account locked after repeated failed logins",
+		zap.String("user_id", userID.String()),
+		zap.Time("locked_until", *lockedUntil))
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID: &userID,
+		Action: securitylog.ActionLoginFailed,
+		Status: securitylog.StatusFailed,
+		Metadata: securitylog.Metadata{
+			"reason":       "account_locked",
+			"locked_until": lockedUntil,
+		},
+	})
+
+	return autherrors.ErrAccountLocked.WithOp(op)
+}
+
+func (s *service) recordLoginFailure(ctx context.Context, userID uuid.UUID, reason string) {
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionLoginFailed,
+		Status:   securitylog.StatusFailed,
+		Metadata: securitylog.Metadata{"reason": reason},
+	})
+}
+
+func (s *service) LockUser(ctx context.Context, adminID, userID uuid.UUID, until *time.Time) error {
+	const op = "service.LockUser"
+
+	err := s.checkCanManage(ctx, op, adminID, userID)
+	if err != nil {
+		return err
+	}
+
+	lockedUntil := permanentLock
+	if until != nil {
+		lockedUntil = until.UTC()
+	}
+
+	err = s.userSvc.SetAccountLockedUntil(ctx, userID, &lockedUntil)
+	if err != nil {
+		return err
+	}
+
+	return s.revokeAllSessions(ctx, op, userID)
+}
+
+func (s *service) UnlockUser(ctx context.Context, adminID, userID uuid.UUID) error {
+	const op = "service.UnlockUser"
+
+	err := s.checkCanManage(ctx, op, adminID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.userSvc.SetAccountLockedUntil(ctx, userID, nil)
+	if err != nil {
+		return err
+	}
+
+	err = s.lockout.reset(ctx, userID)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	return nil
+}
+
 func (s *service) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
 	const op = "service.ChangePassword"
 
@@ -93,6 +685,10 @@ func (s *service) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassw
 		return err
 	}
 
+	if user.PasswordHash == nil {
+		return apperrors.ErrIncorrectX.WithField(consts.PasswordField).WithOp(op)
+	}
+
 	isValid, err := isPasswordMatch(*user.PasswordHash, oldPassword)
 	if err != nil {
 		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
@@ -104,7 +700,130 @@ func (s *service) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassw
 		Password: &newPassword,
 	}
 
-	return s.userSvc.UpdateUser(ctx, userID, param)
+	err = s.userSvc.UpdateUser(ctx, userID, param)
+	if err != nil {
+		return err
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID: &userID,
+		Action: securitylog.ActionPasswordChange,
+		Status: securitylog.StatusSuccess,
+	})
+
+	// Sessions opened with the old password must not survive the change
+	return s.revokeAllSessions(ctx, op, userID)
+}
+
+// ForgotPassword mails a password reset link. It succeeds silently for
+// unknown addresses so it cannot be used to find out which emails are
+// registered.
+func (s *service) ForgotPassword(ctx context.Context, email string) error {
+	const op = "service.ForgotPassword"
+
+	user, err := s.userSvc.GetUserByEmail(ctx, email)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Answering only once the mail is out would tell registered emails from
+	// unknown ones, so the rest happens in the background
+	go s.requestPasswordReset(context.WithoutCancel(ctx), user)
+	return nil
+}
+
+// requestPasswordReset sends the reset link unless the user asked for one
+// within the cooldown. Failures are only logged: the caller has already been
+// answered.
+func (s *service) requestPasswordReset(ctx context.Context, user *userModel.User) {
+	const op = "service.requestPasswordReset"
+
+	ctx, cancel := context.WithTimeout(ctx, resetMailTimeout)
+	defer cancel()
+
+	allowed, err := s.resetTokens.allowRequest(ctx, user.ID)
+	if err != nil {
+		s.logger.Error("Password reset request error", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return
+	} else if !allowed {
+		s.logger.Debug("Password reset requested within cooldown", zap.String("user_id", user.ID.String()))
+		return
+	}
+
+	err = s.sendPasswordReset(ctx, op, user)
+	if err != nil {
+		s.logger.Error("Password reset request error", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+}
+
+// sendPasswordReset mails the user a new password reset link.
+func (s *service) sendPasswordReset(ctx context.Context, op string, user *userModel.User) error {
+	resetToken, err := s.resetTokens.issue(ctx, user.ID)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	msg, err := mailer.Render(mailer.TemplatePasswordReset, struct {
+		Link             string
+		ExpiresInMinutes int
+	}{s.passwordResetLink(resetToken), int(s.resetTokens.ttl.Minutes())}, clientinfo.FromContext(ctx).Languages...)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	msg.To = []string{*user.Email}
+
+	err = s.mailer.Send(ctx, msg)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return nil
+}
+
+func (s *service) passwordResetLink(resetToken string) string {
+	link, err := url.Parse(s.resetURL)
+	if s.resetURL == "" || err != nil {
+		return resetToken
+	}
+
+	query := link.Query()
+	query.Set("token", resetToken)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+func (s *service) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	const op = "service.ResetPassword"
+
+	userID, err := s.resetTokens.consume(ctx, resetToken)
+	if errors.Is(err, errInvalidResetToken) {
+		return apperrors.ErrInvalidX.WithField(authconsts.TokenField).WithOp(op)
+	} else if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	err = s.userSvc.UpdateUser(ctx, userID, &userModel.UpdateUserParam{
+		Password: &newPassword,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.lockout.reset(ctx, userID)
+	if err != nil {
+		s.logger.Warn("failed to reset login failures", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID: &userID,
+		Action: securitylog.ActionPasswordReset,
+		Status: securitylog.StatusSuccess,
+	})
+
+	// Whoever knew the old password must lose access
+	return s.revokeAllSessions(ctx, op, userID)
 }
 
 func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*Tokens, error) {
@@ -120,17 +839,69 @@ func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*Token
 		return nil, err
 	}
 
-	err = token.InvalidateToken(ctx, refreshToken)
+	if user.PasswordChangedAt != nil && token.IssuedNotAfter(claims.IssuedAt, *user.PasswordChangedAt) {
+		return nil, apperrors.ErrSessionExpired.WithOp(op)
+	} else if !user.IsActive {
+		return nil, apperrors.ErrSessionExpired.WithOp(op)
+	}
+
+	revoked, err := token.IsRevokedForUser(ctx, user.ID, claims.IssuedAt)
 	if err != nil {
-		s.logger.Warn("failed to blacklist old refresh token", zap.Error(err))
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if revoked {
+		return nil, apperrors.ErrSessionExpired.WithOp(op)
 	}
 
-	accessToken, err := token.GenerateAccessToken(user.ID, user.Email, user.Username)
+	sessionID, err := uuid.Parse(claims.FamilyID)
 	if err != nil {
+		return nil, apperrors.ErrSessionExpired.WithOp(op).Wrap(err)
+	}
+
+	newRefreshToken, err := token.RotateRefreshToken(ctx, claims)
+	if errors.Is(err, token.ErrRefreshTokenReused) {
+		s.logger.Warn("Refresh token reuse detected, family revoked",
+			zap.String("user_id", claims.UserID.String()),
+			zap.String("family_id", claims.FamilyID))
+		// The session must not outlive its tokens in the session list
+		if err := s.sessionSvc.RevokeSession(ctx, claims.UserID, sessionID); err != nil && !apperrors.Is(err, apperrors.ErrXNotFound) {
+			s.logger.Error("Revoke session after token reuse failed", zap.String("session_id", sessionID.String()), zap.Error(err))
+		}
+		s.securityLogSvc.Record(ctx, securitylog.Event{
+			UserID: &claims.UserID,
+			Action: securitylog.ActionTokenReuse,
+			Status: securitylog.StatusRevoked,
+			Metadata: securitylog.Metadata{
+				"family_id": claims.FamilyID,
+				"jti":       claims.ID,
+			},
+		})
+		return nil, apperrors.ErrSessionExpired.WithOp(op).Wrap(err)
+	} else if errors.Is(err, token.ErrRefreshTokenRevoked) {
+		return nil, apperrors.ErrSessionExpired.WithOp(op).Wrap(err)
+	} else if err != nil {
 		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 	}
 
-	newRefreshToken, err := token.GenerateRefreshToken(user.ID)
+	// Only a refresh that rotated keeps the session alive. A session revoked
+	// in storage but not in the token family is still revoked.
+	err = s.sessionSvc.TouchSession(ctx, sessionID)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		if err := token.RevokeRefreshTokenFamily(ctx, claims.FamilyID); err != nil {
+			s.logger.Error("Revoke token family of a revoked session failed", zap.String("family_id", claims.FamilyID), zap.Error(err))
+		}
+		return nil, apperrors.ErrSessionExpired.WithOp(op).Wrap(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// New permissions arrive with the next refresh. Losing one revokes the
+	// user's tokens instead, so it cannot outlive the change
+	authz, err := s.tokenAuthorization(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := token.GenerateAccessToken(user.ID, user.Username, user.Email, claims.FamilyID, authz)
 	if err != nil {
 		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 	}
@@ -140,3 +911,48 @@ func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*Token
 		RefreshToken: newRefreshToken,
 	}, nil
 }
+
+func (s *service) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	const op = "service.LogoutAll"
+
+	err := s.revokeAllSessions(ctx, op, userID)
+	if err != nil {
+		return err
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionLogout,
+		Status:   securitylog.StatusRevoked,
+		Metadata: securitylog.Metadata{"scope": "all"},
+	})
+
+	return nil
+}
+
+func (s *service) Logout(ctx context.Context, userID, sessionID uuid.UUID) error {
+	err := s.sessionSvc.RevokeSession(ctx, userID, sessionID)
+	if err != nil && !apperrors.Is(err, apperrors.ErrXNotFound) {
+		return err
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionLogout,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"session_id": sessionID.String()},
+	})
+
+	return nil
+}
+
+// revokeAllSessions ends every session of the user, including access tokens
+// that are not bound to a session.
+func (s *service) revokeAllSessions(ctx context.Context, op string, userID uuid.UUID) error {
+	err := token.RevokeUserTokens(ctx, userID, time.Now())
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return s.sessionSvc.RevokeAllSessions(ctx, userID)
+}
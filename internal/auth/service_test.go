@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/mfa"
+	"auth-service/internal/passkey"
+	"auth-service/internal/reauth"
+	"auth-service/internal/role"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/session"
+	autherrors "auth-service/internal/shared/errors"
+	"auth-service/internal/testutil"
+	userModel "auth-service/internal/user"
+	token "auth-service/pkg/jwt"
+	"context"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET_KEY", "test-secret-key-of-at-least-32-bytes")
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+// fakeUserService keeps users in memory; other methods are not used.
+type fakeUserService struct {
+	userModel.Service
+	mu    sync.Mutex
+	users map[uuid.UUID]*userModel.User
+}
+
+func (s *fakeUserService) GetUser(_ context.Context, id uuid.UUID) (*userModel.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, apperrors.ErrXNotFound.Wrap(gorm.ErrRecordNotFound)
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeUserService) SetAccountLockedUntil(_ context.Context, id uuid.UUID, until *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[id].AccountLockedUntil = until
+	return nil
+}
+
+func (s *fakeUserService) RecordLogin(context.Context, uuid.UUID) error {
+	return nil
+}
+
+func (s *fakeUserService) UpdateUser(_ context.Context, id uuid.UUID, param *userModel.UpdateUserParam) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if param.Username != nil {
+		s.users[id].Username = param.Username
+	}
+	if param.Password != nil {
+		// Kept as given, the real service hashes it
+		s.users[id].PasswordHash = param.Password
+	}
+	return nil
+}
+
+func (s *fakeUserService) DeleteUser(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, id)
+	return nil
+}
+
+// fakeSessionService counts the sessions it opens.
+type fakeSessionService struct {
+	session.Service
+	mu       sync.Mutex
+	sessions map[uuid.UUID][]uuid.UUID
+}
+
+func (s *fakeSessionService) CreateSession(_ context.Context, userID, sessionID uuid.UUID, _ *string, _ []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[userID] = append(s.sessions[userID], sessionID)
+	return nil
+}
+
+func (s *fakeSessionService) RevokeAllSessions(_ context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, userID)
+	return nil
+}
+
+func (s *fakeSessionService) count(userID uuid.UUID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions[userID])
+}
+
+// fakeMFAService accepts one code. Unlike the real service, it accepts it
+// again, so only the MFA token stops replays.
+type fakeMFAService struct {
+	mfa.Service
+}
+
+const validCode = "123456"
+
+func (fakeMFAService) Verify(_ context.Context, _ uuid.UUID, code string) error {
+	if code != validCode {
+		return apperrors.ErrInvalidX
+	}
+	return nil
+}
+
+func (f fakeMFAService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	return f.Verify(ctx, userID, code)
+}
+
+// fakePasskeyService signs in userID with any passkey.
+type fakePasskeyService struct {
+	passkey.Service
+	userID uuid.UUID
+}
+
+func (f fakePasskeyService) FinishLogin(context.Context, passkey.FinishParam) (uuid.UUID, error) {
+	return f.userID, nil
+}
+
+type fakeSecurityLog struct {
+	mu     sync.Mutex
+	events []securitylog.Event
+}
+
+func (l *fakeSecurityLog) Record(_ context.Context, event securitylog.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *fakeSecurityLog) has(action securitylog.Action) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, event := range l.events {
+		if event.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// newLoginService returns a service with an active, verified user.
+func newLoginService(t *testing.T) (*service, *userModel.User) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.JWT.AccessDuration = time.Hour
+	cfg.JWT.RefreshDuration = 24 * time.Hour
+	cfg.Lockout.MaxAttempts = 5
+	cfg.Lockout.AttemptWindow = time.Minute
+	cfg.Lockout.BaseDuration = time.Minute
+	cfg.Lockout.MaxDuration = time.Hour
+	if err := token.Init(cfg); err != nil {
+		t.Fatalf("token.Init: %v", err)
+	}
+
+	email := "ada@example.com"
+	user := &userModel.User{ID: uuid.New(), Email: &email, EmailVerified: true, IsActive: true}
+
+	s := NewService(cfg,
+		&fakeUserService{users: map[uuid.UUID]*userModel.User{user.ID: user}},
+		&fakeSessionService{sessions: map[uuid.UUID][]uuid.UUID{}},
+		&fakeSecurityLog{},
+		nil,
+		fakeMFAService{},
+		nil,
+		nil,
+		nil,
+		&fakeRoleService{authz: map[uuid.UUID]*role.Authorization{}},
+		reauth.NewStore(cfg),
+		nil,
+		zap.NewNop(),
+	)
+	return s.(*service), user
+}
+
+func mfaToken(t *testing.T, userID uuid.UUID) string {
+	t.Helper()
+	mfaToken, err := token.GenerateMFAToken(userID, nil, []string{amrPassword})
+	if err != nil {
+		t.Fatalf("GenerateMFAToken: %v", err)
+	}
+	return mfaToken
+}
+
+func TestVerifyMFA_TokenIsSingleUse(t *testing.T) {
+	s, user := newLoginService(t)
+	ctx := context.Background()
+	param := VerifyMFAParam{MFAToken: mfaToken(t, user.ID), Code: validCode}
+
+	if _, err := s.VerifyMFA(ctx, param); err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if _, err := s.VerifyMFA(ctx, param); !apperrors.Is(err, apperrors.ErrSessionExpired) {
+		t.Errorf("reused token: err = %v, want session expired", err)
+	}
+}
+
+func TestVerifyMFA_TokenClaimedByAnotherRequest(t *testing.T) {
+	s, user := newLoginService(t)
+	ctx := context.Background()
+	mfaToken := mfaToken(t, user.ID)
+
+	// As if a concurrent request were still checking its code
+	claims, err := token.ParseMFAToken(mfaToken)
+	if err != nil {
+		t.Fatalf("ParseMFAToken: %v", err)
+	}
+	if claimed, err := token.ClaimMFAToken(ctx, claims); err != nil || !claimed {
+		t.Fatalf("ClaimMFAToken = %v, %v", claimed, err)
+	}
+
+	_, err = s.VerifyMFA(ctx, VerifyMFAParam{MFAToken: mfaToken, Code: validCode})
+	if !apperrors.Is(err, apperrors.ErrSessionExpired) {
+		t.Errorf("err = %v, want session expired", err)
+	}
+	if n := s.sessionSvc.(*fakeSessionService).count(user.ID); n != 0 {
+		t.Errorf("%d sessions opened, want none", n)
+	}
+}
+
+func TestVerifyMFA_WrongCodeKeepsToken(t *testing.T) {
+	s, user := newLoginService(t)
+	ctx := context.Background()
+	mfaToken := mfaToken(t, user.ID)
+
+	_, err := s.VerifyMFA(ctx, VerifyMFAParam{MFAToken: mfaToken, Code: "000000"})
+	if !apperrors.Is(err, apperrors.ErrIncorrectX) {
+		t.Fatalf("err = %v, want incorrect code", err)
+	}
+	if !s.securityLogSvc.(*fakeSecurityLog).has(securitylog.ActionLoginFailed) {
+		t.Error("failed code not recorded")
+	}
+
+	// A typo does not send the user back to the password step
+	if _, err := s.VerifyMFA(ctx, VerifyMFAParam{MFAToken: mfaToken, Code: validCode}); err != nil {
+		t.Errorf("VerifyMFA after a wrong code: %v", err)
+	}
+}
+
+func TestVerifyMFA_LogoutAllCancelsPendingLogin(t *testing.T) {
+	s, user := newLoginService(t)
+	ctx := context.Background()
+	mfaToken := mfaToken(t, user.ID)
+
+	if err := s.LogoutAll(ctx, user.ID); err != nil {
+		t.Fatalf("LogoutAll: %v", err)
+	}
+
+	_, err := s.VerifyMFA(ctx, VerifyMFAParam{MFAToken: mfaToken, Code: validCode})
+	if !apperrors.Is(err, apperrors.ErrSessionExpired) {
+		t.Errorf("err = %v, want session expired", err)
+	}
+	if _, err := s.BeginMFAPasskey(ctx, mfaToken); !apperrors.Is(err, apperrors.ErrSessionExpired) {
+		t.Errorf("BeginMFAPasskey: err = %v, want session expired", err)
+	}
+}
+
+func TestLoginWithoutPassword_PasswordResetRequired(t *testing.T) {
+	tests := []struct {
+		name  string
+		login func(s *service, userID uuid.UUID) (*LoginResponse, error)
+	}{
+		{"passkey", func(s *service, userID uuid.UUID) (*LoginResponse, error) {
+			s.passkeySvc = fakePasskeyService{userID: userID}
+			return s.LoginWithPasskey(context.Background(), PasskeyLoginParam{})
+		}},
+		{"second factor", func(s *service, userID uuid.UUID) (*LoginResponse, error) {
+			return s.VerifyMFA(context.Background(), VerifyMFAParam{MFAToken: mfaToken(t, userID), Code: validCode})
+		}},
+		{"provider", func(s *service, userID uuid.UUID) (*LoginResponse, error) {
+			user, err := s.userSvc.GetUser(context.Background(), userID)
+			if err != nil {
+				return nil, err
+			}
+			return s.issueTokens(context.Background(), user, nil, []string{amrFederated})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, user := newLoginService(t)
+			sessions := s.sessionSvc.(*fakeSessionService)
+			user.PasswordResetRequired = true
+
+			_, err := tt.login(s, user.ID)
+			if !apperrors.Is(err, autherrors.ErrPasswordResetRequired) {
+				t.Errorf("err = %v, want password reset required", err)
+			}
+			if sessions.count(user.ID) != 0 {
+				t.Error("session opened for a user who must reset their password")
+			}
+		})
+	}
+}
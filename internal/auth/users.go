@@ -0,0 +1,173 @@
+package auth
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	autherrors "auth-service/internal/shared/errors"
+	userModel "auth-service/internal/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/filters"
+)
+
+func (s *service) ListUsers(ctx context.Context, param ListUsersParam) (*UserList, error) {
+	filter := &userModel.Filter{
+		Username: param.Username,
+		Email:    param.Email,
+		IsActive: param.IsActive,
+		Pagination: filters.Pagination{
+			Offset:  param.Offset,
+			Limit:   param.Limit,
+			OrderBy: param.OrderBy,
+			SortDir: param.SortDir,
+		},
+	}
+
+	users, err := s.userSvc.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.userSvc.CountUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &UserList{
+		Users:  make([]userModel.Response, 0, len(users)),
+		Total:  total,
+		Offset: filter.GetOffset(),
+		Limit:  filter.GetLimit(),
+	}
+	for _, user := range users {
+		list.Users = append(list.Users, *user.Response())
+	}
+	return list, nil
+}
+
+func (s *service) GetUser(ctx context.Context, id uuid.UUID) (*userModel.Response, error) {
+	user, err := s.userSvc.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return user.Response(), nil
+}
+
+// checkCanManage refuses to let an administrator act on a user who holds a
+// permission the administrator lacks. Otherwise users:write could take over
+// a super admin by changing their email and resetting the password.
+func (s *service) checkCanManage(ctx context.Context, op string, adminID, id uuid.UUID) error {
+	admin, err := s.roleSvc.UserAuthorization(ctx, adminID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.roleSvc.UserAuthorization(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, permission := range user.Permissions {
+		if !authmiddleware.HasPermission(admin.Permissions, permission) {
+			return autherrors.ErrForbidden.WithOp(op)
+		}
+	}
+	return nil
+}
+
+func (s *service) UpdateUser(ctx context.Context, adminID, id uuid.UUID, param UpdateUserParam) (*userModel.Response, error) {
+	const op = "service.UpdateUser"
+
+	err := s.checkCanManage(ctx, op, adminID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userSvc.UpdateUser(ctx, id, &userModel.UpdateUserParam{
+		Username:      param.Username,
+		Email:         param.Email,
+		EmailVerified: param.EmailVerified,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+func (s *service) SetUserActive(ctx context.Context, adminID, id uuid.UUID, active bool) error {
+	const op = "service.SetUserActive"
+
+	// An administrator could not undo deactivating themselves
+	if !active && adminID == id {
+		return autherrors.ErrForbidden.WithOp(op)
+	}
+
+	err := s.checkCanManage(ctx, op, adminID, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.userSvc.SetActive(ctx, id, active)
+	if err != nil {
+		return err
+	}
+
+	if active {
+		return nil
+	}
+	return s.revokeAllSessions(ctx, op, id)
+}
+
+func (s *service) ForcePasswordReset(ctx context.Context, adminID, id uuid.UUID) error {
+	const op = "service.ForcePasswordReset"
+
+	err := s.checkCanManage(ctx, op, adminID, id)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userSvc.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// The reset link is the only way back in
+	if user.Email == nil {
+		return apperrors.ErrXIsRequired.WithField(consts.EmailField).WithOp(op)
+	}
+
+	err = s.userSvc.RequirePasswordReset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.revokeAllSessions(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	return s.sendPasswordReset(ctx, op, user)
+}
+
+func (s *service) DeleteUser(ctx context.Context, adminID, id uuid.UUID) error {
+	const op = "service.DeleteUser"
+
+	if adminID == id {
+		return autherrors.ErrForbidden.WithOp(op)
+	}
+
+	err := s.checkCanManage(ctx, op, adminID, id)
+	if err != nil {
+		return err
+	}
+
+	// Access tokens outlive the user row unless revoked first
+	err = s.revokeAllSessions(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	return s.userSvc.DeleteUser(ctx, id)
+}
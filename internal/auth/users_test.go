@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/role"
+	autherrors "auth-service/internal/shared/errors"
+	"context"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"testing"
+)
+
+func TestCheckCanManage(t *testing.T) {
+	var (
+		superAdmin = uuid.New()
+		userAdmin  = uuid.New()
+		support    = uuid.New()
+		member     = uuid.New()
+	)
+	s := newAuthorizationService(&config.Config{}, map[uuid.UUID]*role.Authorization{
+		superAdmin: {Roles: []string{"admin"}, Permissions: []string{"*"}},
+		userAdmin:  {Roles: []string{"user-admin"}, Permissions: []string{"users:*"}},
+		support:    {Roles: []string{"support"}, Permissions: []string{"users:read", "users:write"}},
+	})
+
+	tests := []struct {
+		name      string
+		admin, id uuid.UUID
+		allowed   bool
+	}{
+		{"user without permissions", support, member, true},
+		{"same permissions", support, support, true},
+		{"wildcard covers", userAdmin, support, true},
+		{"super admin covers everything", superAdmin, userAdmin, true},
+		{"missing permission", support, userAdmin, false},
+		{"wildcard target", userAdmin, superAdmin, false},
+		{"no permissions at all", member, support, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkCanManage(context.Background(), "test", tt.admin, tt.id)
+			if tt.allowed && err != nil {
+				t.Errorf("err = %v, want allowed", err)
+			} else if !tt.allowed && !apperrors.Is(err, autherrors.ErrForbidden) {
+				t.Errorf("err = %v, want forbidden", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package authmethod
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Type string
+
+// Types allowed by the auth_methods.method_type check constraint
+const (
+	TypePassword Type = "password"
+	TypeGoogle   Type = "oauth2_google"
+	TypeGitHub   Type = "oauth2_github"
+	TypeApple    Type = "oauth2_apple"
+	TypeWebAuthn Type = "webauthn"
+)
+
+// AuthMethod is a way a user can sign in. ProviderID identifies the user at
+// the provider, or the credential for WebAuthn.
+type AuthMethod struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	MethodType Type      `json:"method_type" db:"method_type"`
+	ProviderID *string   `json:"provider_id,omitempty" db:"provider_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
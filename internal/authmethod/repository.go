@@ -0,0 +1,113 @@
+package authmethod
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLastMethod is returned when deleting the only way a user can sign in.
+var ErrLastMethod = errors.New("cannot remove the last auth method")
+
+type Repository interface {
+	Create(ctx context.Context, method *AuthMethod) error
+	FindByProvider(ctx context.Context, methodType Type, providerID string) (*AuthMethod, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]AuthMethod, error)
+	// Delete removes one of the user's methods unless it is the last one.
+	Delete(ctx context.Context, userID, id uuid.UUID) (*AuthMethod, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, method *AuthMethod) error {
+	return r.db.WithContext(ctx).Create(method).Error
+}
+
+func (r *repository) FindByProvider(ctx context.Context, methodType Type, providerID string) (*AuthMethod, error) {
+	var method AuthMethod
+	result := r.db.WithContext(ctx).
+		Where("method_type = ? AND provider_id = ?", methodType, providerID).
+		First(&method)
+	return &method, result.Error
+}
+
+func (r *repository) ListByUser(ctx context.Context, userID uuid.UUID) ([]AuthMethod, error) {
+	var methods []AuthMethod
+	result := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&methods)
+	return methods, result.Error
+}
+
+func (r *repository) Delete(ctx context.Context, userID, id uuid.UUID) (*AuthMethod, error) {
+	var deleted *AuthMethod
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		deleted, err = DeleteTx(tx, userID, id)
+		return err
+	})
+	return deleted, err
+}
+
+// DeleteTx deletes one of the user's methods inside tx unless it is the last
+// one. Deleting the password method also clears the password. Rows that
+// reference the method, such as WebAuthn credentials, are deleted with it.
+func DeleteTx(tx *gorm.DB, userID, id uuid.UUID) (*AuthMethod, error) {
+	// Locking the user's methods serializes concurrent deletes, which could
+	// otherwise both see a second method and remove the last two
+	var methods []AuthMethod
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		Find(&methods).Error
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := removable(methods, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Delete(method).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if method.MethodType == TypePassword {
+		err = tx.Table("users").
+			Where("id = ?", userID).
+			Update("password_hash", nil).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return method, nil
+}
+
+// removable returns the method with id among all of a user's methods,
+// unless it is the only one left.
+func removable(methods []AuthMethod, id uuid.UUID) (*AuthMethod, error) {
+	var method *AuthMethod
+	for i := range methods {
+		if methods[i].ID == id {
+			method = &methods[i]
+		}
+	}
+	if method == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if len(methods) == 1 {
+		return nil, ErrLastMethod
+	}
+	return method, nil
+}
@@ -0,0 +1,85 @@
+package authmethod
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	autherrors "auth-service/internal/shared/errors"
+	dberrors "auth-service/pkg/error"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	// GetByProvider finds the method a provider's user ID is linked to.
+	GetByProvider(ctx context.Context, methodType Type, providerID string) (*AuthMethod, error)
+	ListMethods(ctx context.Context, userID uuid.UUID) ([]AuthMethod, error)
+	// Link adds a provider account to the user. An account already linked to
+	// any user is a conflict.
+	Link(ctx context.Context, userID uuid.UUID, methodType Type, providerID string) (*AuthMethod, error)
+	// Unlink removes one of the user's methods, unless it is the last one.
+	Unlink(ctx context.Context, userID, id uuid.UUID) (*AuthMethod, error)
+}
+
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{repo: repo, logger: logger}
+}
+
+func (s *service) GetByProvider(ctx context.Context, methodType Type, providerID string) (*AuthMethod, error) {
+	const op = "service.GetByProvider"
+	method, err := s.repo.FindByProvider(ctx, methodType, providerID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.AuthMethodField).WithOp(op)
+	}
+	return method, nil
+}
+
+func (s *service) ListMethods(ctx context.Context, userID uuid.UUID) ([]AuthMethod, error) {
+	const op = "service.ListMethods"
+	methods, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.AuthMethodField).WithOp(op)
+	}
+	return methods, nil
+}
+
+func (s *service) Link(ctx context.Context, userID uuid.UUID, methodType Type, providerID string) (*AuthMethod, error) {
+	const op = "service.Link"
+
+	_, err := s.repo.FindByProvider(ctx, methodType, providerID)
+	if err == nil {
+		return nil, apperrors.ErrXConflict.WithField(authconsts.AuthMethodField).WithOp(op)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, dberrors.WrapDBError(err, authconsts.AuthMethodField).WithOp(op)
+	}
+
+	method := &AuthMethod{
+		ID:         uuid.New(),
+		UserID:     userID,
+		MethodType: methodType,
+		ProviderID: &providerID,
+	}
+	err = s.repo.Create(ctx, method)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.AuthMethodField).WithOp(op)
+	}
+	return method, nil
+}
+
+func (s *service) Unlink(ctx context.Context, userID, id uuid.UUID) (*AuthMethod, error) {
+	const op = "service.Unlink"
+	method, err := s.repo.Delete(ctx, userID, id)
+	if errors.Is(err, ErrLastMethod) {
+		return nil, autherrors.ErrLastAuthMethod.WithOp(op).Wrap(err)
+	} else if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.AuthMethodField).WithOp(op)
+	}
+	return method, nil
+}
@@ -0,0 +1,149 @@
+package authmethod
+
+import (
+	autherrors "auth-service/internal/shared/errors"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// fakeRepository keeps methods in memory and deletes them with the same
+// guard as DeleteTx.
+type fakeRepository struct {
+	methods []AuthMethod
+}
+
+func (r *fakeRepository) Create(_ context.Context, method *AuthMethod) error {
+	r.methods = append(r.methods, *method)
+	return nil
+}
+
+func (r *fakeRepository) FindByProvider(_ context.Context, methodType Type, providerID string) (*AuthMethod, error) {
+	for i := range r.methods {
+		method := &r.methods[i]
+		if method.MethodType == methodType && method.ProviderID != nil && *method.ProviderID == providerID {
+			return method, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRepository) ListByUser(_ context.Context, userID uuid.UUID) ([]AuthMethod, error) {
+	var methods []AuthMethod
+	for _, method := range r.methods {
+		if method.UserID == userID {
+			methods = append(methods, method)
+		}
+	}
+	return methods, nil
+}
+
+func (r *fakeRepository) Delete(ctx context.Context, userID, id uuid.UUID) (*AuthMethod, error) {
+	methods, _ := r.ListByUser(ctx, userID)
+	method, err := removable(methods, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range r.methods {
+		if r.methods[i].ID == id {
+			r.methods = append(r.methods[:i], r.methods[i+1:]...)
+			break
+		}
+	}
+	return method, nil
+}
+
+func newMethod(userID uuid.UUID, methodType Type, providerID string) AuthMethod {
+	method := AuthMethod{ID: uuid.New(), UserID: userID, MethodType: methodType}
+	if providerID != "" {
+		method.ProviderID = &providerID
+	}
+	return method
+}
+
+func TestRemovable(t *testing.T) {
+	userID := uuid.New()
+	password := newMethod(userID, TypePassword, "")
+	google := newMethod(userID, TypeGoogle, "google-1")
+
+	tests := []struct {
+		name    string
+		methods []AuthMethod
+		id      uuid.UUID
+		wantErr error
+	}{
+		{"one of two", []AuthMethod{password, google}, google.ID, nil},
+		{"password with a provider left", []AuthMethod{password, google}, password.ID, nil},
+		{"last method", []AuthMethod{google}, google.ID, ErrLastMethod},
+		{"last password", []AuthMethod{password}, password.ID, ErrLastMethod},
+		{"not the user's", []AuthMethod{password, google}, uuid.New(), gorm.ErrRecordNotFound},
+		{"no methods", nil, google.ID, gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, err := removable(tt.methods, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && method.ID != tt.id {
+				t.Errorf("method = %s, want %s", method.ID, tt.id)
+			}
+		})
+	}
+}
+
+func TestLink(t *testing.T) {
+	ada, grace := uuid.New(), uuid.New()
+	repo := &fakeRepository{methods: []AuthMethod{newMethod(grace, TypeGitHub, "github-1")}}
+	s := NewService(repo, zap.NewNop())
+	ctx := context.Background()
+
+	method, err := s.Link(ctx, ada, TypeGoogle, "google-1")
+	if err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if method.UserID != ada || method.MethodType != TypeGoogle || *method.ProviderID != "google-1" {
+		t.Errorf("method = %+v", method)
+	}
+
+	// A provider account belongs to one user only
+	for _, userID := range []uuid.UUID{ada, grace} {
+		if _, err := s.Link(ctx, userID, TypeGitHub, "github-1"); !apperrors.Is(err, apperrors.ErrXConflict) {
+			t.Errorf("linking an account already linked: err = %v, want conflict", err)
+		}
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	ada, grace := uuid.New(), uuid.New()
+	adaPassword := newMethod(ada, TypePassword, "")
+	adaGoogle := newMethod(ada, TypeGoogle, "google-1")
+	graceGitHub := newMethod(grace, TypeGitHub, "github-1")
+
+	repo := &fakeRepository{methods: []AuthMethod{adaPassword, adaGoogle, graceGitHub}}
+	s := NewService(repo, zap.NewNop())
+	ctx := context.Background()
+
+	if _, err := s.Unlink(ctx, ada, graceGitHub.ID); !apperrors.Is(err, apperrors.ErrXNotFound) {
+		t.Errorf("another user's method: err = %v, want not found", err)
+	}
+	if _, err := s.Unlink(ctx, grace, graceGitHub.ID); !apperrors.Is(err, autherrors.ErrLastAuthMethod) {
+		t.Errorf("last method: err = %v, want last auth method", err)
+	}
+
+	method, err := s.Unlink(ctx, ada, adaPassword.ID)
+	if err != nil {
+		t.Fatalf("Unlink: %v", err)
+	}
+	if method.ID != adaPassword.ID {
+		t.Errorf("unlinked %s, want %s", method.ID, adaPassword.ID)
+	}
+	if _, err := s.Unlink(ctx, ada, adaGoogle.ID); !apperrors.Is(err, autherrors.ErrLastAuthMethod) {
+		t.Errorf("remaining method: err = %v, want last auth method", err)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"auth-service/db"
+	"auth-service/pkg/mailer"
 	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
@@ -13,10 +14,34 @@ import (
 	"time"
 )
 
+// RateLimitPolicy limits requests to Limit per Window, counted separately for
+// every key kind in Keys: "ip", "email" (from the JSON body) or "user".
+type RateLimitPolicy struct {
+	Limit  int           `mapstructure:"limit" validate:"min=1"`
+	Window time.Duration `mapstructure:"window" validate:"required"`
+	Keys   []string      `mapstructure:"keys" validate:"required,dive,oneof=ip email user"`
+}
+
+// OAuthProvider configures social login with one provider. The provider is
+// disabled when ClientID is empty. Endpoints default to the provider's own and
+// can point at a mock server instead.
+type OAuthProvider struct {
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url" validate:"required_with=ClientID"` // our callback
+	Scopes       []string `mapstructure:"scopes"`
+	AuthURL      string   `mapstructure:"auth_url" validate:"omitempty,url"`
+	TokenURL     string   `mapstructure:"token_url" validate:"omitempty,url"`
+	UserInfoURL  string   `mapstructure:"userinfo_url" validate:"omitempty,url"`
+}
+
 type Config struct {
 	Server struct {
 		Host string `mapstructure:"host"`
 		Port string `mapstructure:"port" validate:"required"`
+		// TrustedProxies may set X-Forwarded-For; empty trusts none, so the
+		// client IP is the peer address.
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
 	} `mapstructure:"server"`
 
 	Postgres struct {
@@ -29,9 +54,113 @@ type Config struct {
 
 	JWT struct {
 		SecretKey       string        `mapstructure:"secret_key" validate:"required"`
+		Algorithm       string        `mapstructure:"algorithm" validate:"omitempty,oneof=HS256 RS256 ES256 EdDSA"`
+		PrivateKeyPath  string        `mapstructure:"private_key_path"`
+		KeyID           string        `mapstructure:"key_id"`
 		AccessDuration  time.Duration `mapstructure:"access_duration"`
 		RefreshDuration time.Duration `mapstructure:"refresh_duration"`
+
+		// Key ring
+		RotationInterval time.Duration `mapstructure:"rotation_interval"`
+		KeySyncInterval  time.Duration `mapstructure:"key_sync_interval"`
+
+		// Roles and permissions in access tokens
+		EmbedAuthorization     bool `mapstructure:"embed_authorization"`
+		MaxEmbeddedPermissions int  `mapstructure:"max_embedded_permissions" validate:"omitempty,min=1"`
 	} `mapstructure:"jwt"`
+
+	Lockout struct {
+		MaxAttempts   int           `mapstructure:"max_attempts" validate:"omitempty,min=1"`
+		AttemptWindow time.Duration `mapstructure:"attempt_window"`
+		BaseDuration  time.Duration `mapstructure:"base_duration"`
+		MaxDuration   time.Duration `mapstructure:"max_duration"`
+	} `mapstructure:"lockout"`
+
+	Verification struct {
+		CodeTTL              time.Duration `mapstructure:"code_ttl"`
+		MaxAttempts          int           `mapstructure:"max_attempts" validate:"omitempty,min=1"`
+		ResendCooldown       time.Duration `mapstructure:"resend_cooldown"`
+		AllowUnverifiedLogin bool          `mapstructure:"allow_unverified_login"`
+	} `mapstructure:"verification"`
+
+	MFA struct {
+		Issuer        string        `mapstructure:"issuer"` // shown in authenticator apps
+		TokenDuration time.Duration `mapstructure:"token_duration"`
+	} `mapstructure:"mfa"`
+
+	Reauth struct {
+		Window time.Duration `mapstructure:"window"` // how long a login or re-authentication counts as recent
+	} `mapstructure:"reauth"`
+
+	WebAuthn struct {
+		RPID          string        `mapstructure:"rp_id"` // the site's domain, without scheme or port
+		RPDisplayName string        `mapstructure:"rp_display_name"`
+		RPOrigins     []string      `mapstructure:"rp_origins" validate:"omitempty,dive,url"`
+		Timeout       time.Duration `mapstructure:"timeout"`
+	} `mapstructure:"webauthn"`
+
+	Social struct {
+		StateTTL time.Duration `mapstructure:"state_ttl"` // time allowed at the provider
+		Google   OAuthProvider `mapstructure:"google"`
+		GitHub   OAuthProvider `mapstructure:"github"`
+		Apple    struct {
+			OAuthProvider `mapstructure:",squash"`
+			Issuer        string `mapstructure:"issuer"` // expected iss of the ID token
+			TeamID        string `mapstructure:"team_id"`
+			KeyID         string `mapstructure:"key_id"`
+			// Signs the client secret JWT when client_secret is empty
+			PrivateKeyPath string `mapstructure:"private_key_path"`
+		} `mapstructure:"apple"`
+	} `mapstructure:"social"`
+
+	OAuth struct {
+		Issuer     string        `mapstructure:"issuer" validate:"omitempty,url"` // public base URL of this service
+		CodeTTL    time.Duration `mapstructure:"code_ttl"`
+		ConsentURL string        `mapstructure:"consent_url" validate:"omitempty,url"` // the authorization request is appended as its query
+	} `mapstructure:"oauth"`
+
+	Mailer struct {
+		Backend string `mapstructure:"backend" validate:"required,oneof=log smtp outbox"`
+		// AllowLog permits the log backend, which delivers nothing
+		AllowLog bool `mapstructure:"allow_log"`
+		// AllowOutbox permits the outbox backend, which keeps messages on disk
+		AllowOutbox bool   `mapstructure:"allow_outbox"`
+		From        string `mapstructure:"from" validate:"required_if=Backend smtp"`
+		OutboxDir   string `mapstructure:"outbox_dir" validate:"required_if=Backend outbox"`
+		SMTP        struct {
+			mailer.SMTPConfig `mapstructure:",squash"`
+		} `mapstructure:"smtp"`
+	} `mapstructure:"mailer"`
+
+	PasswordReset struct {
+		TokenTTL        time.Duration `mapstructure:"token_ttl"`
+		RequestCooldown time.Duration `mapstructure:"request_cooldown"`
+		URL             string        `mapstructure:"url" validate:"omitempty,url"` // the token is appended as ?token=
+	} `mapstructure:"password_reset"`
+
+	RateLimit struct {
+		Enabled  bool                       `mapstructure:"enabled"`
+		Backend  string                     `mapstructure:"backend" validate:"omitempty,oneof=redis memory"`
+		Policies map[string]RateLimitPolicy `mapstructure:"policies" validate:"dive"`
+	} `mapstructure:"rate_limit"`
+
+	Encryption struct {
+		Key string `mapstructure:"key" validate:"required"`
+	} `mapstructure:"encryption"`
+
+	Seed struct {
+		RolesFile string `mapstructure:"roles_file"`
+		OnStartup bool   `mapstructure:"on_startup"`
+	} `mapstructure:"seed"`
+
+	// BootstrapAdmin is given Role once, when Email is set. The user is
+	// created with Password if they do not exist.
+	BootstrapAdmin struct {
+		Email    string `mapstructure:"email" validate:"omitempty,email"`
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password" validate:"omitempty,min=6"`
+		Role     string `mapstructure:"role" validate:"required_with=Email"`
+	} `mapstructure:"bootstrap_admin"`
 }
 
 func (c *Config) Validate() error {
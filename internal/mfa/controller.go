@@ -0,0 +1,123 @@
+package mfa
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	authconsts "auth-service/internal/shared/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"github.com/xinyi-chong/common-lib/validation"
+	"go.uber.org/zap"
+)
+
+type Controller struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func NewController(service Service, logger *zap.Logger) *Controller {
+	return &Controller{service: service, logger: logger}
+}
+
+// EnrollTOTP godoc
+// @Summary Start TOTP enrollment
+// @Description Create a new authenticator secret. It is not required at login until confirmed. Requires a recent login.
+// @Tags MFA
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 201 {object} response.Response{data=EnrollResponse} "Created"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 409 {object} response.Response "MFA already enabled"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/totp/enroll [post]
+func (ctrl *Controller) EnrollTOTP(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.Enroll(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("EnrollTOTP error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.MFAField), resp)
+}
+
+// ConfirmTOTP godoc
+// @Summary Confirm TOTP enrollment
+// @Description Enable MFA with a code from the authenticator app
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body CodeParam true "Code"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 404 {object} response.Response "No enrollment in progress"
+// @Failure 409 {object} response.Response "MFA already enabled"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/totp/confirm [post]
+func (ctrl *Controller) ConfirmTOTP(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[CodeParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.Confirm(ctx, userID, param.Code)
+	if err != nil {
+		ctrl.logger.Error("ConfirmTOTP error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.MFAField), nil)
+}
+
+// GenerateRecoveryCodes godoc
+// @Summary Generate recovery codes
+// @Description Create a new set of single-use recovery codes. Earlier codes stop working. Requires a recent login.
+// @Tags MFA
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 201 {object} response.Response{data=RecoveryCodesResponse} "Created"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/mfa/recovery-codes [post]
+func (ctrl *Controller) GenerateRecoveryCodes(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	codes, err := ctrl.service.GenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("GenerateRecoveryCodes error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.RecoveryCodeField), RecoveryCodesResponse{Codes: codes})
+}
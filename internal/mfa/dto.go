@@ -0,0 +1,5 @@
+package mfa
+
+type CodeParam struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
@@ -0,0 +1,38 @@
+package mfa
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TOTPFactor is a user's authenticator app. It only counts as a second factor
+// once ConfirmedAt is set.
+type TOTPFactor struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	Secret          string     `json:"-" gorm:"-"`              // decrypted by the repository
+	SecretEncrypted string     `json:"-" db:"secret_encrypted"` // never expose in JSON
+	LastUsedStep    *int64     `json:"-" db:"last_used_step"`   // the newest accepted time step, to stop replays
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// RecoveryCode is a single-use code that can stand in for the second factor.
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"` // never expose in JSON
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type EnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qr_code"` // PNG data URI
+}
+
+type RecoveryCodesResponse struct {
+	Codes []string `json:"codes"` // shown once; only hashes are stored
+}
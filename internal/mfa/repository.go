@@ -0,0 +1,169 @@
+package mfa
+
+import (
+	"auth-service/pkg/secretbox"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Repository persists TOTP factors. Secrets are encrypted at rest.
+type Repository interface {
+	FindByUserID(ctx context.Context, userID uuid.UUID) (*TOTPFactor, error)
+	SavePending(ctx context.Context, factor *TOTPFactor) error
+	Confirm(ctx context.Context, userID uuid.UUID, step int64) error
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []RecoveryCode) error
+	ListUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, id uuid.UUID) error
+}
+
+type repository struct {
+	db  *gorm.DB
+	box *secretbox.Box
+}
+
+func NewRepository(db *gorm.DB, box *secretbox.Box) Repository {
+	return &repository{db: db, box: box}
+}
+
+func (r *repository) FindByUserID(ctx context.Context, userID uuid.UUID) (*TOTPFactor, error) {
+	var factor TOTPFactor
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		First(&factor).Error
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := r.box.Open(factor.SecretEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt totp secret of user %s: %w", userID, err)
+	}
+	factor.Secret = string(secret)
+	return &factor, nil
+}
+
+// SavePending stores an unconfirmed factor, replacing an earlier unconfirmed
+// one. A confirmed factor is never replaced.
+func (r *repository) SavePending(ctx context.Context, factor *TOTPFactor) error {
+	encrypted, err := r.box.Seal([]byte(factor.Secret))
+	if err != nil {
+		return err
+	}
+	factor.SecretEncrypted = encrypted
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"secret_encrypted": encrypted,
+				"last_used_step":   nil,
+				"updated_at":       time.Now().UTC(),
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "totp_factors.confirmed_at IS NULL"},
+			}},
+		}).
+		Create(factor)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrDuplicatedKey
+	}
+	return nil
+}
+
+func (r *repository) Confirm(ctx context.Context, userID uuid.UUID, step int64) error {
+	now := time.Now().UTC()
+	result := r.db.WithContext(ctx).
+		Model(&TOTPFactor{}).
+		Where("user_id = ? AND confirmed_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"confirmed_at":   now,
+			"last_used_step": step,
+			"updated_at":     now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UseStep records a time step as used. It fails if the step, or a later one,
+// was used already, so a code cannot be replayed.
+func (r *repository) UseStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&TOTPFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Where("last_used_step IS NULL OR last_used_step < ?", step).
+		Updates(map[string]interface{}{
+			"last_used_step": step,
+			"updated_at":     time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, userID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&TOTPFactor{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes deletes every recovery code of the user, used or not,
+// and stores the new set.
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *repository) ListUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Find(&codes)
+	return codes, result.Error
+}
+
+// UseRecoveryCode marks the code used. It fails if it was used already, so
+// two concurrent logins cannot share one code.
+func (r *repository) UseRecoveryCode(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
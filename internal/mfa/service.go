@@ -0,0 +1,285 @@
+package mfa
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	userModel "auth-service/internal/user"
+	dberrors "auth-service/pkg/error"
+	"auth-service/pkg/totp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	defaultIssuer = "Auth Service"
+	qrCodeSize    = 256
+)
+
+type Service interface {
+	Enroll(ctx context.Context, userID uuid.UUID) (*EnrollResponse, error)
+	Confirm(ctx context.Context, userID uuid.UUID, code string) error
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	// IsEnabled reports whether the user has a confirmed second factor.
+	IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	// Verify checks a code for the second login step. Each code is accepted
+	// only once.
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+
+	// GenerateRecoveryCodes replaces the user's recovery codes with a new set.
+	GenerateRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error)
+	// UseRecoveryCode consumes one of the user's recovery codes.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+type service struct {
+	repo           Repository
+	userSvc        userModel.Service
+	securityLogSvc securitylog.Service
+	issuer         string
+	logger         *zap.Logger
+}
+
+func NewService(cfg *config.Config, repo Repository, userSvc userModel.Service, securityLogSvc securitylog.Service, logger *zap.Logger) Service {
+	issuer := cfg.MFA.Issuer
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+
+	return &service{
+		repo:           repo,
+		userSvc:        userSvc,
+		securityLogSvc: securityLogSvc,
+		issuer:         issuer,
+		logger:         logger,
+	}
+}
+
+// Enroll starts TOTP enrollment with a new secret. The factor is not used for
+// logins until it is confirmed with a code.
+func (s *service) Enroll(ctx context.Context, userID uuid.UUID) (*EnrollResponse, error) {
+	const op = "service.Enroll"
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	err = s.repo.SavePending(ctx, &TOTPFactor{
+		ID:     uuid.New(),
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	}
+
+	uri := totp.URI(s.issuer, accountName(user), secret)
+	png, err := totp.QRCode(uri, qrCodeSize)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return &EnrollResponse{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+func accountName(user *userModel.User) string {
+	if user.Email != nil {
+		return *user.Email
+	}
+	if user.Username != nil {
+		return *user.Username
+	}
+	return user.ID.String()
+}
+
+func (s *service) Confirm(ctx context.Context, userID uuid.UUID, code string) error {
+	const op = "service.Confirm"
+
+	factor, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	} else if factor.ConfirmedAt != nil {
+		return apperrors.ErrXConflict.WithField(authconsts.MFAField).WithOp(op)
+	}
+
+	step, ok := totp.Validate(factor.Secret, code, time.Now())
+	if !ok {
+		return apperrors.ErrInvalidX.WithField(authconsts.CodeField).WithOp(op)
+	}
+
+	err = s.repo.Confirm(ctx, userID, step)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionMFAEnabled,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"factor": "totp"},
+	})
+	return nil
+}
+
+// Disable removes the factor. A confirmed factor needs a current code, so a
+// stolen access token alone cannot turn MFA off.
+func (s *service) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	const op = "service.Disable"
+
+	factor, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	}
+
+	if factor.ConfirmedAt != nil {
+		err = s.Verify(ctx, userID, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.repo.Delete(ctx, userID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	}
+
+	if factor.ConfirmedAt != nil {
+		s.securityLogSvc.Record(ctx, securitylog.Event{
+			UserID:   &userID,
+			Action:   securitylog.ActionMFADisabled,
+			Status:   securitylog.StatusSuccess,
+			Metadata: securitylog.Metadata{"factor": "totp"},
+		})
+	}
+	return nil
+}
+
+func (s *service) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	const op = "service.IsEnabled"
+
+	factor, err := s.repo.FindByUserID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	}
+	return factor.ConfirmedAt != nil, nil
+}
+
+func (s *service) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	const op = "service.Verify"
+
+	factor, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	} else if factor.ConfirmedAt == nil {
+		return apperrors.ErrXNotFound.WithField(authconsts.MFAField).WithOp(op)
+	}
+
+	step, ok := totp.Validate(factor.Secret, code, time.Now())
+	if !ok {
+		return apperrors.ErrInvalidX.WithField(authconsts.CodeField).WithOp(op)
+	}
+
+	err = s.repo.UseStep(ctx, userID, step)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The code was valid but has been used already
+		return apperrors.ErrInvalidX.WithField(authconsts.CodeField).WithOp(op)
+	} else if err != nil {
+		return dberrors.WrapDBError(err, authconsts.MFAField).WithOp(op)
+	}
+	return nil
+}
+
+func (s *service) GenerateRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	const op = "service.GenerateRecoveryCodes"
+
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]RecoveryCode, 0, recoveryCodeCount)
+	now := time.Now().UTC()
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+
+		hash, err := hashRecoveryCode(code)
+		if err != nil {
+			return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+
+		plain = append(plain, code)
+		codes = append(codes, RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: now,
+		})
+	}
+
+	err := s.repo.ReplaceRecoveryCodes(ctx, userID, codes)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RecoveryCodeField).WithOp(op)
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionRecoveryCodesGenerated,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"count": len(codes)},
+	})
+
+	return plain, nil
+}
+
+func (s *service) UseRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error {
+	const op = "service.UseRecoveryCode"
+
+	codes, err := s.repo.ListUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.RecoveryCodeField).WithOp(op)
+	}
+
+	for _, stored := range codes {
+		if !isRecoveryCodeMatch(stored.CodeHash, code) {
+			continue
+		}
+
+		err = s.repo.UseRecoveryCode(ctx, stored.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		} else if err != nil {
+			return dberrors.WrapDBError(err, authconsts.RecoveryCodeField).WithOp(op)
+		}
+
+		remaining := len(codes) - 1
+		s.securityLogSvc.Record(ctx, securitylog.Event{
+			UserID:   &userID,
+			Action:   securitylog.ActionRecoveryCodeUsed,
+			Status:   securitylog.StatusSuccess,
+			Metadata: securitylog.Metadata{"remaining": remaining},
+		})
+		if remaining == 0 {
+			s.logger.Info("Last recovery code used", zap.String("user_id", userID.String()))
+		}
+		return nil
+	}
+
+	return apperrors.ErrInvalidX.WithField(authconsts.RecoveryCodeField).WithOp(op)
+}
@@ -0,0 +1,271 @@
+package mfa
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	"auth-service/pkg/totp"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, "init logger:", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeRepository keeps factors in memory, with the same step bookkeeping as
+// the SQL in repository.
+type fakeRepository struct {
+	factors       map[uuid.UUID]*TOTPFactor
+	recoveryCodes map[uuid.UUID]*RecoveryCode
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		factors:       map[uuid.UUID]*TOTPFactor{},
+		recoveryCodes: map[uuid.UUID]*RecoveryCode{},
+	}
+}
+
+func (r *fakeRepository) FindByUserID(_ context.Context, userID uuid.UUID) (*TOTPFactor, error) {
+	factor, ok := r.factors[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *factor
+	return &copied, nil
+}
+
+func (r *fakeRepository) SavePending(_ context.Context, factor *TOTPFactor) error {
+	if existing, ok := r.factors[factor.UserID]; ok && existing.ConfirmedAt != nil {
+		return gorm.ErrDuplicatedKey
+	}
+	copied := *factor
+	r.factors[factor.UserID] = &copied
+	return nil
+}
+
+func (r *fakeRepository) Confirm(_ context.Context, userID uuid.UUID, step int64) error {
+	factor, ok := r.factors[userID]
+	if !ok || factor.ConfirmedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	factor.ConfirmedAt, factor.LastUsedStep = &now, &step
+	return nil
+}
+
+func (r *fakeRepository) UseStep(_ context.Context, userID uuid.UUID, step int64) error {
+	factor, ok := r.factors[userID]
+	if !ok || factor.ConfirmedAt == nil || (factor.LastUsedStep != nil && *factor.LastUsedStep >= step) {
+		return gorm.ErrRecordNotFound
+	}
+	factor.LastUsedStep = &step
+	return nil
+}
+
+func (r *fakeRepository) Delete(_ context.Context, userID uuid.UUID) error {
+	if _, ok := r.factors[userID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.factors, userID)
+	return nil
+}
+
+func (r *fakeRepository) ReplaceRecoveryCodes(_ context.Context, userID uuid.UUID, codes []RecoveryCode) error {
+	for id, code := range r.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.recoveryCodes, id)
+		}
+	}
+	for i := range codes {
+		r.recoveryCodes[codes[i].ID] = &codes[i]
+	}
+	return nil
+}
+
+func (r *fakeRepository) ListUnusedRecoveryCodes(_ context.Context, userID uuid.UUID) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+	for _, code := range r.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			codes = append(codes, *code)
+		}
+	}
+	return codes, nil
+}
+
+func (r *fakeRepository) UseRecoveryCode(_ context.Context, id uuid.UUID) error {
+	code, ok := r.recoveryCodes[id]
+	if !ok || code.UsedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return nil
+}
+
+type fakeSecurityLog struct{}
+
+func (fakeSecurityLog) Record(context.Context, securitylog.Event) {}
+
+// enrollment is a user with a confirmed factor.
+type enrollment struct {
+	svc         Service
+	repo        *fakeRepository
+	userID      uuid.UUID
+	secret      string
+	confirmCode string // spent by the confirmation
+}
+
+func newEnrolledService(t *testing.T) *enrollment {
+	t.Helper()
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+
+	repo := newFakeRepository()
+	userID := uuid.New()
+	repo.factors[userID] = &TOTPFactor{ID: uuid.New(), UserID: userID, Secret: secret}
+
+	svc := NewService(&config.Config{}, repo, nil, fakeSecurityLog{}, zap.NewNop())
+	code := codeAt(t, secret, 0)
+	if err := svc.Confirm(context.Background(), userID, code); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	return &enrollment{svc: svc, repo: repo, userID: userID, secret: secret, confirmCode: code}
+}
+
+// codeAt returns the code offset steps from now.
+func codeAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	return code
+}
+
+func TestVerify_ConfirmationCodeIsSpent(t *testing.T) {
+	e := newEnrolledService(t)
+
+	err := e.svc.Verify(context.Background(), e.userID, e.confirmCode)
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("err = %v, want the confirmation code refused", err)
+	}
+}
+
+func TestVerify_CodeAcceptedOnce(t *testing.T) {
+	e := newEnrolledService(t)
+	ctx := context.Background()
+	code := codeAt(t, e.secret, 1)
+
+	if err := e.svc.Verify(ctx, e.userID, code); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := e.svc.Verify(ctx, e.userID, code); !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("replayed code: err = %v, want invalid code", err)
+	}
+}
+
+func TestVerify_OlderStepAfterNewer(t *testing.T) {
+	e := newEnrolledService(t)
+	ctx := context.Background()
+
+	// As if the factor was confirmed a minute ago
+	confirmed := totp.Step(time.Now()) - 2
+	e.repo.factors[e.userID].LastUsedStep = &confirmed
+
+	if err := e.svc.Verify(ctx, e.userID, codeAt(t, e.secret, 1)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	// Still inside the skew window, but older than the accepted code
+	if err := e.svc.Verify(ctx, e.userID, codeAt(t, e.secret, 0)); !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("older code: err = %v, want invalid code", err)
+	}
+}
+
+func TestVerify_WrongCode(t *testing.T) {
+	e := newEnrolledService(t)
+	used := *e.repo.factors[e.userID].LastUsedStep
+
+	code := codeAt(t, e.secret, 5)
+	if err := e.svc.Verify(context.Background(), e.userID, code); !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("err = %v, want invalid code", err)
+	}
+	if *e.repo.factors[e.userID].LastUsedStep != used {
+		t.Error("a rejected code moved the last used step")
+	}
+}
+
+func TestVerify_Unconfirmed(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	repo := newFakeRepository()
+	userID := uuid.New()
+	repo.factors[userID] = &TOTPFactor{ID: uuid.New(), UserID: userID, Secret: secret}
+	svc := NewService(&config.Config{}, repo, nil, fakeSecurityLog{}, zap.NewNop())
+
+	err := svc.Verify(context.Background(), userID, codeAt(t, secret, 0))
+	if !apperrors.Is(err, apperrors.ErrXNotFound) {
+		t.Errorf("err = %v, want not found", err)
+	}
+	if enabled, _ := svc.IsEnabled(context.Background(), userID); enabled {
+		t.Error("unconfirmed factor counts as enabled")
+	}
+}
+
+func TestDisable_RequiresFreshCode(t *testing.T) {
+	e := newEnrolledService(t)
+	ctx := context.Background()
+
+	// The code that confirmed the factor cannot turn it off
+	if err := e.svc.Disable(ctx, e.userID, e.confirmCode); !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Fatalf("err = %v, want invalid code", err)
+	}
+	if _, ok := e.repo.factors[e.userID]; !ok {
+		t.Fatal("factor deleted with a spent code")
+	}
+
+	if err := e.svc.Disable(ctx, e.userID, codeAt(t, e.secret, 1)); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if _, ok := e.repo.factors[e.userID]; ok {
+		t.Error("factor not deleted")
+	}
+}
+
+func TestUseRecoveryCode_Once(t *testing.T) {
+	e := newEnrolledService(t)
+	ctx := context.Background()
+
+	codes, err := e.svc.GenerateRecoveryCodes(ctx, e.userID)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Typed differently from how it was shown
+	if err := e.svc.UseRecoveryCode(ctx, e.userID, " "+strings.ToUpper(codes[0][:5]+codes[0][6:])+" "); err != nil {
+		t.Fatalf("UseRecoveryCode: %v", err)
+	}
+	if err := e.svc.UseRecoveryCode(ctx, e.userID, codes[0]); !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("reused recovery code: err = %v, want invalid", err)
+	}
+	if err := e.svc.UseRecoveryCode(ctx, e.userID, codes[1]); err != nil {
+		t.Errorf("other recovery code: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	token "auth-service/pkg/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// AuthMiddleware authenticates the request with the bearer access token and
+// stores its claims in the gin context. Blacklisted or expired tokens are
+// rejected, as are tokens whose refresh token family was revoked or that were
+// issued before the user's revocation watermark.
+func AuthMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, bearerPrefix) {
+			logger.Debug("Missing or invalid Authorization header")
+			response.Error(c, apperrors.ErrUnauthorized)
+			return
+		}
+
+		accessToken := strings.TrimSpace(strings.TrimPrefix(authHeader, bearerPrefix))
+		if accessToken == "" {
+			logger.Debug("Empty bearer token")
+			response.Error(c, apperrors.ErrUnauthorized)
+			return
+		}
+
+		claims, err := token.ParseAccessToken(accessToken)
+		if err != nil {
+			logger.Debug("Invalid access token", zap.Error(err))
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				response.Error(c, apperrors.ErrSessionExpired)
+				return
+			}
+			response.Error(c, apperrors.ErrUnauthorized)
+			return
+		}
+
+		ctx := c.Request.Context()
+		blacklisted, err := token.IsTokenBlacklisted(ctx, accessToken)
+		if err != nil {
+			logger.Error("Check token blacklist error", zap.Error(err))
+			response.Error(c, apperrors.ErrInternalServerError)
+			return
+		} else if blacklisted {
+			logger.Debug("Blacklisted access token", zap.String("user_id", claims.UserID.String()))
+			response.Error(c, apperrors.ErrSessionExpired)
+			return
+		}
+
+		revoked, err := token.IsRevokedForUser(ctx, claims.UserID, claims.IssuedAt)
+		if err != nil {
+			logger.Error("Check user revocation error", zap.Error(err))
+			response.Error(c, apperrors.ErrInternalServerError)
+			return
+		} else if revoked {
+			logger.Debug("Access token issued before user revocation", zap.String("user_id", claims.UserID.String()))
+			response.Error(c, apperrors.ErrSessionExpired)
+			return
+		}
+
+		if claims.FamilyID != "" {
+			revoked, err = token.IsRefreshTokenFamilyRevoked(ctx, claims.FamilyID)
+			if err != nil {
+				logger.Error("Check token family error", zap.Error(err))
+				response.Error(c, apperrors.ErrInternalServerError)
+				return
+			} else if revoked {
+				logger.Debug("Access token of revoked family", zap.String("family_id", claims.FamilyID))
+				response.Error(c, apperrors.ErrSessionExpired)
+				return
+			}
+		}
+
+		// Identity forwarded through headers must not outlive a verified token
+		delete(c.Keys, consts.CtxUsername)
+		delete(c.Keys, consts.CtxUserEmail)
+		delete(c.Keys, authconsts.CtxPermissions)
+
+		c.Set(consts.CtxAccessToken, accessToken)
+		c.Set(consts.CtxUserID, claims.UserID)
+		c.Set(authconsts.CtxSessionID, claims.FamilyID)
+		if claims.Username != nil {
+			c.Set(consts.CtxUsername, *claims.Username)
+		}
+		if claims.Email != nil {
+			c.Set(consts.CtxUserEmail, *claims.Email)
+		}
+		if claims.Authz != nil && !claims.Authz.PermissionsOmitted {
+			c.Set(authconsts.CtxPermissions, claims.Authz.Permissions)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"auth-service/internal/config"
+	authconsts "auth-service/internal/shared/consts"
+	token "auth-service/pkg/jwt"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func initTokens(t *testing.T) {
+	t.Helper()
+	if err := token.Init(&config.Config{}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+}
+
+func accessToken(t *testing.T, userID uuid.UUID, username *string, authz *token.Authorization) string {
+	t.Helper()
+	accessToken, err := token.GenerateAccessToken(userID, username, nil, "", authz)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return accessToken
+}
+
+// authRequest sends GET /me with the Authorization header behind
+// AuthMiddleware and returns the status and the context the handler saw.
+// forwarded is set on the context first, as a proxy or an earlier middleware
+// could have.
+func authRequest(header string, forwarded map[string]any) (int, map[string]any) {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		for key, value := range forwarded {
+			c.Set(key, value)
+		}
+		c.Next()
+	})
+
+	var keys map[string]any
+	router.GET("/me", AuthMiddleware(zap.NewNop()), func(c *gin.Context) {
+		keys = c.Keys
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	router.ServeHTTP(w, req)
+	return w.Code, keys
+}
+
+func TestAuthMiddleware_Rejects(t *testing.T) {
+	initTokens(t)
+	ctx := context.Background()
+
+	blacklisted := accessToken(t, uuid.New(), nil, nil)
+	if err := token.InvalidateToken(ctx, blacklisted); err != nil {
+		t.Fatalf("InvalidateToken: %v", err)
+	}
+
+	revokedUser := uuid.New()
+	revoked := accessToken(t, revokedUser, nil, nil)
+	if err := token.RevokeUserTokens(ctx, revokedUser, time.Now()); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+
+	valid := accessToken(t, uuid.New(), nil, nil)
+
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"no header", ""},
+		{"basic scheme", "Basic dXNlcjpwYXNz"},
+		{"lowercase scheme", "bearer " + valid},
+		{"empty token", "Bearer   "},
+		{"malformed token", "Bearer not-a-jwt"},
+		{"tampered token", "Bearer " + valid + "x"},
+		{"blacklisted token", "Bearer " + blacklisted},
+		{"issued before logout everywhere", "Bearer " + revoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, keys := authRequest(tt.header, nil)
+			if code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", code)
+			}
+			if keys != nil {
+				t.Error("handler ran")
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_SetsClaims(t *testing.T) {
+	initTokens(t)
+	userID := uuid.New()
+	username := "ada"
+	authz := &token.Authorization{Roles: []string{"support"}, Permissions: []string{"users:read"}}
+
+	code, keys := authRequest("Bearer "+accessToken(t, userID, &username, authz), nil)
+	if code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", code)
+	}
+	if keys[consts.CtxUserID] != userID {
+		t.Errorf("user ID = %v, want %v", keys[consts.CtxUserID], userID)
+	}
+	if keys[consts.CtxUsername] != username {
+		t.Errorf("username = %v, want %q", keys[consts.CtxUsername], username)
+	}
+	if permissions, _ := keys[authconsts.CtxPermissions].([]string); len(permissions) != 1 || permissions[0] != "users:read" {
+		t.Errorf("permissions = %v, want [users:read]", keys[authconsts.CtxPermissions])
+	}
+}
+
+func TestAuthMiddleware_StripsForwardedIdentity(t *testing.T) {
+	initTokens(t)
+	forwarded := map[string]any{
+		consts.CtxUserID:          uuid.New(),
+		consts.CtxUsername:        "mallory",
+		consts.CtxUserEmail:       "mallory@example.com",
+		authconsts.CtxPermissions: []string{"*"},
+		authconsts.CtxSessionID:   uuid.NewString(),
+	}
+
+	// The token has no username or email and omits its permissions, so
+	// nothing forwarded may be left in their place
+	userID := uuid.New()
+	header := "Bearer " + accessToken(t, userID, nil, &token.Authorization{PermissionsOmitted: true})
+
+	code, keys := authRequest(header, forwarded)
+	if code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", code)
+	}
+	if keys[consts.CtxUserID] != userID {
+		t.Errorf("user ID = %v, want %v", keys[consts.CtxUserID], userID)
+	}
+	for _, key := range []string{consts.CtxUsername, consts.CtxUserEmail, authconsts.CtxPermissions} {
+		if value, ok := keys[key]; ok {
+			t.Errorf("%s = %v, want it removed", key, value)
+		}
+	}
+	if keys[authconsts.CtxSessionID] != "" {
+		t.Errorf("session = %v, want the token's (none)", keys[authconsts.CtxSessionID])
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"auth-service/internal/shared/clientinfo"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInfoMiddleware stores the client IP, user agent and preferred
+// languages in the request context so services can attribute security events
+// and localize emails without depending on gin.
+func ClientInfoMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := clientinfo.WithInfo(c.Request.Context(), clientinfo.Info{
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Languages: []string{c.Query("lang"), c.GetHeader("Accept-Language")},
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
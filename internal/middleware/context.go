@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+)
+
+// UserIDFromContext returns the user ID set by AuthMiddleware.
+func UserIDFromContext(c *gin.Context) (uuid.UUID, error) {
+	value, exists := c.Get(consts.CtxUserID)
+	if !exists {
+		return uuid.Nil, apperrors.ErrUnauthorized
+	}
+
+	userID, ok := value.(uuid.UUID)
+	if !ok {
+		return uuid.Nil, apperrors.ErrInternalServerError.Wrap(fmt.Errorf("invalid user ID type in context: %T", value))
+	}
+	return userID, nil
+}
+
+// SessionIDFromContext returns the session of the access token, if it has one.
+func SessionIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	sessionID, err := uuid.Parse(c.GetString(authconsts.CtxSessionID))
+	return sessionID, err == nil
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	autherrors "auth-service/internal/shared/errors"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/response"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// PermissionLookup returns the permission codes of a user. It is used when the
+// access token does not embed them.
+type PermissionLookup func(ctx context.Context, userID uuid.UUID) ([]string, error)
+
+// PermissionChecker builds middleware that requires a permission granted
+// through the user's roles.
+type PermissionChecker struct {
+	lookup PermissionLookup
+	logger *zap.Logger
+}
+
+func NewPermissionChecker(lookup PermissionLookup, logger *zap.Logger) *PermissionChecker {
+	return &PermissionChecker{lookup: lookup, logger: logger}
+}
+
+// Require allows only users holding the permission. It must run after
+// AuthMiddleware.
+func (p *PermissionChecker) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := UserIDFromContext(c)
+		if err != nil {
+			response.Error(c, err)
+			return
+		}
+
+		permissions, ok := c.Value(authconsts.CtxPermissions).([]string)
+		if !ok {
+			permissions, err = p.lookup(c.Request.Context(), userID)
+			if err != nil {
+				p.logger.Error("Permission lookup error", zap.String("user_id", userID.String()), zap.Error(err))
+				response.Error(c, err)
+				return
+			}
+		}
+
+		if !HasPermission(permissions, permission) {
+			p.logger.Warn("Permission denied", zap.String("user_id", userID.String()), zap.String("permission", permission))
+			response.Error(c, autherrors.ErrForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission reports whether granted covers required. "*" covers
+// everything and "users:*" covers "users:read" and "users:lock:all".
+func HasPermission(granted []string, required string) bool {
+	for _, g := range granted {
+		if g == required || g == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasSuffix(prefix, ":") && strings.HasPrefix(required, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required string
+		want     bool
+	}{
+		{[]string{"users:read"}, "users:read", true},
+		{[]string{"users:read"}, "users:write", false},
+		{[]string{"*"}, "roles:write", true},
+		{[]string{"users:*"}, "users:read", true},
+		{[]string{"users:*"}, "users:lock:all", true},
+		{[]string{"users:*"}, "roles:read", false},
+		{[]string{"users:*"}, "users", false},
+		{[]string{"users*"}, "users:read", false}, // wildcards only follow a colon
+		{[]string{"users:*"}, "usersettings:read", false},
+		{[]string{"users:read:*"}, "users:read", false}, // a narrower wildcard does not cover its parent
+		{nil, "users:read", false},
+		{[]string{"roles:read", "users:read"}, "users:read", true},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.granted, tt.required); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+// permissionRequest runs GET /users behind Require("users:read"). embedded
+// is what AuthMiddleware would take from the token; nil means omitted.
+func permissionRequest(lookup PermissionLookup, userID *uuid.UUID, embedded []string) int {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID != nil {
+			c.Set(consts.CtxUserID, *userID)
+		}
+		if embedded != nil {
+			c.Set(authconsts.CtxPermissions, embedded)
+		}
+		c.Next()
+	})
+	checker := NewPermissionChecker(lookup, zap.NewNop())
+	router.GET("/users", checker.Require("users:read"), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	return w.Code
+}
+
+// recordingLookup returns permissions and counts how often it was asked.
+func recordingLookup(permissions []string, err error, calls *int) PermissionLookup {
+	return func(context.Context, uuid.UUID) ([]string, error) {
+		*calls++
+		return permissions, err
+	}
+}
+
+func TestRequire_EmbeddedPermissions(t *testing.T) {
+	userID := uuid.New()
+	var calls int
+	lookup := recordingLookup([]string{"users:read"}, nil, &calls)
+
+	if code := permissionRequest(lookup, &userID, []string{"users:read"}); code != http.StatusNoContent {
+		t.Errorf("granted: status = %d, want 204", code)
+	}
+	// The token is authoritative, even when the database would allow it
+	if code := permissionRequest(lookup, &userID, []string{"roles:read"}); code != http.StatusForbidden {
+		t.Errorf("not granted: status = %d, want 403", code)
+	}
+	if code := permissionRequest(lookup, &userID, []string{}); code != http.StatusForbidden {
+		t.Errorf("no permissions: status = %d, want 403", code)
+	}
+	if calls != 0 {
+		t.Errorf("lookup called %d times with embedded permissions", calls)
+	}
+}
+
+func TestRequire_LookupWhenOmitted(t *testing.T) {
+	userID := uuid.New()
+	var calls int
+
+	if code := permissionRequest(recordingLookup([]string{"users:*"}, nil, &calls), &userID, nil); code != http.StatusNoContent {
+		t.Errorf("granted: status = %d, want 204", code)
+	}
+	if code := permissionRequest(recordingLookup([]string{"roles:read"}, nil, &calls), &userID, nil); code != http.StatusForbidden {
+		t.Errorf("not granted: status = %d, want 403", code)
+	}
+	if calls != 2 {
+		t.Errorf("lookup called %d times, want 2", calls)
+	}
+}
+
+func TestRequire_LookupError(t *testing.T) {
+	userID := uuid.New()
+	var calls int
+	lookup := recordingLookup(nil, errors.New("database unavailable"), &calls)
+
+	// Unlike rate limiting, authorization fails closed
+	if code := permissionRequest(lookup, &userID, nil); code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", code)
+	}
+}
+
+func TestRequire_Unauthenticated(t *testing.T) {
+	var calls int
+	lookup := recordingLookup([]string{"*"}, nil, &calls)
+
+	if code := permissionRequest(lookup, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", code)
+	}
+	if calls != 0 {
+		t.Error("lookup called without a user")
+	}
+}
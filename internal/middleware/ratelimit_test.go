@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/testutil"
+	"auth-service/pkg/ratelimit"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("JWT_SECRET_KEY", "test-secret-key-of-at-least-32-bytes")
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+// failingLimiter stands in for a storage outage.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, int, time.Duration) (*ratelimit.Result, error) {
+	return nil, errors.New("connection refused")
+}
+
+// newRateLimitedRouter serves POST /login behind the policy and echoes the
+// request body, so tests can check the handler still gets it.
+func newRateLimitedRouter(limiter ratelimit.Limiter, policy config.RateLimitPolicy) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set(consts.CtxUserID, uuid.MustParse(userID))
+		}
+		c.Next()
+	})
+	router.POST("/login", RateLimitMiddleware(limiter, "login", policy, zap.NewNop()), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return router
+}
+
+func postLogin(router *gin.Engine, ip, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.RemoteAddr = ip + ":1234"
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddleware_ByIP(t *testing.T) {
+	policy := config.RateLimitPolicy{Limit: 2, Window: time.Minute, Keys: []string{RateLimitByIP}}
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), policy)
+
+	for i := range 2 {
+		w := postLogin(router, "192.0.2.1", "{}", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i+1, w.Code)
+		}
+		if got, want := w.Header().Get("RateLimit-Remaining"), fmt.Sprint(1-i); got != want {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, want)
+		}
+	}
+	if got := postLogin(router, "192.0.2.1", "{}", nil).Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 2;w=60", got)
+	}
+
+	w := postLogin(router, "192.0.2.1", "{}", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After missing")
+	}
+
+	if w := postLogin(router, "192.0.2.2", "{}", nil); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitMiddleware_ByEmail(t *testing.T) {
+	policy := config.RateLimitPolicy{Limit: 1, Window: time.Minute, Keys: []string{RateLimitByEmail}}
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), policy)
+
+	body := `{"email":"User@Example.com","password":"secret"}`
+	w := postLogin(router, "192.0.2.1", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Body.String() != body {
+		t.Errorf("handler got body %q, want %q", w.Body.String(), body)
+	}
+
+	// The address is normalized, and changing IP does not help
+	if w := postLogin(router, "192.0.2.2", `{"email":" user@example.com"}`, nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same email: status = %d, want 429", w.Code)
+	}
+	if w := postLogin(router, "192.0.2.1", `{"email":"other@example.com"}`, nil); w.Code != http.StatusOK {
+		t.Errorf("other email: status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitMiddleware_ByUser(t *testing.T) {
+	policy := config.RateLimitPolicy{Limit: 1, Window: time.Minute, Keys: []string{RateLimitByUser}}
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), policy)
+	user := http.Header{"X-Test-User": {uuid.NewString()}}
+
+	postLogin(router, "192.0.2.1", "{}", user)
+	if w := postLogin(router, "192.0.2.2", "{}", user); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same user: status = %d, want 429", w.Code)
+	}
+
+	// Anonymous requests have no user key to count
+	if w := postLogin(router, "192.0.2.1", "{}", nil); w.Code != http.StatusOK {
+		t.Errorf("anonymous: status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitMiddleware_StrictestKeyApplies(t *testing.T) {
+	policy := config.RateLimitPolicy{Limit: 2, Window: time.Minute, Keys: []string{RateLimitByIP, RateLimitByEmail}}
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), policy)
+
+	postLogin(router, "192.0.2.1", `{"email":"a@example.com"}`, nil)
+	w := postLogin(router, "192.0.2.2", `{"email":"a@example.com"}`, nil)
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want the email's 0", got)
+	}
+
+	if w := postLogin(router, "192.0.2.3", `{"email":"a@example.com"}`, nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 from the email limit", w.Code)
+	}
+}
+
+func TestRateLimitMiddleware_OversizedBody(t *testing.T) {
+	policy := config.RateLimitPolicy{Limit: 1, Window: time.Minute, Keys: []string{RateLimitByEmail}}
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), policy)
+
+	body := `{"email":"a@example.com","padding":"` + strings.Repeat("x", maxPeekBodySize) + `"}`
+	w := postLogin(router, "192.0.2.1", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Body.Len() != len(body) {
+		t.Errorf("handler got %d bytes, want the whole %d byte body", w.Body.Len(), len(body))
+	}
+}
+
+func TestRateLimitMiddleware_FailsOpen(t *testing.T) {
+	policy := config.RateLimitPolicy{Limit: 1, Window: time.Minute, Keys: []string{RateLimitByIP}}
+	router := newRateLimitedRouter(failingLimiter{}, policy)
+
+	for range 3 {
+		w := postLogin(router, "192.0.2.1", "{}", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 while the limiter is down", w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "" {
+			t.Error("RateLimit headers sent without a result")
+		}
+	}
+}
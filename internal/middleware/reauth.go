@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"auth-service/internal/reauth"
+	autherrors "auth-service/internal/shared/errors"
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"go.uber.org/zap"
+)
+
+// RequireRecentAuth allows only sessions that logged in or re-authenticated
+// within the configured window. It must run after AuthMiddleware.
+func RequireRecentAuth(store *reauth.Store, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, ok := SessionIDFromContext(c)
+		if !ok {
+			response.Error(c, autherrors.ErrReauthRequired)
+			return
+		}
+
+		recent, err := store.IsRecent(c.Request.Context(), sessionID)
+		if err != nil {
+			logger.Error("Check re-authentication error", zap.Error(err))
+			response.Error(c, apperrors.ErrInternalServerError)
+			return
+		} else if !recent {
+			logger.Debug("Re-authentication required", zap.String("session_id", sessionID.String()))
+			response.Error(c, autherrors.ErrReauthRequired)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	token "auth-service/pkg/jwt"
+	"context"
+	"errors"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"time"
+)
+
+const redisAssertionPrefix = "auth:oauth_assertion:"
+
+// markAssertionUsed remembers the jti until the assertion expires. It
+// reports false when the assertion was already used.
+func markAssertionUsed(ctx context.Context, clientID, jti string, expiresAt time.Time) (bool, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return false, err
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return client.SetNX(ctx, redisAssertionPrefix+clientID+":"+jti, 1, ttl).Result()
+}
+
+// authenticateAssertion checks a private_key_jwt assertion against the keys
+// registered for the client named in its subject.
+func (s *service) authenticateAssertion(ctx context.Context, creds ClientCredentials) (*Client, error) {
+	if len(s.audiences) == 0 {
+		s.logger.Warn("Client assertion rejected, oauth issuer is not configured")
+		return nil, errInvalidClient()
+	}
+
+	clientID, err := token.UnverifiedSubject(creds.Assertion)
+	if err != nil || clientID == "" {
+		return nil, errInvalidClient()
+	}
+	if creds.ClientID != "" && creds.ClientID != clientID {
+		return nil, errInvalidClient()
+	}
+
+	client, err := s.repo.FindByClientID(ctx, clientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInvalidClient()
+	} else if err != nil {
+		return nil, errServer(err)
+	} else if !client.IsActive || !client.IsConfidential || client.JWKS == nil {
+		return nil, errInvalidClient()
+	}
+
+	claims, err := token.ParseClientAssertion(creds.Assertion, token.JWKSet(*client.JWKS), client.ClientID, s.audiences)
+	if err != nil {
+		s.logger.Debug("Invalid client assertion", zap.String("client_id", client.ClientID), zap.Error(err))
+		return nil, errInvalidClient()
+	}
+
+	fresh, err := markAssertionUsed(ctx, client.ClientID, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, errServer(err)
+	} else if !fresh {
+		s.logger.Warn("Client assertion replayed", zap.String("client_id", client.ClientID))
+		return nil, errInvalidClient()
+	}
+
+	return client, nil
+}
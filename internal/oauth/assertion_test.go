@@ -0,0 +1,191 @@
+package oauth
+
+import (
+	token "auth-service/pkg/jwt"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+const assertionClientID = "signed-backend"
+
+// addAssertionClient registers a confidential client that authenticates
+// with private_key_jwt, and returns its signing key.
+func addAssertionClient(t *testing.T, repo *fakeRepository) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	repo.clients[assertionClientID] = &Client{
+		ID:       uuid.New(),
+		ClientID: assertionClientID,
+		Scopes:   []string{"read", "write"},
+		JWKS: &KeySet{Keys: []token.JWK{{
+			Kty: "EC",
+			Kid: "key-1",
+			Alg: token.AlgES256,
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}},
+		IsConfidential: true,
+		IsActive:       true,
+	}
+	return key
+}
+
+func signAssertion(t *testing.T, key *ecdsa.PrivateKey, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	assertion := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	assertion.Header["kid"] = "key-1"
+	signed, err := assertion.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+	return signed
+}
+
+func assertionClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    assertionClientID,
+		Subject:   assertionClientID,
+		Audience:  jwt.ClaimStrings{testIssuer + TokenPath},
+		ID:        uuid.NewString(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func requestClientToken(s *service, scope string, creds ClientCredentials) (*TokenResponse, error) {
+	return s.Token(context.Background(), TokenParam{GrantType: grantTypeClientCredentials, Scope: scope}, creds)
+}
+
+func TestClientCredentials_Assertion(t *testing.T) {
+	s, repo := newTestService(t)
+	key := addAssertionClient(t, repo)
+
+	resp, err := requestClientToken(s, "read", ClientCredentials{Assertion: signAssertion(t, key, assertionClaims())})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	claims, err := token.ParseOAuthAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseOAuthAccessToken: %v", err)
+	}
+	if claims.Subject != assertionClientID || claims.ClientID != assertionClientID {
+		t.Errorf("token subject %q, client %q, want the client", claims.Subject, claims.ClientID)
+	}
+}
+
+func TestClientCredentials_AssertionReplay(t *testing.T) {
+	s, repo := newTestService(t)
+	key := addAssertionClient(t, repo)
+	assertion := signAssertion(t, key, assertionClaims())
+
+	if _, err := requestClientToken(s, "", ClientCredentials{Assertion: assertion}); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	_, err := requestClientToken(s, "", ClientCredentials{Assertion: assertion})
+	assertOAuthError(t, err, codeInvalidClient)
+
+	// A new assertion from the same client is fine
+	if _, err := requestClientToken(s, "", ClientCredentials{Assertion: signAssertion(t, key, assertionClaims())}); err != nil {
+		t.Errorf("fresh assertion: %v", err)
+	}
+}
+
+func TestClientCredentials_AssertionReusedJTI(t *testing.T) {
+	s, repo := newTestService(t)
+	key := addAssertionClient(t, repo)
+	claims := assertionClaims()
+
+	if _, err := requestClientToken(s, "", ClientCredentials{Assertion: signAssertion(t, key, claims)}); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	// Re-signing does not make a used jti fresh
+	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Second))
+	_, err := requestClientToken(s, "", ClientCredentials{Assertion: signAssertion(t, key, claims)})
+	assertOAuthError(t, err, codeInvalidClient)
+}
+
+func TestClientCredentials_InvalidAssertion(t *testing.T) {
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*jwt.RegisteredClaims){
+		"wrong audience":    func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"https://other.example.com"} },
+		"expired":           func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) },
+		"too long lived":    func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour)) },
+		"no jti":            func(c *jwt.RegisteredClaims) { c.ID = "" },
+		"issuer not client": func(c *jwt.RegisteredClaims) { c.Issuer = "backend" },
+		"other key":         nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, repo := newTestService(t)
+			key := addAssertionClient(t, repo)
+			claims := assertionClaims()
+			if mutate != nil {
+				mutate(&claims)
+			} else {
+				key = otherKey
+			}
+
+			_, err := requestClientToken(s, "", ClientCredentials{Assertion: signAssertion(t, key, claims)})
+			assertOAuthError(t, err, codeInvalidClient)
+		})
+	}
+}
+
+func TestClientCredentials_AssertionForOtherClient(t *testing.T) {
+	s, repo := newTestService(t)
+	key := addAssertionClient(t, repo)
+
+	creds := ClientCredentials{ClientID: "backend", Assertion: signAssertion(t, key, assertionClaims())}
+	_, err := requestClientToken(s, "", creds)
+	assertOAuthError(t, err, codeInvalidClient)
+}
+
+func TestClientCredentials_Secret(t *testing.T) {
+	s, _ := newTestService(t)
+
+	resp, err := requestClientToken(s, "", ClientCredentials{ClientID: "backend", ClientSecret: testSecret, Basic: true})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if resp.Scope != "read write" {
+		t.Errorf("scope = %q, want the client's scopes", resp.Scope)
+	}
+
+	_, err = requestClientToken(s, "", ClientCredentials{ClientID: "backend", ClientSecret: "wrong"})
+	assertOAuthError(t, err, codeInvalidClient)
+	_, err = requestClientToken(s, "", ClientCredentials{ClientID: "backend"})
+	assertOAuthError(t, err, codeInvalidClient)
+}
+
+func TestClientCredentials_Scopes(t *testing.T) {
+	s, _ := newTestService(t)
+	creds := ClientCredentials{ClientID: "backend", ClientSecret: testSecret}
+
+	_, err := requestClientToken(s, "read admin", creds)
+	assertOAuthError(t, err, codeInvalidScope)
+	_, err = requestClientToken(s, ScopeOpenID, creds)
+	assertOAuthError(t, err, codeInvalidScope)
+}
+
+func TestClientCredentials_PublicClient(t *testing.T) {
+	s, _ := newTestService(t)
+
+	_, err := requestClientToken(s, "", ClientCredentials{ClientID: "public-app"})
+	assertOAuthError(t, err, codeUnauthorizedClient)
+}
@@ -0,0 +1,324 @@
+package oauth
+
+import (
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	dberrors "auth-service/pkg/error"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	clientIDBytes     = 16
+	clientSecretBytes = 32
+)
+
+var (
+	// Unreserved characters, so IDs survive HTTP Basic and URLs unchanged
+	clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]+$`)
+	// RFC 6749 section 3.3
+	scopePattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]+$`)
+)
+
+func (s *service) ListClients(ctx context.Context) ([]Client, error) {
+	const op = "service.ListClients"
+	clients, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+	return clients, nil
+}
+
+func (s *service) GetClient(ctx context.Context, id uuid.UUID) (*Client, error) {
+	const op = "service.GetClient"
+	client, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+	return client, nil
+}
+
+func (s *service) CreateClient(ctx context.Context, adminID uuid.UUID, param CreateClientParam) (*ClientSecretResponse, error) {
+	const op = "service.CreateClient"
+
+	client := &Client{
+		ID:             uuid.New(),
+		Name:           param.Name,
+		Description:    param.Description,
+		RedirectURIs:   nonNil(param.RedirectURIs),
+		Scopes:         nonNil(param.Scopes),
+		JWKS:           nonEmptyKeySet(param.JWKS),
+		IsConfidential: param.IsConfidential == nil || *param.IsConfidential,
+		IsActive:       true,
+	}
+
+	if param.ClientID != nil {
+		client.ClientID = *param.ClientID
+	} else {
+		clientID, err := randomToken(clientIDBytes)
+		if err != nil {
+			return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+		client.ClientID = clientID
+	}
+
+	if !clientIDPattern.MatchString(client.ClientID) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.ClientField).WithOp(op)
+	}
+	if err := validateClient(client); err != nil {
+		return nil, err.WithOp(op)
+	}
+
+	// Clients with a key set authenticate with assertions and can ask for a
+	// secret later
+	var secret string
+	if client.IsConfidential && client.JWKS == nil {
+		var err error
+		secret, client.ClientSecretHash, err = newClientSecret()
+		if err != nil {
+			return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+	}
+
+	if err := s.repo.Create(ctx, client); err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	s.recordClientChange(ctx, adminID, securitylog.ActionOAuthClientCreated, client, nil)
+
+	return &ClientSecretResponse{Client: client, ClientSecret: secret}, nil
+}
+
+func (s *service) UpdateClient(ctx context.Context, adminID, id uuid.UUID, param UpdateClientParam) (*Client, error) {
+	const op = "service.UpdateClient"
+
+	client, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	columns := map[string]interface{}{}
+	if param.Name != nil {
+		client.Name = *param.Name
+		columns["name"] = client.Name
+	}
+	if param.Description != nil {
+		client.Description = param.Description
+		columns["description"] = client.Description
+	}
+	if param.RedirectURIs != nil {
+		client.RedirectURIs = param.RedirectURIs
+		columns["redirect_uris"] = client.RedirectURIs
+	}
+	if param.Scopes != nil {
+		client.Scopes = param.Scopes
+		columns["scopes"] = client.Scopes
+	}
+	if param.JWKS != nil {
+		client.JWKS = nonEmptyKeySet(param.JWKS)
+		columns["jwks"] = client.JWKS
+	}
+
+	if len(columns) == 0 {
+		return client, nil
+	}
+
+	if err := validateClient(client); err != nil {
+		return nil, err.WithOp(op)
+	}
+
+	if err := s.repo.UpdateColumns(ctx, id, columns); err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	fields := make([]string, 0, len(columns))
+	for column := range columns {
+		fields = append(fields, column)
+	}
+	slices.Sort(fields)
+	s.recordClientChange(ctx, adminID, securitylog.ActionOAuthClientUpdated, client, securitylog.Metadata{"fields": fields})
+
+	return client, nil
+}
+
+func (s *service) SetClientActive(ctx context.Context, adminID, id uuid.UUID, active bool) error {
+	const op = "service.SetClientActive"
+
+	client, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	err = s.repo.UpdateColumns(ctx, id, map[string]interface{}{"is_active": active})
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	s.recordClientChange(ctx, adminID, securitylog.ActionOAuthClientUpdated, client, securitylog.Metadata{"is_active": active})
+	return nil
+}
+
+func (s *service) RotateClientSecret(ctx context.Context, adminID, id uuid.UUID) (*ClientSecretResponse, error) {
+	const op = "service.RotateClientSecret"
+
+	client, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	} else if !client.IsConfidential {
+		// Public clients cannot keep a secret
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.ClientField).WithOp(op)
+	}
+
+	secret, hash, err := newClientSecret()
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	err = s.repo.UpdateColumns(ctx, id, map[string]interface{}{"client_secret_hash": hash})
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+	client.ClientSecretHash = hash
+
+	s.recordClientChange(ctx, adminID, securitylog.ActionOAuthClientRotated, client, nil)
+
+	return &ClientSecretResponse{Client: client, ClientSecret: secret}, nil
+}
+
+func (s *service) DeleteClient(ctx context.Context, adminID, id uuid.UUID) error {
+	const op = "service.DeleteClient"
+
+	client, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return dberrors.WrapDBError(err, authconsts.ClientField).WithOp(op)
+	}
+
+	s.recordClientChange(ctx, adminID, securitylog.ActionOAuthClientDeleted, client, nil)
+	return nil
+}
+
+// recordClientChange logs a change to a client against the administrator
+// who made it.
+func (s *service) recordClientChange(ctx context.Context, adminID uuid.UUID, action securitylog.Action, client *Client, metadata securitylog.Metadata) {
+	if metadata == nil {
+		metadata = securitylog.Metadata{}
+	}
+	metadata["client_id"] = client.ClientID
+	metadata["client_name"] = client.Name
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &adminID,
+		Action:   action,
+		Status:   securitylog.StatusSuccess,
+		Metadata: metadata,
+	})
+}
+
+// validateClient checks what the tags on the params cannot.
+func validateClient(client *Client) *apperrors.Error {
+	for _, uri := range client.RedirectURIs {
+		if !isValidRedirectURI(uri) {
+			return apperrors.ErrInvalidX.WithField(authconsts.RedirectURIField)
+		}
+	}
+
+	// Public clients only have the authorization code grant
+	if !client.IsConfidential && len(client.RedirectURIs) == 0 {
+		return apperrors.ErrXIsRequired.WithField(authconsts.RedirectURIField)
+	}
+
+	for _, scope := range client.Scopes {
+		if !scopePattern.MatchString(scope) {
+			return apperrors.ErrInvalidX.WithField(authconsts.ScopeField)
+		}
+	}
+
+	if client.JWKS != nil {
+		if !client.IsConfidential {
+			return apperrors.ErrInvalidX.WithField(authconsts.JWKSField)
+		}
+		for i := range client.JWKS.Keys {
+			if _, err := client.JWKS.Keys[i].PublicKey(); err != nil {
+				return apperrors.ErrInvalidX.WithField(authconsts.JWKSField)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isValidRedirectURI accepts absolute URIs without a fragment or wildcard
+// (RFC 6749 section 3.1.2). They must use https, except on the loopback
+// interface, or a private-use scheme such as com.example.app for native
+// apps (RFC 8252 section 7).
+func isValidRedirectURI(raw string) bool {
+	if strings.ContainsAny(raw, "#*") {
+		return false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	default:
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+// newClientSecret returns a secret and its bcrypt hash.
+func newClientSecret() (string, string, error) {
+	secret, err := randomToken(clientSecretBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, string(hash), nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// nonEmptyKeySet treats a key set without keys as none.
+func nonEmptyKeySet(keys *KeySet) *KeySet {
+	if keys == nil || len(keys.Keys) == 0 {
+		return nil
+	}
+	return keys
+}
@@ -0,0 +1,361 @@
+package oauth
+
+import (
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	token "auth-service/pkg/jwt"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// assertAppError checks the error and the field it names.
+func assertAppError(t *testing.T, err error, want *apperrors.Error, field consts.Field) {
+	t.Helper()
+	var appErr *apperrors.Error
+	if !apperrors.Is(err, want) || !errors.As(err, &appErr) || appErr.TemplateData["Field"] != field {
+		t.Errorf("err = %v, want %s for %s", err, want.MessageKey, field)
+	}
+}
+
+// lastEvent returns the event the service recorded last.
+func lastEvent(t *testing.T, s *service) securitylog.Event {
+	t.Helper()
+	events := s.securityLogSvc.(*fakeSecurityLog).events
+	if len(events) == 0 {
+		t.Fatal("no security event recorded")
+	}
+	return events[len(events)-1]
+}
+
+func TestCreateClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		param      CreateClientParam
+		wantSecret bool
+	}{
+		{
+			name:       "confidential by default",
+			param:      CreateClientParam{Name: "Backend", Scopes: []string{"read"}},
+			wantSecret: true,
+		},
+		{
+			name: "public",
+			param: CreateClientParam{
+				Name:           "SPA",
+				RedirectURIs:   []string{"https://spa.example.com/callback"},
+				IsConfidential: ptr(false),
+			},
+		},
+		{
+			name: "native app on the loopback interface",
+			param: CreateClientParam{
+				Name:           "CLI",
+				RedirectURIs:   []string{"http://127.0.0.1:8123/callback", "com.example.cli:/callback"},
+				IsConfidential: ptr(false),
+			},
+		},
+		{
+			name: "named",
+			param: CreateClientParam{
+				ClientID: ptr("reporting-service"),
+				Name:     "Reporting",
+			},
+			wantSecret: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestService(t)
+			adminID := uuid.New()
+
+			resp, err := s.CreateClient(context.Background(), adminID, tt.param)
+			if err != nil {
+				t.Fatalf("CreateClient: %v", err)
+			}
+			client := resp.Client
+
+			if tt.param.ClientID != nil && client.ClientID != *tt.param.ClientID {
+				t.Errorf("client_id = %q, want %q", client.ClientID, *tt.param.ClientID)
+			} else if !clientIDPattern.MatchString(client.ClientID) {
+				t.Errorf("generated client_id %q is not URL safe", client.ClientID)
+			}
+			if repo.clients[client.ClientID] != client || !client.IsActive {
+				t.Error("client not stored active")
+			}
+			if client.RedirectURIs == nil || client.Scopes == nil {
+				t.Error("lists stored as null")
+			}
+
+			if (resp.ClientSecret != "") != tt.wantSecret {
+				t.Fatalf("secret returned = %v, want %v", resp.ClientSecret != "", tt.wantSecret)
+			}
+			if tt.wantSecret && bcrypt.CompareHashAndPassword([]byte(client.ClientSecretHash), []byte(resp.ClientSecret)) != nil {
+				t.Error("stored hash does not match the returned secret")
+			} else if !tt.wantSecret && client.ClientSecretHash != "" {
+				t.Error("public client stored a secret")
+			}
+
+			event := lastEvent(t, s)
+			if event.Action != securitylog.ActionOAuthClientCreated || *event.UserID != adminID ||
+				event.Metadata["client_id"] != client.ClientID {
+				t.Errorf("event = %+v, want client creation by the admin", event)
+			}
+		})
+	}
+}
+
+func TestCreateClient_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		param CreateClientParam
+		want  *apperrors.Error
+		field consts.Field
+	}{
+		{
+			name:  "client ID with a space",
+			param: CreateClientParam{ClientID: ptr("my app"), Name: "App"},
+			want:  apperrors.ErrInvalidX,
+			field: authconsts.ClientField,
+		},
+		{
+			name:  "client ID taken",
+			param: CreateClientParam{ClientID: ptr("backend"), Name: "App"},
+			want:  apperrors.ErrXConflict,
+			field: authconsts.ClientField,
+		},
+		{
+			name:  "public client without a redirect URI",
+			param: CreateClientParam{Name: "SPA", IsConfidential: ptr(false)},
+			want:  apperrors.ErrXIsRequired,
+			field: authconsts.RedirectURIField,
+		},
+		{
+			name:  "plain http redirect URI",
+			param: CreateClientParam{Name: "App", RedirectURIs: []string{"http://app.example.com/callback"}},
+			want:  apperrors.ErrInvalidX,
+			field: authconsts.RedirectURIField,
+		},
+		{
+			name:  "redirect URI with a fragment",
+			param: CreateClientParam{Name: "App", RedirectURIs: []string{"https://app.example.com/callback#x"}},
+			want:  apperrors.ErrInvalidX,
+			field: authconsts.RedirectURIField,
+		},
+		{
+			name:  "relative redirect URI",
+			param: CreateClientParam{Name: "App", RedirectURIs: []string{"/callback"}},
+			want:  apperrors.ErrInvalidX,
+			field: authconsts.RedirectURIField,
+		},
+		{
+			name:  "scope with a quote",
+			param: CreateClientParam{Name: "App", Scopes: []string{`read"`}},
+			want:  apperrors.ErrInvalidX,
+			field: authconsts.ScopeField,
+		},
+		{
+			name: "key set on a public client",
+			param: CreateClientParam{
+				Name:           "SPA",
+				RedirectURIs:   []string{testRedirectURI},
+				IsConfidential: ptr(false),
+				JWKS:           &KeySet{Keys: []token.JWK{{Kty: "EC"}}},
+			},
+			want:  apperrors.ErrInvalidX,
+			field: authconsts.JWKSField,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestService(t)
+			before := len(repo.clients)
+
+			_, err := s.CreateClient(context.Background(), uuid.New(), tt.param)
+			assertAppError(t, err, tt.want, tt.field)
+			if len(repo.clients) != before {
+				t.Error("invalid client stored")
+			}
+			if events := s.securityLogSvc.(*fakeSecurityLog).events; len(events) != 0 {
+				t.Errorf("events = %v, want none", events)
+			}
+		})
+	}
+}
+
+func TestCreateClient_KeySetWithoutSecret(t *testing.T) {
+	s, repo := newTestService(t)
+	addAssertionClient(t, repo)
+
+	resp, err := s.CreateClient(context.Background(), uuid.New(), CreateClientParam{
+		Name: "Signed",
+		JWKS: repo.clients[assertionClientID].JWKS,
+	})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if resp.ClientSecret != "" || resp.Client.ClientSecretHash != "" {
+		t.Error("secret issued to a client that authenticates with its keys")
+	}
+}
+
+func TestUpdateClient(t *testing.T) {
+	s, repo := newTestService(t)
+	client := repo.clients["backend"]
+	adminID := uuid.New()
+
+	updated, err := s.UpdateClient(context.Background(), adminID, client.ID, UpdateClientParam{
+		Name:   ptr("Backend v2"),
+		Scopes: []string{"read"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateClient: %v", err)
+	}
+	if updated.Name != "Backend v2" || !slices.Equal(updated.Scopes, []string{"read"}) {
+		t.Errorf("client = %+v", updated)
+	}
+	if !slices.Equal(updated.RedirectURIs, []string{testRedirectURI}) {
+		t.Errorf("redirect_uris = %v, want them unchanged", updated.RedirectURIs)
+	}
+
+	event := lastEvent(t, s)
+	if event.Action != securitylog.ActionOAuthClientUpdated || *event.UserID != adminID ||
+		!slices.Equal(event.Metadata["fields"].([]string), []string{"name", "scopes"}) {
+		t.Errorf("event = %+v, want the changed fields", event)
+	}
+
+	// Nothing to change is not an update
+	events := len(s.securityLogSvc.(*fakeSecurityLog).events)
+	if _, err := s.UpdateClient(context.Background(), adminID, client.ID, UpdateClientParam{}); err != nil {
+		t.Fatalf("UpdateClient: %v", err)
+	}
+	if len(s.securityLogSvc.(*fakeSecurityLog).events) != events {
+		t.Error("empty update recorded")
+	}
+}
+
+func TestUpdateClient_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		param    UpdateClientParam
+		want     *apperrors.Error
+		field    consts.Field
+	}{
+		{
+			name:     "bad redirect URI",
+			clientID: "backend",
+			param:    UpdateClientParam{RedirectURIs: []string{"https://app.example.com/*"}},
+			want:     apperrors.ErrInvalidX,
+			field:    authconsts.RedirectURIField,
+		},
+		{
+			name:     "key set on a public client",
+			clientID: "public-app",
+			param:    UpdateClientParam{JWKS: &KeySet{Keys: []token.JWK{{Kty: "EC"}}}},
+			want:     apperrors.ErrInvalidX,
+			field:    authconsts.JWKSField,
+		},
+		{
+			name:  "unknown client",
+			param: UpdateClientParam{Name: ptr("Ghost")},
+			want:  apperrors.ErrXNotFound,
+			field: authconsts.ClientField,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestService(t)
+			id := uuid.New()
+			if client, ok := repo.clients[tt.clientID]; ok {
+				id = client.ID
+			}
+
+			_, err := s.UpdateClient(context.Background(), uuid.New(), id, tt.param)
+			assertAppError(t, err, tt.want, tt.field)
+		})
+	}
+}
+
+func TestSetClientActive(t *testing.T) {
+	s, repo := newTestService(t)
+	client := repo.clients["public-app"]
+
+	if err := s.SetClientActive(context.Background(), uuid.New(), client.ID, false); err != nil {
+		t.Fatalf("SetClientActive: %v", err)
+	}
+	if client.IsActive {
+		t.Fatal("client still active")
+	}
+	if event := lastEvent(t, s); event.Action != securitylog.ActionOAuthClientUpdated || event.Metadata["is_active"] != false {
+		t.Errorf("event = %+v, want the deactivation", event)
+	}
+
+	// A deactivated client cannot start an authorization
+	_, err := s.Authorize(context.Background(), uuid.New(), uuid.New(), ConsentParam{AuthorizeParam: authorizeParam("public-app", rfcChallenge), Approved: true})
+	assertAppError(t, err, apperrors.ErrInvalidX, authconsts.ClientField)
+}
+
+func TestRotateClientSecret(t *testing.T) {
+	s, repo := newTestService(t)
+	client := repo.clients["backend"]
+
+	resp, err := s.RotateClientSecret(context.Background(), uuid.New(), client.ID)
+	if err != nil {
+		t.Fatalf("RotateClientSecret: %v", err)
+	}
+	if lastEvent(t, s).Action != securitylog.ActionOAuthClientRotated {
+		t.Error("rotation not recorded")
+	}
+
+	if _, err := requestClientToken(s, "read", ClientCredentials{ClientID: "backend", ClientSecret: testSecret}); err == nil {
+		t.Error("old secret still accepted")
+	}
+	if _, err := requestClientToken(s, "read", ClientCredentials{ClientID: "backend", ClientSecret: resp.ClientSecret}); err != nil {
+		t.Errorf("new secret rejected: %v", err)
+	}
+}
+
+func TestRotateClientSecret_PublicClient(t *testing.T) {
+	s, repo := newTestService(t)
+
+	_, err := s.RotateClientSecret(context.Background(), uuid.New(), repo.clients["public-app"].ID)
+	assertAppError(t, err, apperrors.ErrInvalidX, authconsts.ClientField)
+	if repo.clients["public-app"].ClientSecretHash != "" {
+		t.Error("public client given a secret")
+	}
+}
+
+func TestDeleteClient(t *testing.T) {
+	s, repo := newTestService(t)
+	client := repo.clients["backend"]
+	adminID := uuid.New()
+
+	if err := s.DeleteClient(context.Background(), adminID, client.ID); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if _, ok := repo.clients["backend"]; ok {
+		t.Error("client not deleted")
+	}
+
+	// The event names the client, which is gone from the table
+	event := lastEvent(t, s)
+	if event.Action != securitylog.ActionOAuthClientDeleted || event.Metadata["client_id"] != "backend" {
+		t.Errorf("event = %+v, want the deleted client", event)
+	}
+
+	err := s.DeleteClient(context.Background(), adminID, client.ID)
+	assertAppError(t, err, apperrors.ErrXNotFound, authconsts.ClientField)
+}
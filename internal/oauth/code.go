@@ -0,0 +1,92 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"time"
+)
+
+const (
+	redisCodePrefix = "auth:oauth_code:"
+	codeBytes       = 32
+)
+
+var errCodeNotFound = errors.New("authorization code not found or expired")
+
+// authorizationCode is the grant a code stands for until the client redeems
+// it at the token endpoint.
+type authorizationCode struct {
+	ClientID      string    `json:"client_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	CodeChallenge string    `json:"code_challenge,omitempty"` // S256
+
+	// For the ID token
+	Nonce    string    `json:"nonce,omitempty"`
+	AuthTime time.Time `json:"auth_time"`
+	AMR      []string  `json:"amr,omitempty"`
+}
+
+// codeStore keeps authorization codes hashed in Redis, so they can be
+// redeemed once and only within the TTL.
+type codeStore struct {
+	ttl time.Duration
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *codeStore) issue(ctx context.Context, grant *authorizationCode) (string, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, codeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := client.Set(ctx, redisCodePrefix+hashCode(code), data, s.ttl).Err(); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// take returns the grant and deletes the code.
+func (s *codeStore) take(ctx context.Context, code string) (*authorizationCode, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.GetDel(ctx, redisCodePrefix+hashCode(code)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errCodeNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var grant authorizationCode
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
@@ -0,0 +1,603 @@
+package oauth
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	authconsts "auth-service/internal/shared/consts"
+	authsuccess "auth-service/internal/shared/success"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"github.com/xinyi-chong/common-lib/validation"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	bearerPrefix          = "Bearer "
+	discoveryCacheControl = "public, max-age=300"
+)
+
+type Controller struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func NewController(service Service, logger *zap.Logger) *Controller {
+	return &Controller{service: service, logger: logger}
+}
+
+// Authorize godoc
+// @Summary Authorization endpoint
+// @Description Start the authorization code flow (RFC 6749 with PKCE). Valid requests are redirected to the consent page with the same query; other errors go back to the client's redirect URI.
+// @Tags OAuth
+// @Param response_type query string true "Response type" Enums(code)
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string false "Space separated scopes"
+// @Param state query string false "State"
+// @Param code_challenge query string false "PKCE challenge, required for public clients"
+// @Param code_challenge_method query string false "PKCE method" Enums(S256)
+// @Param nonce query string false "Nonce to echo in the ID token"
+// @Success 302 "Redirect to the consent page or the client"
+// @Failure 400 {object} response.Response "Unknown client or redirect URI"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /oauth/authorize [get]
+func (ctrl *Controller) Authorize(c *gin.Context) {
+	var param AuthorizeParam
+	if err := c.ShouldBindQuery(&param); err != nil {
+		ctrl.logger.Debug("Invalid request query", zap.Error(err))
+		response.Error(c, apperrors.ErrBadRequest.Wrap(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	redirectURL, err := ctrl.service.ConsentURL(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("Authorize error", zap.String("client_id", param.ClientID), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// ConsentDetails godoc
+// @Summary Describe an authorization request
+// @Description Get the client and scopes of an authorization request to show on the consent page
+// @Tags OAuth
+// @Produce json
+// @Security BearerTokenAuth
+// @Param response_type query string true "Response type" Enums(code)
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string false "Space separated scopes"
+// @Param code_challenge query string false "PKCE challenge"
+// @Param code_challenge_method query string false "PKCE method" Enums(S256)
+// @Param nonce query string false "Nonce"
+// @Success 200 {object} response.Response{data=ConsentResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /oauth/consent [get]
+func (ctrl *Controller) ConsentDetails(c *gin.Context) {
+	var param AuthorizeParam
+	if err := c.ShouldBindQuery(&param); err != nil {
+		ctrl.logger.Debug("Invalid request query", zap.Error(err))
+		response.Error(c, apperrors.ErrBadRequest.Wrap(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	details, err := ctrl.service.ConsentDetails(ctx, param)
+	if err != nil {
+		ctrl.logger.Error("ConsentDetails error", zap.String("client_id", param.ClientID), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.ClientField), details)
+}
+
+// Consent godoc
+// @Summary Approve or deny an authorization request
+// @Description Record the current user's answer. The consent page sends the browser to the returned URI, which carries a code or an error.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body ConsentParam true "Authorization request and answer"
+// @Success 200 {object} response.Response{data=AuthorizeResponse} "Success"
+// @Failure 400 {object} response.Response "Unknown client or redirect URI"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /oauth/consent [post]
+func (ctrl *Controller) Consent(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	sessionID, ok := authmiddleware.SessionIDFromContext(c)
+	if !ok {
+		ctrl.logger.Debug("Missing session in context", zap.String("user_id", userID.String()))
+		response.Error(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var param ConsentParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, apperrors.ErrBadRequest)
+		return
+	}
+
+	ctx := c.Request.Context()
+	redirectURI, err := ctrl.service.Authorize(ctx, userID, sessionID, param)
+	if err != nil {
+		ctrl.logger.Error("Consent error", zap.String("client_id", param.ClientID), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, authsuccess.Authorized, AuthorizeResponse{RedirectURI: redirectURI})
+}
+
+// Token godoc
+// @Summary Token endpoint
+// @Description Exchange an authorization code for an access token, and an ID token with the openid scope, or get a token for the client itself with client_credentials.
+// @Description Confidential clients authenticate with HTTP Basic, client_secret in the form, or a private_key_jwt client assertion. Responses follow RFC 6749 rather than the usual envelope.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Grant type" Enums(authorization_code, client_credentials)
+// @Param code formData string false "Authorization code, for authorization_code"
+// @Param redirect_uri formData string false "Redirect URI of the authorization request, for authorization_code"
+// @Param code_verifier formData string false "PKCE verifier"
+// @Param scope formData string false "Space separated scopes, for client_credentials"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Param client_assertion_type formData string false "Client assertion type" Enums(urn:ietf:params:oauth:client-assertion-type:jwt-bearer)
+// @Param client_assertion formData string false "Client assertion JWT"
+// @Success 200 {object} TokenResponse "Success"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 401 {object} Error "Invalid client"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /oauth/token [post]
+func (ctrl *Controller) Token(c *gin.Context) {
+	// Tokens and errors must not be cached (RFC 6749 section 5.1)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var param TokenParam
+	if err := c.ShouldBindWith(&param, binding.Form); err != nil {
+		ctrl.logger.Debug("Invalid token request", zap.Error(err))
+		ctrl.tokenError(c, errInvalidRequest("the request must be a form"))
+		return
+	}
+
+	creds, err := clientCredentials(c, param)
+	if err != nil {
+		ctrl.logger.Debug("Invalid client credentials", zap.Error(err))
+		ctrl.tokenError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.Token(ctx, param, creds)
+	if err != nil {
+		ctrl.logger.Info("Token request rejected", zap.String("client_id", creds.ClientID), zap.Error(err))
+		var oauthErr *Error
+		if creds.Basic && errors.As(err, &oauthErr) && oauthErr.Code == codeInvalidClient {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		ctrl.tokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UserInfo godoc
+// @Summary OpenID Connect userinfo endpoint
+// @Description Get the claims about the user that the access token's scopes release. The token must have the openid scope. Errors follow RFC 6750 rather than the usual envelope.
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer access token from the token endpoint"
+// @Success 200 {object} UserInfoResponse "Success"
+// @Failure 401 {object} Error "Invalid token"
+// @Failure 403 {object} Error "Insufficient scope"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /oauth/userinfo [get]
+// @Router /oauth/userinfo [post]
+func (ctrl *Controller) UserInfo(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		// No error code when the request carried no token (RFC 6750 section 3.1)
+		c.Header("WWW-Authenticate", `Bearer realm="oauth"`)
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	accessToken := strings.TrimSpace(strings.TrimPrefix(authHeader, bearerPrefix))
+
+	ctx := c.Request.Context()
+	info, err := ctrl.service.UserInfo(ctx, accessToken)
+	if err != nil {
+		ctrl.logger.Info("UserInfo request rejected", zap.Error(err))
+		var oauthErr *Error
+		if errors.As(err, &oauthErr) && oauthErr.Code != codeServerError {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="oauth", error=%q, error_description=%q`, oauthErr.Code, oauthErr.Description))
+		}
+		ctrl.tokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// Discovery godoc
+// @Summary OpenID Connect discovery
+// @Description Get the OpenID Provider metadata, served at the issuer's /.well-known/openid-configuration
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} ProviderMetadata "Success"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /.well-known/openid-configuration [get]
+func (ctrl *Controller) Discovery(c *gin.Context) {
+	ctx := c.Request.Context()
+	metadata, err := ctrl.service.Metadata(ctx)
+	if err != nil {
+		ctrl.logger.Error("Discovery error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", discoveryCacheControl)
+	c.JSON(http.StatusOK, metadata)
+}
+
+func (ctrl *Controller) tokenError(c *gin.Context, err error) {
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) {
+		oauthErr = errServer(err)
+	}
+	if oauthErr.Code == codeServerError {
+		ctrl.logger.Error("Token error", zap.Error(err))
+	}
+	c.AbortWithStatusJSON(oauthErr.Status, oauthErr)
+}
+
+// clientCredentials reads the client from HTTP Basic, the form or a client
+// assertion. Clients must use only one of them.
+func clientCredentials(c *gin.Context, param TokenParam) (ClientCredentials, error) {
+	username, password, ok := c.Request.BasicAuth()
+	if param.ClientAssertionType != "" || param.ClientAssertion != "" {
+		if ok || param.ClientSecret != "" {
+			return ClientCredentials{}, errInvalidRequest("use one client authentication method")
+		}
+		if param.ClientAssertionType != clientAssertionTypeJWTBearer || param.ClientAssertion == "" {
+			return ClientCredentials{}, errInvalidRequest("client_assertion_type must be " + clientAssertionTypeJWTBearer)
+		}
+		return ClientCredentials{ClientID: param.ClientID, Assertion: param.ClientAssertion}, nil
+	}
+
+	if !ok {
+		return ClientCredentials{ClientID: param.ClientID, ClientSecret: param.ClientSecret}, nil
+	}
+	if param.ClientSecret != "" {
+		return ClientCredentials{}, errInvalidRequest("use one client authentication method")
+	}
+
+	// Both parts are form encoded before Base64 (RFC 6749 section 2.3.1)
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return ClientCredentials{}, errInvalidClient()
+	}
+	secret, err := url.QueryUnescape(password)
+	if err != nil {
+		return ClientCredentials{}, errInvalidClient()
+	}
+	if param.ClientID != "" && param.ClientID != clientID {
+		return ClientCredentials{}, errInvalidRequest("client_id does not match the Authorization header")
+	}
+
+	return ClientCredentials{ClientID: clientID, ClientSecret: secret, Basic: true}, nil
+}
+
+// ListClients godoc
+// @Summary List OAuth clients
+// @Description List the registered OAuth clients
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]Client} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients [get]
+func (ctrl *Controller) ListClients(c *gin.Context) {
+	ctx := c.Request.Context()
+	clients, err := ctrl.service.ListClients(ctx)
+	if err != nil {
+		ctrl.logger.Error("ListClients error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.ClientField), clients)
+}
+
+// GetClient godoc
+// @Summary Get OAuth client
+// @Description Get a registered OAuth client
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Client record ID"
+// @Success 200 {object} response.Response{data=Client} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Client not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients/{id} [get]
+func (ctrl *Controller) GetClient(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid client ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.ClientField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := ctrl.service.GetClient(ctx, id)
+	if err != nil {
+		ctrl.logger.Error("GetClient error", zap.String("id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.ClientField), client)
+}
+
+// CreateClient godoc
+// @Summary Create OAuth client
+// @Description Register an OAuth client. Confidential clients without a key set get a client secret, which is only returned in this response.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body CreateClientParam true "Client"
+// @Success 201 {object} response.Response{data=ClientSecretResponse} "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 409 {object} response.Response "Client ID already exists"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients [post]
+func (ctrl *Controller) CreateClient(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[CreateClientParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.CreateClient(ctx, adminID, param)
+	if err != nil {
+		ctrl.logger.Error("CreateClient error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.ClientField), resp)
+}
+
+// UpdateClient godoc
+// @Summary Update OAuth client
+// @Description Change the name, description, redirect URIs, scopes or keys of an OAuth client. Fields left out are kept.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Client record ID"
+// @Param body body UpdateClientParam true "Fields to change"
+// @Success 200 {object} response.Response{data=Client} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Client not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients/{id} [patch]
+func (ctrl *Controller) UpdateClient(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid client ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.ClientField))
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[UpdateClientParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := ctrl.service.UpdateClient(ctx, adminID, id, param)
+	if err != nil {
+		ctrl.logger.Error("UpdateClient error", zap.String("id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.ClientField), client)
+}
+
+// DisableClient godoc
+// @Summary Disable OAuth client
+// @Description Stop an OAuth client from authorizing users or getting tokens
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Client record ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Client not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients/{id}/disable [post]
+func (ctrl *Controller) DisableClient(c *gin.Context) {
+	ctrl.setClientActive(c, false)
+}
+
+// EnableClient godoc
+// @Summary Enable OAuth client
+// @Description Allow a disabled OAuth client again
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Client record ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Client not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients/{id}/enable [post]
+func (ctrl *Controller) EnableClient(c *gin.Context) {
+	ctrl.setClientActive(c, true)
+}
+
+func (ctrl *Controller) setClientActive(c *gin.Context, active bool) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid client ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.ClientField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.SetClientActive(ctx, adminID, id, active)
+	if err != nil {
+		ctrl.logger.Error("SetClientActive error", zap.String("id", id.String()), zap.Bool("active", active), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.ClientField), nil)
+}
+
+// RotateClientSecret godoc
+// @Summary Rotate OAuth client secret
+// @Description Replace the secret of a confidential client. The old secret stops working at once and the new one is only returned in this response.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Client record ID"
+// @Success 200 {object} response.Response{data=ClientSecretResponse} "Success"
+// @Failure 400 {object} response.Response "Public client"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Client not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients/{id}/secret [post]
+func (ctrl *Controller) RotateClientSecret(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid client ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.ClientField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.RotateClientSecret(ctx, adminID, id)
+	if err != nil {
+		ctrl.logger.Error("RotateClientSecret error", zap.String("id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XChanged.WithField(authconsts.ClientField), resp)
+}
+
+// DeleteClient godoc
+// @Summary Delete OAuth client
+// @Description Delete an OAuth client. Tokens it already holds stay valid until they expire.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Client record ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Client not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/oauth/clients/{id} [delete]
+func (ctrl *Controller) DeleteClient(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid client ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.ClientField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.DeleteClient(ctx, adminID, id)
+	if err != nil {
+		ctrl.logger.Error("DeleteClient error", zap.String("id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.ClientField), nil)
+}
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	token "auth-service/pkg/jwt"
+)
+
+type (
+	// AuthorizeParam is an authorization request (RFC 6749 section 4.1.1)
+	// with a PKCE challenge (RFC 7636).
+	AuthorizeParam struct {
+		ResponseType        string `form:"response_type" json:"response_type"`
+		ClientID            string `form:"client_id" json:"client_id"`
+		RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+		Scope               string `form:"scope" json:"scope"`
+		State               string `form:"state" json:"state"`
+		CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+		CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+		Nonce               string `form:"nonce" json:"nonce"` // echoed in the ID token
+	}
+
+	// ConsentParam is the user's answer to an authorization request, sent by
+	// the consent page.
+	ConsentParam struct {
+		AuthorizeParam
+		Approved bool `json:"approved"`
+	}
+
+	// ConsentResponse describes an authorization request for the consent page.
+	ConsentResponse struct {
+		ClientID    string   `json:"client_id"`
+		ClientName  string   `json:"client_name"`
+		RedirectURI string   `json:"redirect_uri"`
+		Scopes      []string `json:"scopes"`
+	}
+
+	// AuthorizeResponse is where the consent page sends the browser next,
+	// with either a code or an error.
+	AuthorizeResponse struct {
+		RedirectURI string `json:"redirect_uri"`
+	}
+
+	// TokenParam is a token request (RFC 6749 sections 4.1.3 and 4.4.2),
+	// posted as a form.
+	TokenParam struct {
+		GrantType           string `form:"grant_type"`
+		Code                string `form:"code"`
+		RedirectURI         string `form:"redirect_uri"`
+		CodeVerifier        string `form:"code_verifier"`
+		Scope               string `form:"scope"`
+		ClientID            string `form:"client_id"`
+		ClientSecret        string `form:"client_secret"`
+		ClientAssertionType string `form:"client_assertion_type"`
+		ClientAssertion     string `form:"client_assertion"`
+	}
+
+	// ClientCredentials authenticate a client at the token endpoint with a
+	// secret, from the Authorization header or the form, or with a signed
+	// assertion.
+	ClientCredentials struct {
+		ClientID     string
+		ClientSecret string
+		Basic        bool // sent with HTTP Basic
+		Assertion    string
+	}
+
+	TokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Scope       string `json:"scope,omitempty"`
+		IDToken     string `json:"id_token,omitempty"` // with the openid scope
+	}
+
+	// UserInfoResponse holds the claims about the user that the access
+	// token's scopes release.
+	UserInfoResponse struct {
+		Subject string `json:"sub"`
+		token.ProfileClaims
+	}
+
+	// CreateClientParam registers a client. ClientID is generated when
+	// empty, and IsConfidential defaults to true.
+	CreateClientParam struct {
+		ClientID       *string  `json:"client_id" validate:"omitempty,min=3,max=100"`
+		Name           string   `json:"name" validate:"required,max=255"`
+		Description    *string  `json:"description" validate:"omitempty,max=1000"`
+		RedirectURIs   []string `json:"redirect_uris" validate:"max=20,dive,required,max=2000"`
+		Scopes         []string `json:"scopes" validate:"max=50,dive,required,max=255"`
+		IsConfidential *bool    `json:"is_confidential"`
+		JWKS           *KeySet  `json:"jwks"`
+	}
+
+	// UpdateClientParam changes the fields that are set. Lists replace the
+	// current ones; an empty jwks removes the client's keys.
+	UpdateClientParam struct {
+		Name         *string  `json:"name" validate:"omitempty,min=1,max=255"`
+		Description  *string  `json:"description" validate:"omitempty,max=1000"`
+		RedirectURIs []string `json:"redirect_uris" validate:"omitempty,max=20,dive,required,max=2000"`
+		Scopes       []string `json:"scopes" validate:"omitempty,max=50,dive,required,max=255"`
+		JWKS         *KeySet  `json:"jwks"`
+	}
+
+	// ClientSecretResponse carries a new client secret. It is only ever
+	// returned here; the client stores a hash.
+	ClientSecretResponse struct {
+		Client       *Client `json:"client"`
+		ClientSecret string  `json:"client_secret,omitempty"`
+	}
+
+	// ProviderMetadata is the OpenID Connect discovery document.
+	ProviderMetadata struct {
+		Issuer                                     string   `json:"issuer"`
+		AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+		TokenEndpoint                              string   `json:"token_endpoint"`
+		UserInfoEndpoint                           string   `json:"userinfo_endpoint"`
+		JWKSURI                                    string   `json:"jwks_uri"`
+		ScopesSupported                            []string `json:"scopes_supported"`
+		ResponseTypesSupported                     []string `json:"response_types_supported"`
+		ResponseModesSupported                     []string `json:"response_modes_supported"`
+		GrantTypesSupported                        []string `json:"grant_types_supported"`
+		SubjectTypesSupported                      []string `json:"subject_types_supported"`
+		IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+		TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+		TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+		CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+		ClaimsSupported                            []string `json:"claims_supported"`
+	}
+)
@@ -0,0 +1,75 @@
+package oauth
+
+import (
+	"net/http"
+)
+
+// Error codes from RFC 6749 sections 4.1.2.1 and 5.2
+const (
+	codeInvalidRequest          = "invalid_request"
+	codeInvalidClient           = "invalid_client"
+	codeInvalidGrant            = "invalid_grant"
+	codeUnauthorizedClient      = "unauthorized_client"
+	codeUnsupportedGrantType    = "unsupported_grant_type"
+	codeUnsupportedResponseType = "unsupported_response_type"
+	codeInvalidScope            = "invalid_scope"
+	codeAccessDenied            = "access_denied"
+	codeServerError             = "server_error"
+
+	// RFC 6750 section 3.1, for the userinfo endpoint
+	codeInvalidToken      = "invalid_token"
+	codeInsufficientScope = "insufficient_scope"
+)
+
+// Error is an OAuth 2.0 error. The token endpoint returns it as the body that
+// client libraries expect, and the authorization endpoint as query parameters
+// on the redirect URI.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+	cause       error
+}
+
+func (e *Error) Error() string {
+	msg := e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func newError(code string, status int, description string) *Error {
+	return &Error{Code: code, Description: description, Status: status}
+}
+
+func errInvalidRequest(description string) *Error {
+	return newError(codeInvalidRequest, http.StatusBadRequest, description)
+}
+
+func errInvalidClient() *Error {
+	return newError(codeInvalidClient, http.StatusUnauthorized, "client authentication failed")
+}
+
+func errInvalidGrant(description string) *Error {
+	return newError(codeInvalidGrant, http.StatusBadRequest, description)
+}
+
+func errInvalidScope(description string) *Error {
+	return newError(codeInvalidScope, http.StatusBadRequest, description)
+}
+
+func errInvalidToken(description string) *Error {
+	return newError(codeInvalidToken, http.StatusUnauthorized, description)
+}
+
+func errServer(cause error) *Error {
+	return &Error{Code: codeServerError, Status: http.StatusInternalServerError, cause: cause}
+}
@@ -0,0 +1,116 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	responseTypeCode             = "code"
+	grantTypeAuthorizationCode   = "authorization_code"
+	grantTypeClientCredentials   = "client_credentials"
+	challengeMethodS256          = "S256"
+	tokenTypeBearer              = "Bearer"
+	clientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// Endpoints relative to the issuer. TokenPath is also an accepted
+	// client assertion audience.
+	AuthorizePath = "/api/v1/oauth/authorize"
+	TokenPath     = "/api/v1/oauth/token"
+	UserInfoPath  = "/api/v1/oauth/userinfo"
+	JWKSPath      = "/.well-known/jwks.json"
+)
+
+var (
+	// RFC 7636 section 4.1; an S256 challenge is a base64url SHA-256 digest
+	verifierPattern  = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+	challengePattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{43}$`)
+)
+
+// verifyPKCE checks the verifier against an S256 challenge.
+func verifyPKCE(verifier, challenge string) bool {
+	if !verifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// grantedScopes returns the requested scopes, or all of the client's when
+// none were requested.
+func grantedScopes(client *Client, requested string) ([]string, *Error) {
+	fields := strings.Fields(requested)
+	if len(fields) == 0 {
+		return append([]string{}, client.Scopes...), nil
+	}
+
+	scopes := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, scope := range fields {
+		if !client.AllowsScope(scope) {
+			return nil, errInvalidScope("scope " + scope + " is not allowed for this client")
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// withQuery adds params to the query of uri, keeping any it already has.
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// errorRedirect sends an authorization error back to the client (RFC 6749
+// section 4.1.2.1).
+func errorRedirect(redirectURI, state string, oauthErr *Error) string {
+	params := url.Values{"error": {oauthErr.Code}}
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return withQuery(redirectURI, params)
+}
+
+func (p *AuthorizeParam) query() url.Values {
+	params := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	set("response_type", p.ResponseType)
+	set("client_id", p.ClientID)
+	set("redirect_uri", p.RedirectURI)
+	set("scope", p.Scope)
+	set("state", p.State)
+	set("code_challenge", p.CodeChallenge)
+	set("code_challenge_method", p.CodeChallengeMethod)
+	set("nonce", p.Nonce)
+	return params
+}
@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"auth-service/db"
+	token "auth-service/pkg/jwt"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Client is an application allowed to request tokens. Public clients, such as
+// single-page and mobile apps, cannot keep a secret and must use PKCE.
+type Client struct {
+	ID               uuid.UUID      `json:"id" db:"id"`
+	ClientID         string         `json:"client_id" db:"client_id"`
+	ClientSecretHash string         `json:"-" db:"client_secret_hash"` // empty for public clients
+	Name             string         `json:"name" db:"name"`
+	Description      *string        `json:"description,omitempty" db:"description"`
+	RedirectURIs     db.StringArray `json:"redirect_uris" db:"redirect_uris"`
+	Scopes           db.StringArray `json:"scopes" db:"scopes"`
+	JWKS             *KeySet        `json:"jwks,omitempty" db:"jwks"` // for private_key_jwt
+	IsConfidential   bool           `json:"is_confidential" db:"is_confidential"`
+	IsActive         bool           `json:"is_active" db:"is_active"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+func (Client) TableName() string {
+	return "oauth_clients"
+}
+
+// HasRedirectURI reports whether uri is registered. URIs must match exactly.
+func (c *Client) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope reports whether the client may be granted scope.
+func (c *Client) AllowsScope(scope string) bool {
+	for _, allowed := range c.Scopes {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// KeySet holds the public keys a client signs its assertions with.
+type KeySet token.JWKSet
+
+func (k KeySet) Value() (driver.Value, error) {
+	return json.Marshal(token.JWKSet(k))
+}
+
+func (k *KeySet) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported jwks type")
+	}
+	return json.Unmarshal(data, (*token.JWKSet)(k))
+}
@@ -0,0 +1,150 @@
+package oauth
+
+import (
+	userModel "auth-service/internal/user"
+	token "auth-service/pkg/jwt"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"net/http"
+	"slices"
+)
+
+// OpenID Connect scopes (OpenID Connect Core sections 3.1.2.1 and 5.4)
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// oidcScopes only make sense on behalf of a user
+var oidcScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+func hasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope)
+}
+
+func withoutScopes(scopes, remove []string) []string {
+	kept := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(remove, scope) {
+			kept = append(kept, scope)
+		}
+	}
+	return kept
+}
+
+// profileClaims returns the claims about user that the scopes release.
+func profileClaims(user *userModel.User, scopes []string) token.ProfileClaims {
+	var claims token.ProfileClaims
+	if hasScope(scopes, ScopeProfile) {
+		claims.PreferredUsername = user.Username
+		claims.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if hasScope(scopes, ScopeEmail) && user.Email != nil {
+		claims.Email = user.Email
+		claims.EmailVerified = &user.EmailVerified
+	}
+	return claims
+}
+
+// idToken tells the client who authorized the grant and how they logged in.
+func (s *service) idToken(ctx context.Context, grant *authorizationCode) (string, error) {
+	if !s.oidc {
+		return "", errServer(errors.New("openid connect is not enabled"))
+	}
+
+	user, err := s.userSvc.GetUser(ctx, grant.UserID)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		return "", errInvalidGrant("the user no longer exists")
+	} else if err != nil {
+		return "", errServer(err)
+	}
+
+	idToken, err := token.GenerateIDToken(grant.UserID.String(), grant.ClientID, token.IDTokenClaims{
+		Nonce:         grant.Nonce,
+		AuthTime:      grant.AuthTime.Unix(),
+		AMR:           grant.AMR,
+		ProfileClaims: profileClaims(user, grant.Scopes),
+	})
+	if err != nil {
+		return "", errServer(err)
+	}
+	return idToken, nil
+}
+
+func (s *service) UserInfo(ctx context.Context, accessToken string) (*UserInfoResponse, error) {
+	claims, err := token.ParseOAuthAccessToken(accessToken)
+	if err != nil {
+		return nil, errInvalidToken("the access token is invalid or expired")
+	}
+
+	blacklisted, err := token.IsTokenBlacklisted(ctx, accessToken)
+	if err != nil {
+		return nil, errServer(err)
+	} else if blacklisted {
+		return nil, errInvalidToken("the access token was revoked")
+	}
+
+	if !hasScope(claims.Scopes(), ScopeOpenID) {
+		return nil, newError(codeInsufficientScope, http.StatusForbidden, "the access token lacks the openid scope")
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, errInvalidToken("the access token was not issued for a user")
+	}
+
+	revoked, err := token.IsRevokedForUser(ctx, userID, claims.IssuedAt)
+	if err != nil {
+		return nil, errServer(err)
+	} else if revoked {
+		return nil, errInvalidToken("the access token was revoked")
+	}
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		return nil, errInvalidToken("the user no longer exists")
+	} else if err != nil {
+		return nil, errServer(err)
+	} else if !user.IsActive {
+		return nil, errInvalidToken("the user is deactivated")
+	}
+
+	return &UserInfoResponse{
+		Subject:       user.ID.String(),
+		ProfileClaims: profileClaims(user, claims.Scopes()),
+	}, nil
+}
+
+func (s *service) Metadata(ctx context.Context) (*ProviderMetadata, error) {
+	const op = "service.Metadata"
+
+	if !s.oidc {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(errors.New("openid connect needs an oauth issuer and an asymmetric jwt algorithm"))
+	}
+
+	return &ProviderMetadata{
+		Issuer:                           s.issuer,
+		AuthorizationEndpoint:            s.issuer + AuthorizePath,
+		TokenEndpoint:                    s.issuer + TokenPath,
+		UserInfoEndpoint:                 s.issuer + UserInfoPath,
+		JWKSURI:                          s.issuer + JWKSPath,
+		ScopesSupported:                  oidcScopes,
+		ResponseTypesSupported:           []string{responseTypeCode},
+		ResponseModesSupported:           []string{"query"},
+		GrantTypesSupported:              []string{grantTypeAuthorizationCode, grantTypeClientCredentials},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{token.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{
+			"client_secret_basic", "client_secret_post", "private_key_jwt", "none",
+		},
+		TokenEndpointAuthSigningAlgValuesSupported: token.AssertionAlgorithms,
+		CodeChallengeMethodsSupported:              []string{challengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr",
+			"preferred_username", "updated_at", "email", "email_verified",
+		},
+	}, nil
+}
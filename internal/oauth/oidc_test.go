@@ -0,0 +1,177 @@
+package oauth
+
+import (
+	userModel "auth-service/internal/user"
+	token "auth-service/pkg/jwt"
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeUserService only looks users up; other methods are not used.
+type fakeUserService struct {
+	userModel.Service
+	users map[uuid.UUID]*userModel.User
+}
+
+func (f *fakeUserService) GetUser(_ context.Context, id uuid.UUID) (*userModel.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, apperrors.ErrXNotFound
+	}
+	return user, nil
+}
+
+// newOIDCTestService returns a service that issues ID tokens for user. The
+// tests sign with HS256, which turns OpenID Connect off, so it is turned
+// back on here: the claims are what is under test, not the key.
+func newOIDCTestService(t *testing.T) (*service, *userModel.User) {
+	t.Helper()
+	s, repo := newTestService(t)
+	repo.clients["public-app"].Scopes = []string{"read", ScopeOpenID, ScopeProfile, ScopeEmail}
+
+	username, email := "ada", "ada@example.com"
+	user := &userModel.User{
+		ID:            uuid.New(),
+		Username:      &username,
+		Email:         &email,
+		EmailVerified: true,
+		IsActive:      true,
+		UpdatedAt:     time.Now().Add(-time.Hour).Truncate(time.Second),
+	}
+	s.userSvc = &fakeUserService{users: map[uuid.UUID]*userModel.User{user.ID: user}}
+	s.oidc = true
+	return s, user
+}
+
+func parseIDToken(t *testing.T, idToken string) *token.IDTokenClaims {
+	t.Helper()
+	var claims token.IDTokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, &claims); err != nil {
+		t.Fatalf("parse ID token: %v", err)
+	}
+	return &claims
+}
+
+func TestIDToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		scope       string
+		wantProfile bool
+		wantEmail   bool
+	}{
+		{name: "openid only", scope: "openid"},
+		{name: "profile", scope: "openid profile", wantProfile: true},
+		{name: "email", scope: "openid email", wantEmail: true},
+		{name: "profile and email", scope: "openid profile email", wantProfile: true, wantEmail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, user := newOIDCTestService(t)
+			param := authorizeParam("public-app", rfcChallenge)
+			param.Scope = tt.scope
+			param.Nonce = "n-0S6_WzA2Mj"
+
+			loginAt := time.Now()
+			redirect, err := s.Authorize(context.Background(), user.ID, uuid.New(), ConsentParam{AuthorizeParam: param, Approved: true})
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			code := queryOf(t, redirect).Get("code")
+
+			resp, err := exchange(s, code, rfcVerifier, ClientCredentials{ClientID: "public-app"})
+			if err != nil {
+				t.Fatalf("Token: %v", err)
+			}
+			if resp.IDToken == "" {
+				t.Fatal("no ID token for the openid scope")
+			}
+			claims := parseIDToken(t, resp.IDToken)
+
+			if claims.Issuer != testIssuer || claims.Subject != user.ID.String() {
+				t.Errorf("iss = %q, sub = %q", claims.Issuer, claims.Subject)
+			}
+			if !slices.Equal(claims.Audience, jwt.ClaimStrings{"public-app"}) {
+				t.Errorf("aud = %v, want the client", claims.Audience)
+			}
+			if claims.Nonce != param.Nonce {
+				t.Errorf("nonce = %q, want %q", claims.Nonce, param.Nonce)
+			}
+			if claims.AuthTime < loginAt.Add(-time.Minute).Unix() || claims.AuthTime > loginAt.Unix()+1 {
+				t.Errorf("auth_time = %d, want the session's login time", claims.AuthTime)
+			}
+			if !slices.Equal(claims.AMR, []string{"pwd"}) {
+				t.Errorf("amr = %v, want the session's methods", claims.AMR)
+			}
+
+			hasProfile := claims.PreferredUsername != nil && *claims.PreferredUsername == *user.Username &&
+				claims.UpdatedAt == user.UpdatedAt.Unix()
+			if hasProfile != tt.wantProfile || (!tt.wantProfile && (claims.PreferredUsername != nil || claims.UpdatedAt != 0)) {
+				t.Errorf("preferred_username = %v, updated_at = %d, want profile claims %v", claims.PreferredUsername, claims.UpdatedAt, tt.wantProfile)
+			}
+			hasEmail := claims.Email != nil && *claims.Email == *user.Email &&
+				claims.EmailVerified != nil && *claims.EmailVerified
+			if hasEmail != tt.wantEmail || (!tt.wantEmail && (claims.Email != nil || claims.EmailVerified != nil)) {
+				t.Errorf("email = %v, email_verified = %v, want email claims %v", claims.Email, claims.EmailVerified, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestIDToken_NotIssuedWithoutOpenIDScope(t *testing.T) {
+	s, user := newOIDCTestService(t)
+	param := authorizeParam("public-app", rfcChallenge)
+	param.Scope = "read profile"
+
+	redirect, err := s.Authorize(context.Background(), user.ID, uuid.New(), ConsentParam{AuthorizeParam: param, Approved: true})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	resp, err := exchange(s, queryOf(t, redirect).Get("code"), rfcVerifier, ClientCredentials{ClientID: "public-app"})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if resp.IDToken != "" {
+		t.Error("ID token issued without the openid scope")
+	}
+}
+
+func TestOpenIDConnect_DisabledWithHS256(t *testing.T) {
+	if token.SigningAlgorithm() != token.AlgHS256 {
+		t.Skipf("tokens are signed with %s", token.SigningAlgorithm())
+	}
+	s, repo := newTestService(t)
+	if s.oidc {
+		t.Fatal("OpenID Connect enabled with a symmetric signing key")
+	}
+	repo.clients["public-app"].Scopes = []string{"read", ScopeOpenID, ScopeProfile}
+
+	if _, err := s.Metadata(context.Background()); err == nil {
+		t.Error("discovery metadata published without OpenID Connect")
+	}
+
+	// Asking for openid is an error the client hears about
+	param := authorizeParam("public-app", rfcChallenge)
+	param.Scope = "openid profile"
+	query := authorize(t, s, param)
+	if query.Get("error") != codeInvalidScope {
+		t.Errorf("redirect = %v, want invalid_scope", query)
+	}
+
+	// The client's default scopes quietly leave it out
+	param.Scope = ""
+	code := issueCode(t, s, param)
+	resp, err := exchange(s, code, rfcVerifier, ClientCredentials{ClientID: "public-app"})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if resp.Scope != "read" || resp.IDToken != "" {
+		t.Errorf("scope = %q, id_token = %q, want read and no ID token", resp.Scope, resp.IDToken)
+	}
+}
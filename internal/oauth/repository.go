@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, client *Client) error
+	FindByID(ctx context.Context, id uuid.UUID) (*Client, error)
+	FindByClientID(ctx context.Context, clientID string) (*Client, error)
+	List(ctx context.Context) ([]Client, error)
+	// UpdateColumns updates the given columns, including zero values.
+	UpdateColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, client *Client) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (*Client, error) {
+	var client Client
+	result := r.db.WithContext(ctx).First(&client, id)
+	return &client, result.Error
+}
+
+func (r *repository) FindByClientID(ctx context.Context, clientID string) (*Client, error) {
+	var client Client
+	result := r.db.WithContext(ctx).
+		Where("client_id = ?", clientID).
+		First(&client)
+	return &client, result.Error
+}
+
+func (r *repository) List(ctx context.Context) ([]Client, error) {
+	var clients []Client
+	result := r.db.WithContext(ctx).
+		Order("created_at").
+		Find(&clients)
+	return clients, result.Error
+}
+
+func (r *repository) UpdateColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	result := r.db.WithContext(ctx).
+		Model(&Client{}).
+		Where("id = ?", id).
+		Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&Client{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package oauth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/session"
+	authconsts "auth-service/internal/shared/consts"
+	userModel "auth-service/internal/user"
+	token "auth-service/pkg/jwt"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultCodeTTL = time.Minute
+
+type Service interface {
+	// ConsentURL checks an authorization request and returns where to send
+	// the browser: the consent page, or the client with an error. Requests
+	// with an unknown client or redirect URI fail instead, since redirecting
+	// them would make this an open redirector.
+	ConsentURL(ctx context.Context, param AuthorizeParam) (string, error)
+	// ConsentDetails describes a valid authorization request for the
+	// consent page.
+	ConsentDetails(ctx context.Context, param AuthorizeParam) (*ConsentResponse, error)
+	// Authorize records the user's answer and returns the client's redirect
+	// URI with a code, or with access_denied. The session is the one the
+	// user approved from; its login is what the ID token reports.
+	Authorize(ctx context.Context, userID, sessionID uuid.UUID, param ConsentParam) (string, error)
+	// Token serves the token endpoint. Failures are always *Error.
+	Token(ctx context.Context, param TokenParam, creds ClientCredentials) (*TokenResponse, error)
+	// UserInfo serves the OpenID Connect userinfo endpoint. Failures are
+	// always *Error.
+	UserInfo(ctx context.Context, accessToken string) (*UserInfoResponse, error)
+	// Metadata returns the OpenID Connect discovery document.
+	Metadata(ctx context.Context) (*ProviderMetadata, error)
+
+	ListClients(ctx context.Context) ([]Client, error)
+	GetClient(ctx context.Context, id uuid.UUID) (*Client, error)
+	// CreateClient registers a client. Confidential clients without a key
+	// set get a secret, which is returned only this once.
+	CreateClient(ctx context.Context, adminID uuid.UUID, param CreateClientParam) (*ClientSecretResponse, error)
+	UpdateClient(ctx context.Context, adminID, id uuid.UUID, param UpdateClientParam) (*Client, error)
+	// SetClientActive enables or disables a client. Disabled clients cannot
+	// authorize users or get tokens.
+	SetClientActive(ctx context.Context, adminID, id uuid.UUID, active bool) error
+	// RotateClientSecret replaces the secret of a confidential client. The
+	// old secret stops working at once.
+	RotateClientSecret(ctx context.Context, adminID, id uuid.UUID) (*ClientSecretResponse, error)
+	DeleteClient(ctx context.Context, adminID, id uuid.UUID) error
+}
+
+type service struct {
+	repo           Repository
+	userSvc        userModel.Service
+	sessionSvc     session.Service
+	securityLogSvc securitylog.Service
+	codes          *codeStore
+	consentURL     string
+	issuer         string
+	oidc           bool     // whether ID tokens can be issued
+	audiences      []string // accepted in client assertions
+	logger         *zap.Logger
+}
+
+func NewService(cfg *config.Config, repo Repository, userSvc userModel.Service, sessionSvc session.Service, securityLogSvc securitylog.Service, logger *zap.Logger) Service {
+	codeTTL := cfg.OAuth.CodeTTL
+	if codeTTL <= 0 {
+		codeTTL = defaultCodeTTL
+	}
+
+	issuer := strings.TrimSuffix(cfg.OAuth.Issuer, "/")
+	var audiences []string
+	if issuer != "" {
+		audiences = []string{issuer, issuer + TokenPath}
+	}
+
+	// Clients verify ID tokens with the published keys, and HS256 keys are
+	// never published: an HS256 ID token is one no client can check.
+	oidc := issuer != "" && token.SigningAlgorithm() != token.AlgHS256
+	if issuer != "" && !oidc {
+		logger.Warn("OpenID Connect is disabled because tokens are signed with a symmetric key",
+			zap.String("algorithm", token.SigningAlgorithm()))
+	}
+
+	return &service{
+		repo:           repo,
+		userSvc:        userSvc,
+		sessionSvc:     sessionSvc,
+		securityLogSvc: securityLogSvc,
+		codes:          &codeStore{ttl: codeTTL},
+		consentURL:     cfg.OAuth.ConsentURL,
+		issuer:         issuer,
+		oidc:           oidc,
+		audiences:      audiences,
+		logger:         logger,
+	}
+}
+
+func (s *service) ConsentURL(ctx context.Context, param AuthorizeParam) (string, error) {
+	const op = "service.ConsentURL"
+
+	client, err := s.clientForRedirect(ctx, op, param)
+	if err != nil {
+		return "", err
+	}
+
+	if _, oauthErr := s.checkAuthorizeRequest(client, param); oauthErr != nil {
+		return errorRedirect(param.RedirectURI, param.State, oauthErr), nil
+	}
+
+	if s.consentURL == "" {
+		return "", apperrors.ErrInternalServerError.WithOp(op).Wrap(errors.New("oauth consent_url is not configured"))
+	}
+	return withQuery(s.consentURL, param.query()), nil
+}
+
+func (s *service) ConsentDetails(ctx context.Context, param AuthorizeParam) (*ConsentResponse, error) {
+	const op = "service.ConsentDetails"
+
+	client, err := s.clientForRedirect(ctx, op, param)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, oauthErr := s.checkAuthorizeRequest(client, param)
+	if oauthErr != nil {
+		return nil, apperrors.ErrBadRequest.WithOp(op).Wrap(oauthErr)
+	}
+
+	return &ConsentResponse{
+		ClientID:    client.ClientID,
+		ClientName:  client.Name,
+		RedirectURI: param.RedirectURI,
+		Scopes:      scopes,
+	}, nil
+}
+
+func (s *service) Authorize(ctx context.Context, userID, sessionID uuid.UUID, param ConsentParam) (string, error) {
+	const op = "service.Authorize"
+
+	client, err := s.clientForRedirect(ctx, op, param.AuthorizeParam)
+	if err != nil {
+		return "", err
+	}
+
+	scopes, oauthErr := s.checkAuthorizeRequest(client, param.AuthorizeParam)
+	if oauthErr != nil {
+		return errorRedirect(param.RedirectURI, param.State, oauthErr), nil
+	}
+
+	if !param.Approved {
+		oauthErr = newError(codeAccessDenied, http.StatusForbidden, "the user denied the request")
+		return errorRedirect(param.RedirectURI, param.State, oauthErr), nil
+	}
+
+	userSession, err := s.sessionSvc.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := s.codes.issue(ctx, &authorizationCode{
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   param.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: param.CodeChallenge,
+		Nonce:         param.Nonce,
+		AuthTime:      userSession.CreatedAt,
+		AMR:           userSession.AuthMethods,
+	})
+	if err != nil {
+		return "", apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	params := url.Values{"code": {code}}
+	if param.State != "" {
+		params.Set("state", param.State)
+	}
+	return withQuery(param.RedirectURI, params), nil
+}
+
+// clientForRedirect returns the active client of the request if it
+// registered the redirect URI.
+func (s *service) clientForRedirect(ctx context.Context, op string, param AuthorizeParam) (*Client, error) {
+	if param.ClientID == "" {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.ClientField).WithOp(op)
+	}
+
+	client, err := s.repo.FindByClientID(ctx, param.ClientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.ClientField).WithOp(op)
+	} else if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if !client.IsActive {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.ClientField).WithOp(op)
+	}
+
+	if !client.HasRedirectURI(param.RedirectURI) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.RedirectURIField).WithOp(op)
+	}
+
+	return client, nil
+}
+
+// checkAuthorizeRequest validates the parts of the request that are reported
+// to the client, and returns the scopes to grant.
+func (s *service) checkAuthorizeRequest(client *Client, param AuthorizeParam) ([]string, *Error) {
+	if param.ResponseType != responseTypeCode {
+		return nil, newError(codeUnsupportedResponseType, http.StatusBadRequest, "only the code response type is supported")
+	}
+
+	if param.CodeChallenge == "" {
+		if !client.IsConfidential {
+			return nil, errInvalidRequest("public clients must send a PKCE code_challenge")
+		}
+	} else if param.CodeChallengeMethod != challengeMethodS256 {
+		return nil, errInvalidRequest("code_challenge_method must be S256")
+	} else if !challengePattern.MatchString(param.CodeChallenge) {
+		return nil, errInvalidRequest("code_challenge is malformed")
+	}
+
+	scopes, oauthErr := grantedScopes(client, param.Scope)
+	if oauthErr != nil {
+		return nil, oauthErr
+	} else if s.oidc {
+		return scopes, nil
+	} else if param.Scope == "" {
+		// The client's default scopes, minus what cannot be granted
+		return withoutScopes(scopes, oidcScopes), nil
+	} else if hasScope(scopes, ScopeOpenID) {
+		return nil, errInvalidScope("openid is not supported by this server")
+	}
+	return scopes, nil
+}
+
+func (s *service) Token(ctx context.Context, param TokenParam, creds ClientCredentials) (*TokenResponse, error) {
+	switch param.GrantType {
+	case grantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, param, creds)
+	case grantTypeClientCredentials:
+		return s.clientCredentialsGrant(ctx, param, creds)
+	case "":
+		return nil, errInvalidRequest("grant_type is required")
+	default:
+		return nil, newError(codeUnsupportedGrantType, http.StatusBadRequest, "")
+	}
+}
+
+func (s *service) exchangeCode(ctx context.Context, param TokenParam, creds ClientCredentials) (*TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.Code == "" {
+		return nil, errInvalidRequest("code is required")
+	}
+
+	// The code is spent even when a check below fails, so it cannot be retried
+	grant, err := s.codes.take(ctx, param.Code)
+	if errors.Is(err, errCodeNotFound) {
+		return nil, errInvalidGrant("authorization code is invalid or expired")
+	} else if err != nil {
+		return nil, errServer(err)
+	}
+
+	if grant.ClientID != client.ClientID {
+		return nil, errInvalidGrant("authorization code was issued to another client")
+	}
+	if grant.RedirectURI != param.RedirectURI {
+		return nil, errInvalidGrant("redirect_uri does not match the authorization request")
+	}
+
+	if grant.CodeChallenge != "" {
+		if !verifyPKCE(param.CodeVerifier, grant.CodeChallenge) {
+			return nil, errInvalidGrant("code_verifier does not match the code_challenge")
+		}
+	} else if param.CodeVerifier != "" {
+		// A verifier without a challenge means the challenge was stripped
+		return nil, errInvalidGrant("the authorization request had no code_challenge")
+	}
+
+	accessToken, expiresIn, err := token.GenerateOAuthAccessToken(grant.UserID.String(), client.ClientID, grant.Scopes)
+	if err != nil {
+		return nil, errServer(err)
+	}
+
+	resp := &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int64(expiresIn.Seconds()),
+		Scope:       joinScopes(grant.Scopes),
+	}
+
+	if hasScope(grant.Scopes, ScopeOpenID) {
+		resp.IDToken, err = s.idToken(ctx, grant)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// clientCredentialsGrant issues a token to the client itself, for service to
+// service calls. The token's subject is the client ID.
+func (s *service) clientCredentialsGrant(ctx context.Context, param TokenParam, creds ClientCredentials) (*TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	} else if !client.IsConfidential {
+		return nil, newError(codeUnauthorizedClient, http.StatusBadRequest, "public clients cannot use client_credentials")
+	}
+
+	scopes, oauthErr := grantedScopes(client, param.Scope)
+	if oauthErr != nil {
+		return nil, oauthErr
+	} else if param.Scope == "" {
+		// The default is what the client may ask for itself
+		scopes = withoutScopes(scopes, oidcScopes)
+	} else if hasScope(scopes, ScopeOpenID) {
+		// There is no user to identify
+		return nil, errInvalidScope("openid cannot be used with client_credentials")
+	}
+
+	accessToken, expiresIn, err := token.GenerateOAuthAccessToken(client.ClientID, client.ClientID, scopes)
+	if err != nil {
+		return nil, errServer(err)
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int64(expiresIn.Seconds()),
+		Scope:       joinScopes(scopes),
+	}, nil
+}
+
+// authenticateClient identifies the client. Confidential clients must prove
+// it with their secret or a signed assertion; public clients only name
+// themselves.
+func (s *service) authenticateClient(ctx context.Context, creds ClientCredentials) (*Client, error) {
+	if creds.Assertion != "" {
+		return s.authenticateAssertion(ctx, creds)
+	}
+
+	if creds.ClientID == "" {
+		return nil, errInvalidClient()
+	}
+
+	client, err := s.repo.FindByClientID(ctx, creds.ClientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInvalidClient()
+	} else if err != nil {
+		return nil, errServer(err)
+	} else if !client.IsActive {
+		return nil, errInvalidClient()
+	}
+
+	if client.IsConfidential {
+		if creds.ClientSecret == "" || client.ClientSecretHash == "" {
+			return nil, errInvalidClient()
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(client.ClientSecretHash), []byte(creds.ClientSecret))
+		if err != nil {
+			s.logger.Debug("Client secret mismatch", zap.String("client_id", client.ClientID))
+			return nil, errInvalidClient()
+		}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,354 @@
+package oauth
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/session"
+	"auth-service/internal/testutil"
+	token "auth-service/pkg/jwt"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer      = "https://auth.example.com"
+	testRedirectURI = "https://app.example.com/callback"
+	testSecret      = "client-secret"
+
+	// RFC 7636 appendix B
+	rfcVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfcChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET_KEY", "test-secret-key-of-at-least-32-bytes")
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+type fakeRepository struct {
+	clients map[string]*Client
+}
+
+func (r *fakeRepository) Create(_ context.Context, client *Client) error {
+	if _, ok := r.clients[client.ClientID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	r.clients[client.ClientID] = client
+	return nil
+}
+
+func (r *fakeRepository) FindByID(_ context.Context, id uuid.UUID) (*Client, error) {
+	for _, client := range r.clients {
+		if client.ID == id {
+			return client, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRepository) FindByClientID(_ context.Context, clientID string) (*Client, error) {
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return client, nil
+}
+
+func (r *fakeRepository) List(context.Context) ([]Client, error) {
+	clients := make([]Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, *client)
+	}
+	return clients, nil
+}
+
+// UpdateColumns applies the columns that the service does not already set
+// on the client it loaded.
+func (r *fakeRepository) UpdateColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	client, err := r.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	for column, value := range columns {
+		switch column {
+		case "is_active":
+			client.IsActive = value.(bool)
+		case "client_secret_hash":
+			client.ClientSecretHash = value.(string)
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	client, err := r.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	delete(r.clients, client.ClientID)
+	return nil
+}
+
+type fakeSecurityLog struct {
+	events []securitylog.Event
+}
+
+func (l *fakeSecurityLog) Record(_ context.Context, event securitylog.Event) {
+	l.events = append(l.events, event)
+}
+
+// fakeSessionService returns a password login for any session; other
+// methods are not used.
+type fakeSessionService struct {
+	session.Service
+}
+
+func (fakeSessionService) GetSession(_ context.Context, userID, sessionID uuid.UUID) (*session.Session, error) {
+	return &session.Session{ID: sessionID, UserID: userID, CreatedAt: time.Now(), AuthMethods: []string{"pwd"}}, nil
+}
+
+func newTestService(t *testing.T) (*service, *fakeRepository) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.JWT.AccessDuration = time.Hour
+	cfg.JWT.RefreshDuration = 24 * time.Hour
+	cfg.OAuth.Issuer = testIssuer
+	if err := token.Init(cfg); err != nil {
+		t.Fatalf("token.Init: %v", err)
+	}
+
+	secretHash, err := bcrypt.GenerateFromPassword([]byte(testSecret), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash secret: %v", err)
+	}
+
+	repo := &fakeRepository{clients: map[string]*Client{
+		"public-app": {
+			ID:           uuid.New(),
+			ClientID:     "public-app",
+			RedirectURIs: []string{testRedirectURI},
+			Scopes:       []string{"read", "write"},
+			IsActive:     true,
+		},
+		"backend": {
+			ID:               uuid.New(),
+			ClientID:         "backend",
+			ClientSecretHash: string(secretHash),
+			RedirectURIs:     []string{testRedirectURI},
+			Scopes:           []string{"read", "write"},
+			IsConfidential:   true,
+			IsActive:         true,
+		},
+	}}
+
+	svc := NewService(cfg, repo, nil, fakeSessionService{}, &fakeSecurityLog{}, zap.NewNop())
+	return svc.(*service), repo
+}
+
+func authorizeParam(clientID, challenge string) AuthorizeParam {
+	param := AuthorizeParam{
+		ResponseType: responseTypeCode,
+		ClientID:     clientID,
+		RedirectURI:  testRedirectURI,
+		Scope:        "read",
+		State:        "xyz",
+	}
+	if challenge != "" {
+		param.CodeChallenge = challenge
+		param.CodeChallengeMethod = challengeMethodS256
+	}
+	return param
+}
+
+// authorize approves the request and returns the redirect's query.
+func authorize(t *testing.T, s *service, param AuthorizeParam) url.Values {
+	t.Helper()
+	redirect, err := s.Authorize(context.Background(), uuid.New(), uuid.New(), ConsentParam{AuthorizeParam: param, Approved: true})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return queryOf(t, redirect)
+}
+
+func queryOf(t *testing.T, redirect string) url.Values {
+	t.Helper()
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	return u.Query()
+}
+
+// issueCode approves the request and returns the code.
+func issueCode(t *testing.T, s *service, param AuthorizeParam) string {
+	t.Helper()
+	query := authorize(t, s, param)
+	if query.Get("code") == "" {
+		t.Fatalf("no code in redirect: %v", query)
+	}
+	return query.Get("code")
+}
+
+func exchange(s *service, code, verifier string, creds ClientCredentials) (*TokenResponse, error) {
+	return s.Token(context.Background(), TokenParam{
+		GrantType:    grantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	}, creds)
+}
+
+func assertOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Errorf("err = %v, want %s", err, code)
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	if !verifyPKCE(rfcVerifier, rfcChallenge) {
+		t.Error("RFC 7636 verifier rejected")
+	}
+	if verifyPKCE(rfcVerifier[:42]+"A", rfcChallenge) {
+		t.Error("other verifier accepted")
+	}
+	if verifyPKCE(rfcChallenge, rfcChallenge) {
+		t.Error("plain challenge accepted as its own verifier")
+	}
+
+	// Verifiers too short to be unguessable are refused even when they match
+	sum := sha256.Sum256([]byte("short"))
+	if verifyPKCE("short", base64.RawURLEncoding.EncodeToString(sum[:])) {
+		t.Error("short verifier accepted")
+	}
+}
+
+func TestAuthorizationCode_PKCE(t *testing.T) {
+	s, _ := newTestService(t)
+	creds := ClientCredentials{ClientID: "public-app"}
+	code := issueCode(t, s, authorizeParam("public-app", rfcChallenge))
+
+	resp, err := exchange(s, code, rfcVerifier, creds)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	claims, err := token.ParseOAuthAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseOAuthAccessToken: %v", err)
+	}
+	if claims.ClientID != "public-app" || resp.Scope != "read" {
+		t.Errorf("token for client %q with scope %q", claims.ClientID, resp.Scope)
+	}
+
+	// Codes are single use
+	_, err = exchange(s, code, rfcVerifier, creds)
+	assertOAuthError(t, err, codeInvalidGrant)
+}
+
+func TestAuthorizationCode_WrongVerifierSpendsCode(t *testing.T) {
+	s, _ := newTestService(t)
+	creds := ClientCredentials{ClientID: "public-app"}
+	code := issueCode(t, s, authorizeParam("public-app", rfcChallenge))
+
+	_, err := exchange(s, code, rfcVerifier[:42]+"A", creds)
+	assertOAuthError(t, err, codeInvalidGrant)
+
+	// An attacker who stole the code cannot keep guessing
+	_, err = exchange(s, code, rfcVerifier, creds)
+	assertOAuthError(t, err, codeInvalidGrant)
+}
+
+func TestAuthorizationCode_MissingVerifier(t *testing.T) {
+	s, _ := newTestService(t)
+	code := issueCode(t, s, authorizeParam("public-app", rfcChallenge))
+
+	_, err := exchange(s, code, "", ClientCredentials{ClientID: "public-app"})
+	assertOAuthError(t, err, codeInvalidGrant)
+}
+
+func TestAuthorizationCode_VerifierWithoutChallenge(t *testing.T) {
+	s, _ := newTestService(t)
+	creds := ClientCredentials{ClientID: "backend", ClientSecret: testSecret}
+	code := issueCode(t, s, authorizeParam("backend", ""))
+
+	// The challenge was stripped from the authorization request
+	_, err := exchange(s, code, rfcVerifier, creds)
+	assertOAuthError(t, err, codeInvalidGrant)
+}
+
+func TestAuthorize_PublicClientRequiresPKCE(t *testing.T) {
+	s, _ := newTestService(t)
+
+	query := authorize(t, s, authorizeParam("public-app", ""))
+	if query.Get("error") != codeInvalidRequest || query.Get("code") != "" {
+		t.Errorf("redirect = %v, want invalid_request", query)
+	}
+	if query.Get("state") != "xyz" {
+		t.Errorf("state = %q, want it echoed", query.Get("state"))
+	}
+}
+
+func TestAuthorize_RejectsPlainChallenge(t *testing.T) {
+	s, _ := newTestService(t)
+	param := authorizeParam("public-app", rfcVerifier)
+	param.CodeChallengeMethod = "plain"
+
+	query := authorize(t, s, param)
+	if query.Get("error") != codeInvalidRequest {
+		t.Errorf("redirect = %v, want invalid_request", query)
+	}
+}
+
+func TestAuthorize_ScopeNotAllowed(t *testing.T) {
+	s, _ := newTestService(t)
+	param := authorizeParam("public-app", rfcChallenge)
+	param.Scope = "read admin"
+
+	query := authorize(t, s, param)
+	if query.Get("error") != codeInvalidScope {
+		t.Errorf("redirect = %v, want invalid_scope", query)
+	}
+}
+
+func TestAuthorize_UnregisteredRedirectURI(t *testing.T) {
+	s, _ := newTestService(t)
+	param := authorizeParam("public-app", rfcChallenge)
+	param.RedirectURI = testRedirectURI + "/../evil"
+
+	// Never redirected to, so the error is returned instead
+	_, err := s.Authorize(context.Background(), uuid.New(), uuid.New(), ConsentParam{AuthorizeParam: param, Approved: true})
+	if err == nil {
+		t.Error("authorized an unregistered redirect URI")
+	}
+}
+
+func TestAuthorizationCode_OtherClientOrRedirect(t *testing.T) {
+	s, repo := newTestService(t)
+	other := *repo.clients["public-app"]
+	other.ID, other.ClientID = uuid.New(), "other-app"
+	repo.clients[other.ClientID] = &other
+
+	code := issueCode(t, s, authorizeParam("public-app", rfcChallenge))
+	_, err := exchange(s, code, rfcVerifier, ClientCredentials{ClientID: "other-app"})
+	assertOAuthError(t, err, codeInvalidGrant)
+
+	code = issueCode(t, s, authorizeParam("public-app", rfcChallenge))
+	_, err = s.Token(context.Background(), TokenParam{
+		GrantType:    grantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI + "?other",
+		CodeVerifier: rfcVerifier,
+	}, ClientCredentials{ClientID: "public-app"})
+	assertOAuthError(t, err, codeInvalidGrant)
+}
@@ -0,0 +1,79 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"time"
+)
+
+const redisCeremonyPrefix = "auth:webauthn_ceremony:"
+
+type ceremonyKind string
+
+const (
+	ceremonyRegistration ceremonyKind = "registration"
+	ceremonyLogin        ceremonyKind = "login"
+	ceremonyMFA          ceremonyKind = "mfa"
+)
+
+var errCeremonyNotFound = errors.New("webauthn ceremony not found or expired")
+
+// ceremony is the server side state of a registration or assertion, kept in
+// Redis between the begin and finish requests.
+type ceremony struct {
+	Kind    ceremonyKind         `json:"kind"`
+	UserID  uuid.UUID            `json:"user_id"` // uuid.Nil for passkey logins
+	Session webauthn.SessionData `json:"session"`
+}
+
+type ceremonyStore struct {
+	ttl time.Duration
+}
+
+func (s *ceremonyStore) save(ctx context.Context, c *ceremony) (string, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.NewString()
+	if err := client.Set(ctx, redisCeremonyPrefix+id, data, s.ttl).Err(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// take returns the ceremony and deletes it, so a challenge is answered once.
+// It must have been started as kind, by userID.
+func (s *ceremonyStore) take(ctx context.Context, id string, kind ceremonyKind, userID uuid.UUID) (*ceremony, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.GetDel(ctx, redisCeremonyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errCeremonyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var c ceremony
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Kind != kind || c.UserID != userID {
+		return nil, errCeremonyNotFound
+	}
+	return &c, nil
+}
@@ -0,0 +1,167 @@
+package passkey
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	authconsts "auth-service/internal/shared/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"github.com/xinyi-chong/common-lib/validation"
+	"go.uber.org/zap"
+)
+
+type Controller struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func NewController(service Service, logger *zap.Logger) *Controller {
+	return &Controller{service: service, logger: logger}
+}
+
+// BeginRegistration godoc
+// @Summary Start passkey registration
+// @Description Get options for navigator.credentials.create
+// @Tags WebAuthn
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 201 {object} response.Response{data=BeginResponse} "Created"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/webauthn/register/begin [post]
+func (ctrl *Controller) BeginRegistration(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := ctrl.service.BeginRegistration(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("BeginRegistration error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.ChallengeField), resp)
+}
+
+// FinishRegistration godoc
+// @Summary Finish passkey registration
+// @Description Store the credential created by the authenticator
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body FinishRegistrationParam true "Credential"
+// @Success 201 {object} response.Response{data=Response} "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 409 {object} response.Response "Credential already registered"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/webauthn/register/finish [post]
+func (ctrl *Controller) FinishRegistration(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[FinishRegistrationParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	credential, err := ctrl.service.FinishRegistration(ctx, userID, param)
+	if err != nil {
+		ctrl.logger.Error("FinishRegistration error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.PasskeyField), credential.Response())
+}
+
+// ListCredentials godoc
+// @Summary List passkeys
+// @Description List the current user's passkeys and security keys
+// @Tags WebAuthn
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]Response} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/webauthn/credentials [get]
+func (ctrl *Controller) ListCredentials(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	credentials, err := ctrl.service.ListCredentials(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("ListCredentials error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	resp := make([]*Response, 0, len(credentials))
+	for i := range credentials {
+		resp = append(resp, credentials[i].Response())
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.PasskeyField), resp)
+}
+
+// DeleteCredential godoc
+// @Summary Delete passkey
+// @Description Remove one of the current user's passkeys
+// @Tags WebAuthn
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Credential ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Reauthentication required"
+// @Failure 404 {object} response.Response "Passkey not found"
+// @Failure 409 {object} response.Response "Last sign-in method"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/webauthn/credentials/{id} [delete]
+func (ctrl *Controller) DeleteCredential(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid credential ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.PasskeyField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.DeleteCredential(ctx, userID, id)
+	if err != nil {
+		ctrl.logger.Error("DeleteCredential error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.PasskeyField), nil)
+}
@@ -0,0 +1,17 @@
+package passkey
+
+import "encoding/json"
+
+type (
+	// FinishParam completes a ceremony. Credential is the
+	// PublicKeyCredential from the browser, serialized as JSON.
+	FinishParam struct {
+		SessionID  string          `json:"session_id" validate:"required"`
+		Credential json.RawMessage `json:"credential" validate:"required" swaggertype:"object"`
+	}
+
+	FinishRegistrationParam struct {
+		FinishParam
+		Name *string `json:"name" validate:"omitempty,max=255"`
+	}
+)
@@ -0,0 +1,70 @@
+package passkey
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"time"
+)
+
+// WebauthnCredential is a registered passkey or security key. Every credential
+// has an auth_methods row of type webauthn; deleting that row deletes it.
+type WebauthnCredential struct {
+	ID           uuid.UUID      `json:"id" db:"id"`
+	UserID       uuid.UUID      `json:"user_id" db:"user_id"`
+	AuthMethodID uuid.UUID      `json:"auth_method_id" db:"auth_method_id"`
+	CredentialID []byte         `json:"-" db:"credential_id"`
+	Name         *string        `json:"name,omitempty" db:"name"`
+	Credential   CredentialData `json:"-" db:"credential"`
+	LastUsedAt   *time.Time     `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// CredentialData is the credential record the WebAuthn library verifies
+// assertions against, including the public key and signature counter.
+type CredentialData webauthn.Credential
+
+func (d CredentialData) Value() (driver.Value, error) {
+	return json.Marshal(webauthn.Credential(d))
+}
+
+func (d *CredentialData) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported credential type")
+	}
+	return json.Unmarshal(data, (*webauthn.Credential)(d))
+}
+
+type Response struct {
+	ID             uuid.UUID  `json:"id"`
+	Name           *string    `json:"name,omitempty"`
+	BackupEligible bool       `json:"backup_eligible"` // synced passkey rather than a device-bound key
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (c *WebauthnCredential) Response() *Response {
+	return &Response{
+		ID:             c.ID,
+		Name:           c.Name,
+		BackupEligible: c.Credential.Flags.BackupEligible,
+		LastUsedAt:     c.LastUsedAt,
+		CreatedAt:      c.CreatedAt,
+	}
+}
+
+// BeginResponse starts a ceremony. Options go to navigator.credentials.create
+// or .get; SessionID comes back with the result.
+type BeginResponse struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
@@ -0,0 +1,97 @@
+package passkey
+
+import (
+	"auth-service/internal/authmethod"
+	"context"
+	"encoding/base64"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, credential *WebauthnCredential) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]WebauthnCredential, error)
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	UpdateAfterLogin(ctx context.Context, credentialID []byte, data CredentialData) error
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Create stores the credential together with its auth method.
+func (r *repository) Create(ctx context.Context, credential *WebauthnCredential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		providerID := base64.RawURLEncoding.EncodeToString(credential.CredentialID)
+		method := &authmethod.AuthMethod{
+			ID:         uuid.New(),
+			UserID:     credential.UserID,
+			MethodType: authmethod.TypeWebAuthn,
+			ProviderID: &providerID,
+		}
+		if err := tx.Create(method).Error; err != nil {
+			return err
+		}
+
+		credential.AuthMethodID = method.ID
+		return tx.Create(credential).Error
+	})
+}
+
+func (r *repository) ListByUser(ctx context.Context, userID uuid.UUID) ([]WebauthnCredential, error) {
+	var credentials []WebauthnCredential
+	result := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&credentials)
+	return credentials, result.Error
+}
+
+func (r *repository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	result := r.db.WithContext(ctx).
+		Model(&WebauthnCredential{}).
+		Where("user_id = ?", userID).
+		Count(&count)
+	return count, result.Error
+}
+
+// UpdateAfterLogin stores the signature counter and flags of the last assertion.
+func (r *repository) UpdateAfterLogin(ctx context.Context, credentialID []byte, data CredentialData) error {
+	result := r.db.WithContext(ctx).
+		Model(&WebauthnCredential{}).
+		Where("credential_id = ?", credentialID).
+		Updates(map[string]interface{}{
+			"credential":   data,
+			"last_used_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes the credential's auth method, which cascades to the
+// credential. The user's last auth method is not removed.
+func (r *repository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var credential WebauthnCredential
+		err := tx.Where("id = ? AND user_id = ?", id, userID).
+			First(&credential).Error
+		if err != nil {
+			return err
+		}
+
+		_, err = authmethod.DeleteTx(tx, userID, credential.AuthMethodID)
+		return err
+	})
+}
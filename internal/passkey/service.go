@@ -0,0 +1,384 @@
+package passkey
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	autherrors "auth-service/internal/shared/errors"
+	userModel "auth-service/internal/user"
+	dberrors "auth-service/pkg/error"
+	"context"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	defaultRPDisplayName = "Auth Service"
+	defaultTimeout       = 5 * time.Minute
+)
+
+var errCloneWarning = errors.New("authenticator signature counter went backwards, it may be cloned")
+
+type Service interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*BeginResponse, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, param FinishRegistrationParam) (*WebauthnCredential, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]WebauthnCredential, error)
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+	HasCredentials(ctx context.Context, userID uuid.UUID) (bool, error)
+
+	// BeginLogin starts a usernameless passkey login.
+	BeginLogin(ctx context.Context) (*BeginResponse, error)
+	// FinishLogin verifies a passkey login and returns the user it belongs to.
+	FinishLogin(ctx context.Context, param FinishParam) (uuid.UUID, error)
+
+	// BeginMFA starts an assertion with one of the user's credentials, as the
+	// second factor after a password.
+	BeginMFA(ctx context.Context, userID uuid.UUID) (*BeginResponse, error)
+	FinishMFA(ctx context.Context, userID uuid.UUID, param FinishParam) error
+}
+
+type service struct {
+	webAuthn       *webauthn.WebAuthn
+	ceremonies     *ceremonyStore
+	repo           Repository
+	userSvc        userModel.Service
+	securityLogSvc securitylog.Service
+	logger         *zap.Logger
+}
+
+func NewService(cfg *config.Config, repo Repository, userSvc userModel.Service, securityLogSvc securitylog.Service, logger *zap.Logger) (Service, error) {
+	displayName := cfg.WebAuthn.RPDisplayName
+	if displayName == "" {
+		displayName = defaultRPDisplayName
+	}
+
+	timeout := cfg.WebAuthn.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: displayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &service{
+		webAuthn:       webAuthn,
+		ceremonies:     &ceremonyStore{ttl: timeout},
+		repo:           repo,
+		userSvc:        userSvc,
+		securityLogSvc: securityLogSvc,
+		logger:         logger,
+	}, nil
+}
+
+// webAuthnUser adapts a user and their credentials to the WebAuthn library.
+// The user handle is the user ID.
+type webAuthnUser struct {
+	user        *userModel.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	if u.user.Email != nil {
+		return *u.user.Email
+	}
+	if u.user.Username != nil {
+		return *u.user.Username
+	}
+	return u.user.ID.String()
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Username != nil {
+		return *u.user.Username
+	}
+	return u.WebAuthnName()
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (s *service) loadUser(ctx context.Context, userID uuid.UUID) (*webAuthnUser, error) {
+	const op = "service.loadUser"
+
+	user, err := s.userSvc.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PasskeyField).WithOp(op)
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, c := range stored {
+		credentials = append(credentials, webauthn.Credential(c.Credential))
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+func (s *service) BeginRegistration(ctx context.Context, userID uuid.UUID) (*BeginResponse, error) {
+	const op = "service.BeginRegistration"
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, c := range user.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	// Discoverable credentials allow usernameless login; security keys
+	// without resident key storage still work as a second factor
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	sessionID, err := s.ceremonies.save(ctx, &ceremony{Kind: ceremonyRegistration, UserID: userID, Session: *session})
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return &BeginResponse{SessionID: sessionID, Options: creation}, nil
+}
+
+func (s *service) FinishRegistration(ctx context.Context, userID uuid.UUID, param FinishRegistrationParam) (*WebauthnCredential, error) {
+	const op = "service.FinishRegistration"
+
+	c, err := s.takeCeremony(ctx, op, param.SessionID, ceremonyRegistration, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(param.Credential)
+	if err != nil {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.PasskeyField).WithOp(op).Wrap(err)
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, c.Session, parsed)
+	if err != nil {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.PasskeyField).WithOp(op).Wrap(err)
+	}
+
+	stored := &WebauthnCredential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CredentialID: credential.ID,
+		Name:         param.Name,
+		Credential:   CredentialData(*credential),
+	}
+	err = s.repo.Create(ctx, stored)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PasskeyField).WithOp(op)
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionPasskeyAdded,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"credential_id": stored.ID.String()},
+	})
+
+	return stored, nil
+}
+
+func (s *service) ListCredentials(ctx context.Context, userID uuid.UUID) ([]WebauthnCredential, error) {
+	const op = "service.ListCredentials"
+
+	credentials, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PasskeyField).WithOp(op)
+	}
+	return credentials, nil
+}
+
+func (s *service) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	const op = "service.DeleteCredential"
+
+	err := s.repo.Delete(ctx, userID, id)
+	if errors.Is(err, authmethod.ErrLastMethod) {
+		return autherrors.ErrLastAuthMethod.WithOp(op).Wrap(err)
+	} else if err != nil {
+		return dberrors.WrapDBError(err, authconsts.PasskeyField).WithOp(op)
+	}
+
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &userID,
+		Action:   securitylog.ActionPasskeyRemoved,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"credential_id": id.String()},
+	})
+	return nil
+}
+
+func (s *service) HasCredentials(ctx context.Context, userID uuid.UUID) (bool, error) {
+	const op = "service.HasCredentials"
+
+	count, err := s.repo.CountByUser(ctx, userID)
+	if err != nil {
+		return false, dberrors.WrapDBError(err, authconsts.PasskeyField).WithOp(op)
+	}
+	return count > 0, nil
+}
+
+func (s *service) BeginLogin(ctx context.Context) (*BeginResponse, error) {
+	const op = "service.BeginLogin"
+
+	// A passkey alone replaces password and second factor, so the
+	// authenticator must verify the user
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	sessionID, err := s.ceremonies.save(ctx, &ceremony{Kind: ceremonyLogin, UserID: uuid.Nil, Session: *session})
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return &BeginResponse{SessionID: sessionID, Options: assertion}, nil
+}
+
+func (s *service) FinishLogin(ctx context.Context, param FinishParam) (uuid.UUID, error) {
+	const op = "service.FinishLogin"
+
+	c, err := s.takeCeremony(ctx, op, param.SessionID, ceremonyLogin, uuid.Nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(param.Credential)
+	if err != nil {
+		return uuid.Nil, apperrors.ErrUnauthorized.WithOp(op).Wrap(err)
+	}
+
+	handler := func(_, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		return s.loadUser(ctx, userID)
+	}
+
+	found, credential, err := s.webAuthn.ValidatePasskeyLogin(handler, c.Session, parsed)
+	if err != nil {
+		return uuid.Nil, apperrors.ErrUnauthorized.WithOp(op).Wrap(err)
+	}
+
+	userID := found.(*webAuthnUser).user.ID
+	err = s.recordAssertion(ctx, op, userID, credential)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+func (s *service) BeginMFA(ctx context.Context, userID uuid.UUID) (*BeginResponse, error) {
+	const op = "service.BeginMFA"
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if len(user.credentials) == 0 {
+		return nil, apperrors.ErrXNotFound.WithField(authconsts.PasskeyField).WithOp(op)
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	sessionID, err := s.ceremonies.save(ctx, &ceremony{Kind: ceremonyMFA, UserID: userID, Session: *session})
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return &BeginResponse{SessionID: sessionID, Options: assertion}, nil
+}
+
+func (s *service) FinishMFA(ctx context.Context, userID uuid.UUID, param FinishParam) error {
+	const op = "service.FinishMFA"
+
+	c, err := s.takeCeremony(ctx, op, param.SessionID, ceremonyMFA, userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(param.Credential)
+	if err != nil {
+		return apperrors.ErrInvalidX.WithField(authconsts.PasskeyField).WithOp(op).Wrap(err)
+	}
+
+	credential, err := s.webAuthn.ValidateLogin(user, c.Session, parsed)
+	if err != nil {
+		return apperrors.ErrInvalidX.WithField(authconsts.PasskeyField).WithOp(op).Wrap(err)
+	}
+
+	return s.recordAssertion(ctx, op, userID, credential)
+}
+
+func (s *service) takeCeremony(ctx context.Context, op, sessionID string, kind ceremonyKind, userID uuid.UUID) (*ceremony, error) {
+	c, err := s.ceremonies.take(ctx, sessionID, kind, userID)
+	if errors.Is(err, errCeremonyNotFound) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.ChallengeField).WithOp(op).Wrap(err)
+	} else if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	return c, nil
+}
+
+// recordAssertion stores the new signature counter. An assertion from a
+// possibly cloned authenticator is rejected.
+func (s *service) recordAssertion(ctx context.Context, op string, userID uuid.UUID, credential *webauthn.Credential) error {
+	if credential.Authenticator.CloneWarning {
+		s.logger.Warn("Possible cloned authenticator",
+			zap.String("user_id", userID.String()),
+			zap.Uint32("sign_count", credential.Authenticator.SignCount))
+		return apperrors.ErrUnauthorized.WithOp(op).Wrap(errCloneWarning)
+	}
+
+	err := s.repo.UpdateAfterLogin(ctx, credential.ID, CredentialData(*credential))
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.PasskeyField).WithOp(op)
+	}
+	return nil
+}
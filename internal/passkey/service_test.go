@@ -0,0 +1,402 @@
+package passkey
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/securitylog"
+	"auth-service/internal/testutil"
+	userModel "auth-service/internal/user"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "https://localhost"
+
+	flagUserPresent  byte = 0x01
+	flagUserVerified byte = 0x04
+	flagAttestedData byte = 0x40
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+// authenticator is a software passkey: an ES256 key with a signature
+// counter, answering ceremonies the way a browser would hand them over.
+type authenticator struct {
+	t          *testing.T
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle []byte
+	signCount  uint32
+	flags      byte
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &authenticator{t: t, key: key, id: id, flags: flagUserPresent | flagUserVerified}
+}
+
+func (a *authenticator) clientData(kind string, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      kind,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		a.t.Fatalf("marshal client data: %v", err)
+	}
+	return data
+}
+
+func (a *authenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+// register answers navigator.credentials.create with "none" attestation.
+func (a *authenticator) register(begin *BeginResponse) json.RawMessage {
+	a.t.Helper()
+	creation := begin.Options.(*protocol.CredentialCreation)
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatalf("marshal public key: %v", err)
+	}
+
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(a.flags|flagAttestedData, attested),
+	})
+	if err != nil {
+		a.t.Fatalf("marshal attestation: %v", err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    protocol.URLEncodedBase64(a.clientData("webauthn.create", creation.Response.Challenge)),
+		"attestationObject": protocol.URLEncodedBase64(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// assert answers navigator.credentials.get, bumping the counter by one.
+func (a *authenticator) assert(begin *BeginResponse) json.RawMessage {
+	a.t.Helper()
+	a.signCount++
+	return a.assertWithCount(begin, a.signCount)
+}
+
+func (a *authenticator) assertWithCount(begin *BeginResponse, signCount uint32) json.RawMessage {
+	a.t.Helper()
+	assertion := begin.Options.(*protocol.CredentialAssertion)
+
+	saved := a.signCount
+	a.signCount = signCount
+	authData := a.authData(a.flags, nil)
+	a.signCount = saved
+
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    protocol.URLEncodedBase64(clientData),
+		"authenticatorData": protocol.URLEncodedBase64(authData),
+		"signature":         protocol.URLEncodedBase64(signature),
+		"userHandle":        protocol.URLEncodedBase64(a.userHandle),
+	})
+}
+
+func (a *authenticator) credential(response map[string]any) json.RawMessage {
+	data, err := json.Marshal(map[string]any{
+		"id":       base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId":    protocol.URLEncodedBase64(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatalf("marshal credential: %v", err)
+	}
+	return data
+}
+
+type fakeRepository struct {
+	credentials map[uuid.UUID]*WebauthnCredential
+}
+
+func (r *fakeRepository) Create(_ context.Context, credential *WebauthnCredential) error {
+	r.credentials[credential.ID] = credential
+	return nil
+}
+
+func (r *fakeRepository) ListByUser(_ context.Context, userID uuid.UUID) ([]WebauthnCredential, error) {
+	var credentials []WebauthnCredential
+	for _, c := range r.credentials {
+		if c.UserID == userID {
+			credentials = append(credentials, *c)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *fakeRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	credentials, _ := r.ListByUser(ctx, userID)
+	return int64(len(credentials)), nil
+}
+
+func (r *fakeRepository) UpdateAfterLogin(_ context.Context, credentialID []byte, data CredentialData) error {
+	for _, c := range r.credentials {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			c.Credential = data
+			return nil
+		}
+	}
+	return errors.New("credential not found")
+}
+
+func (r *fakeRepository) Delete(_ context.Context, _, id uuid.UUID) error {
+	delete(r.credentials, id)
+	return nil
+}
+
+// fakeUserService knows a single user; other methods are not used.
+type fakeUserService struct {
+	userModel.Service
+	user *userModel.User
+}
+
+func (s *fakeUserService) GetUser(_ context.Context, id uuid.UUID) (*userModel.User, error) {
+	if id != s.user.ID {
+		return nil, apperrors.ErrXNotFound
+	}
+	return s.user, nil
+}
+
+type fakeSecurityLog struct{}
+
+func (fakeSecurityLog) Record(context.Context, securitylog.Event) {}
+
+func newTestService(t *testing.T) (*service, *fakeRepository, uuid.UUID) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.WebAuthn.RPID = testRPID
+	cfg.WebAuthn.RPOrigins = []string{testOrigin}
+
+	email := "user@example.com"
+	user := &userModel.User{ID: uuid.New(), Email: &email, IsActive: true}
+	repo := &fakeRepository{credentials: map[uuid.UUID]*WebauthnCredential{}}
+
+	svc, err := NewService(cfg, repo, &fakeUserService{user: user}, fakeSecurityLog{}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return svc.(*service), repo, user.ID
+}
+
+// register runs a registration ceremony for userID with a new authenticator.
+func register(t *testing.T, s *service, userID uuid.UUID) *authenticator {
+	t.Helper()
+	ctx := context.Background()
+	a := newAuthenticator(t)
+
+	begin, err := s.BeginRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	_, err = s.FinishRegistration(ctx, userID, FinishRegistrationParam{
+		FinishParam: FinishParam{SessionID: begin.SessionID, Credential: a.register(begin)},
+	})
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return a
+}
+
+func beginLogin(t *testing.T, s *service) *BeginResponse {
+	t.Helper()
+	begin, err := s.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	return begin
+}
+
+func TestRegistration(t *testing.T) {
+	s, repo, userID := newTestService(t)
+	a := register(t, s, userID)
+
+	credentials, _ := repo.ListByUser(context.Background(), userID)
+	if len(credentials) != 1 {
+		t.Fatalf("stored %d credentials, want 1", len(credentials))
+	}
+	if !bytes.Equal(credentials[0].CredentialID, a.id) {
+		t.Errorf("credential ID = %x, want %x", credentials[0].CredentialID, a.id)
+	}
+	if credentials[0].Credential.AttestationType != "none" {
+		t.Errorf("attestation type = %q, want none", credentials[0].Credential.AttestationType)
+	}
+}
+
+func TestRegistration_ChallengeMismatch(t *testing.T) {
+	s, repo, userID := newTestService(t)
+	ctx := context.Background()
+	a := newAuthenticator(t)
+
+	answered, _ := s.BeginRegistration(ctx, userID)
+	other, _ := s.BeginRegistration(ctx, userID)
+
+	// An answer to one challenge cannot finish another ceremony
+	_, err := s.FinishRegistration(ctx, userID, FinishRegistrationParam{
+		FinishParam: FinishParam{SessionID: other.SessionID, Credential: a.register(answered)},
+	})
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("err = %v, want invalid passkey", err)
+	}
+	if len(repo.credentials) != 0 {
+		t.Error("credential stored for a mismatched challenge")
+	}
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	s, repo, userID := newTestService(t)
+	a := register(t, s, userID)
+	ctx := context.Background()
+
+	begin := beginLogin(t, s)
+	got, err := s.FinishLogin(ctx, FinishParam{SessionID: begin.SessionID, Credential: a.assert(begin)})
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if got != userID {
+		t.Errorf("user = %v, want %v", got, userID)
+	}
+
+	credentials, _ := repo.ListByUser(ctx, userID)
+	if count := credentials[0].Credential.Authenticator.SignCount; count != a.signCount {
+		t.Errorf("stored sign count = %d, want %d", count, a.signCount)
+	}
+}
+
+func TestPasskeyLogin_ChallengeSingleUse(t *testing.T) {
+	s, _, userID := newTestService(t)
+	a := register(t, s, userID)
+	ctx := context.Background()
+
+	begin := beginLogin(t, s)
+	param := FinishParam{SessionID: begin.SessionID, Credential: a.assert(begin)}
+	if _, err := s.FinishLogin(ctx, param); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+
+	_, err := s.FinishLogin(ctx, param)
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("replayed assertion: err = %v, want invalid challenge", err)
+	}
+}
+
+func TestPasskeyLogin_RequiresUserVerification(t *testing.T) {
+	s, _, userID := newTestService(t)
+	a := register(t, s, userID)
+
+	a.flags = flagUserPresent
+	begin := beginLogin(t, s)
+	_, err := s.FinishLogin(context.Background(), FinishParam{SessionID: begin.SessionID, Credential: a.assert(begin)})
+	if !apperrors.Is(err, apperrors.ErrUnauthorized) {
+		t.Errorf("err = %v, want unauthorized", err)
+	}
+}
+
+func TestPasskeyLogin_RejectsClonedAuthenticator(t *testing.T) {
+	s, repo, userID := newTestService(t)
+	a := register(t, s, userID)
+	ctx := context.Background()
+
+	a.signCount = 4
+	begin := beginLogin(t, s)
+	if _, err := s.FinishLogin(ctx, FinishParam{SessionID: begin.SessionID, Credential: a.assert(begin)}); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+
+	// A copy of the key that has signed less often than the original
+	begin = beginLogin(t, s)
+	_, err := s.FinishLogin(ctx, FinishParam{SessionID: begin.SessionID, Credential: a.assertWithCount(begin, 2)})
+	if !apperrors.Is(err, apperrors.ErrUnauthorized) || !strings.Contains(err.Error(), errCloneWarning.Error()) {
+		t.Errorf("err = %v, want clone warning", err)
+	}
+
+	credentials, _ := repo.ListByUser(ctx, userID)
+	if count := credentials[0].Credential.Authenticator.SignCount; count != 5 {
+		t.Errorf("stored sign count = %d, want 5", count)
+	}
+}
+
+func TestMFA(t *testing.T) {
+	s, _, userID := newTestService(t)
+	a := register(t, s, userID)
+	ctx := context.Background()
+
+	begin, err := s.BeginMFA(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginMFA: %v", err)
+	}
+	if err := s.FinishMFA(ctx, userID, FinishParam{SessionID: begin.SessionID, Credential: a.assert(begin)}); err != nil {
+		t.Fatalf("FinishMFA: %v", err)
+	}
+}
+
+func TestMFA_RejectsLoginCeremony(t *testing.T) {
+	s, _, userID := newTestService(t)
+	a := register(t, s, userID)
+
+	// A passkey login challenge is not a second factor challenge
+	begin := beginLogin(t, s)
+	err := s.FinishMFA(context.Background(), userID, FinishParam{SessionID: begin.SessionID, Credential: a.assert(begin)})
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("err = %v, want invalid challenge", err)
+	}
+}
@@ -0,0 +1,51 @@
+package reauth
+
+import (
+	"auth-service/internal/config"
+	"context"
+	"github.com/google/uuid"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"time"
+)
+
+const (
+	redisReauthPrefix = "auth:reauth:"
+	defaultWindow     = 5 * time.Minute
+)
+
+// Store tracks which sessions authenticated recently, for changes that need
+// the user to prove their identity again.
+type Store struct {
+	window time.Duration
+}
+
+func NewStore(cfg *config.Config) *Store {
+	window := cfg.Reauth.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	return &Store{window: window}
+}
+
+// Mark records that the session's user just authenticated.
+func (s *Store) Mark(ctx context.Context, sessionID uuid.UUID) error {
+	client, err := redisclient.Client()
+	if err != nil {
+		return err
+	}
+	return client.Set(ctx, redisReauthPrefix+sessionID.String(), time.Now().Unix(), s.window).Err()
+}
+
+// IsRecent reports whether the session authenticated within the window.
+func (s *Store) IsRecent(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := client.Exists(ctx, redisReauthPrefix+sessionID.String()).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
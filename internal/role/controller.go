@@ -0,0 +1,620 @@
+package role
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	authconsts "auth-service/internal/shared/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"github.com/xinyi-chong/common-lib/validation"
+	"go.uber.org/zap"
+)
+
+type Controller struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func NewController(service Service, logger *zap.Logger) *Controller {
+	return &Controller{service: service, logger: logger}
+}
+
+// ListRoles godoc
+// @Summary List roles
+// @Description List all roles
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]Role} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles [get]
+func (ctrl *Controller) ListRoles(c *gin.Context) {
+	ctx := c.Request.Context()
+	roles, err := ctrl.service.ListRoles(ctx)
+	if err != nil {
+		ctrl.logger.Error("ListRoles error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.RoleField), roles)
+}
+
+// GetRole godoc
+// @Summary Get role
+// @Description Get a role and the permissions it grants
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Role ID"
+// @Success 200 {object} response.Response{data=RoleResponse} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Role not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles/{id} [get]
+func (ctrl *Controller) GetRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	role, err := ctrl.service.GetRole(ctx, id)
+	if err != nil {
+		ctrl.logger.Error("GetRole error", zap.String("role_id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.RoleField), role)
+}
+
+// CreateRole godoc
+// @Summary Create role
+// @Description Create a role. Names are lowercase letters, digits, dots, dashes and underscores.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body CreateRoleParam true "Role"
+// @Success 201 {object} response.Response{data=Role} "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 409 {object} response.Response "Role already exists"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles [post]
+func (ctrl *Controller) CreateRole(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[CreateRoleParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	role, err := ctrl.service.CreateRole(ctx, adminID, param)
+	if err != nil {
+		ctrl.logger.Error("CreateRole error", zap.String("name", param.Name), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.RoleField), role)
+}
+
+// UpdateRole godoc
+// @Summary Update role
+// @Description Rename a role or change its description. System roles cannot be renamed.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Role ID"
+// @Param body body UpdateRoleParam true "Fields to change"
+// @Success 200 {object} response.Response{data=Role} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Role not found"
+// @Failure 409 {object} response.Response "System role or name already exists"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles/{id} [patch]
+func (ctrl *Controller) UpdateRole(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[UpdateRoleParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	role, err := ctrl.service.UpdateRole(ctx, adminID, id, param)
+	if err != nil {
+		ctrl.logger.Error("UpdateRole error", zap.String("role_id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.RoleField), role)
+}
+
+// DeleteRole godoc
+// @Summary Delete role
+// @Description Delete a role and take it away from its users. System roles cannot be deleted, and you must hold every permission the role grants.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Role ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Role not found"
+// @Failure 409 {object} response.Response "System role"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles/{id} [delete]
+func (ctrl *Controller) DeleteRole(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.DeleteRole(ctx, adminID, id)
+	if err != nil {
+		ctrl.logger.Error("DeleteRole error", zap.String("role_id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.RoleField), nil)
+}
+
+// ListPermissions godoc
+// @Summary List permissions
+// @Description List all permissions
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]Permission} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/permissions [get]
+func (ctrl *Controller) ListPermissions(c *gin.Context) {
+	ctx := c.Request.Context()
+	permissions, err := ctrl.service.ListPermissions(ctx)
+	if err != nil {
+		ctrl.logger.Error("ListPermissions error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.PermissionField), permissions)
+}
+
+// CreatePermission godoc
+// @Summary Create permission
+// @Description Create a permission. Codes are lowercase resource and action pairs, such as users:read.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param body body CreatePermissionParam true "Permission"
+// @Success 201 {object} response.Response{data=Permission} "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 409 {object} response.Response "Permission already exists"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/permissions [post]
+func (ctrl *Controller) CreatePermission(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[CreatePermissionParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	permission, err := ctrl.service.CreatePermission(ctx, adminID, param)
+	if err != nil {
+		ctrl.logger.Error("CreatePermission error", zap.String("code", param.Code), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.PermissionField), permission)
+}
+
+// UpdatePermission godoc
+// @Summary Update permission
+// @Description Change the description of a permission. Codes cannot change.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Permission ID"
+// @Param body body UpdatePermissionParam true "Fields to change"
+// @Success 200 {object} response.Response{data=Permission} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Permission not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/permissions/{id} [patch]
+func (ctrl *Controller) UpdatePermission(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid permission ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.PermissionField))
+		return
+	}
+
+	param, err := validation.GinBindAndValidate[UpdatePermissionParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	permission, err := ctrl.service.UpdatePermission(ctx, adminID, id, param)
+	if err != nil {
+		ctrl.logger.Error("UpdatePermission error", zap.String("permission_id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.PermissionField), permission)
+}
+
+// DeletePermission godoc
+// @Summary Delete permission
+// @Description Delete a permission and take it away from every role. You must hold the permission.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Permission ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Permission not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/permissions/{id} [delete]
+func (ctrl *Controller) DeletePermission(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid permission ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.PermissionField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.DeletePermission(ctx, adminID, id)
+	if err != nil {
+		ctrl.logger.Error("DeletePermission error", zap.String("permission_id", id.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.PermissionField), nil)
+}
+
+// GrantPermission godoc
+// @Summary Grant permission to role
+// @Description Add a permission you hold to a role. Granting it again has no effect. System roles grant only their seeded permissions.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Role ID"
+// @Param permission_id path string true "Permission ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "Role or permission not found"
+// @Failure 409 {object} response.Response "System role"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles/{id}/permissions/{permission_id} [put]
+func (ctrl *Controller) GrantPermission(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	permissionID, err := uuid.Parse(c.Param("permission_id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid permission ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.PermissionField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.GrantPermission(ctx, adminID, roleID, permissionID)
+	if err != nil {
+		ctrl.logger.Error("GrantPermission error", zap.String("role_id", roleID.String()), zap.String("permission_id", permissionID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.RoleField), nil)
+}
+
+// RevokePermission godoc
+// @Summary Revoke permission from role
+// @Description Take a permission you hold away from a role. System roles grant only their seeded permissions.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Role ID"
+// @Param permission_id path string true "Permission ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "The role does not have the permission"
+// @Failure 409 {object} response.Response "System role"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/roles/{id}/permissions/{permission_id} [delete]
+func (ctrl *Controller) RevokePermission(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	permissionID, err := uuid.Parse(c.Param("permission_id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid permission ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.PermissionField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.RevokePermission(ctx, adminID, roleID, permissionID)
+	if err != nil {
+		ctrl.logger.Error("RevokePermission error", zap.String("role_id", roleID.String()), zap.String("permission_id", permissionID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(authconsts.RoleField), nil)
+}
+
+// ListUserRoles godoc
+// @Summary List user roles
+// @Description List the roles assigned to a user
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response{data=[]Role} "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/roles [get]
+func (ctrl *Controller) ListUserRoles(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	roles, err := ctrl.service.ListUserRoles(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("ListUserRoles error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.RoleField), roles)
+}
+
+// AssignRole godoc
+// @Summary Assign role to user
+// @Description Give a user a role. You must hold every permission of the role and of the user. Assigning it again has no effect.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Param role_id path string true "Role ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "User or role not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/roles/{role_id} [put]
+func (ctrl *Controller) AssignRole(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("role_id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.AssignRole(ctx, adminID, userID, roleID)
+	if err != nil {
+		ctrl.logger.Error("AssignRole error", zap.String("user_id", userID.String()), zap.String("role_id", roleID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), nil)
+}
+
+// UnassignRole godoc
+// @Summary Unassign role from user
+// @Description Take a role away from a user. You must hold every permission of the role and of the user.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "User ID"
+// @Param role_id path string true "Role ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 404 {object} response.Response "The user does not have the role"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /users/{id}/roles/{role_id} [delete]
+func (ctrl *Controller) UnassignRole(c *gin.Context) {
+	adminID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid user ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(consts.UserField))
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("role_id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid role ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.RoleField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.UnassignRole(ctx, adminID, userID, roleID)
+	if err != nil {
+		ctrl.logger.Error("UnassignRole error", zap.String("user_id", userID.String()), zap.String("role_id", roleID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.UserField), nil)
+}
+
+// CurrentAuthorization godoc
+// @Summary Current user's permissions
+// @Description Get the roles and permissions of the current user. Services call this when an access token says permissions_omitted because the user has too many permissions to embed.
+// @Tags Auth
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=Authorization} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/permissions [get]
+func (ctrl *Controller) CurrentAuthorization(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	authz, err := ctrl.service.UserAuthorization(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("CurrentAuthorization error", zap.String("user_id", userID.String()), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.PermissionField), authz)
+}
@@ -0,0 +1,38 @@
+package role
+
+type (
+	CreateRoleParam struct {
+		Name        string  `json:"name" validate:"required,max=50"`
+		Description *string `json:"description" validate:"omitempty,max=1000"`
+	}
+
+	// UpdateRoleParam changes the fields that are set. System roles keep
+	// their name.
+	UpdateRoleParam struct {
+		Name        *string `json:"name" validate:"omitempty,min=1,max=50"`
+		Description *string `json:"description" validate:"omitempty,max=1000"`
+	}
+
+	// CreatePermissionParam adds a permission. Codes are lowercase resource
+	// and action pairs, such as "users:read".
+	CreatePermissionParam struct {
+		Code        string  `json:"code" validate:"required,max=100"`
+		Description *string `json:"description" validate:"omitempty,max=1000"`
+	}
+
+	UpdatePermissionParam struct {
+		Description *string `json:"description" validate:"omitempty,max=1000"`
+	}
+
+	// Authorization lists what a user may do.
+	Authorization struct {
+		Roles       []string `json:"roles"`
+		Permissions []string `json:"permissions"`
+	}
+
+	// RoleResponse is a role with the permissions it grants.
+	RoleResponse struct {
+		Role
+		Permissions []Permission `json:"permissions"`
+	}
+)
@@ -0,0 +1,45 @@
+package role
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Role groups permissions to grant to users. System roles are managed by the
+// service and cannot be renamed or deleted.
+type Role struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	IsSystem    bool      `json:"is_system" db:"is_system"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Permission is the right to do something, named by a code such as
+// "users:read".
+type Permission struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Code        string    `json:"code" db:"code"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type RolePermission struct {
+	RoleID       uuid.UUID `db:"role_id"`
+	PermissionID uuid.UUID `db:"permission_id"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type UserRole struct {
+	UserID    uuid.UUID `db:"user_id"`
+	RoleID    uuid.UUID `db:"role_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Bootstrap records a one-off setup step that has been done.
+type Bootstrap struct {
+	Name        string     `db:"name"`
+	UserID      *uuid.UUID `db:"user_id"`
+	CompletedAt time.Time  `db:"completed_at"`
+}
@@ -0,0 +1,334 @@
+package role
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Repository interface {
+	CreateRole(ctx context.Context, role *Role) error
+	FindRoleByID(ctx context.Context, id uuid.UUID) (*Role, error)
+	ListRoles(ctx context.Context) ([]Role, error)
+	UpdateRoleColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error
+	DeleteRole(ctx context.Context, id uuid.UUID) error
+
+	CreatePermission(ctx context.Context, permission *Permission) error
+	FindPermissionByID(ctx context.Context, id uuid.UUID) (*Permission, error)
+	ListPermissions(ctx context.Context) ([]Permission, error)
+	UpdatePermissionColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error
+	DeletePermission(ctx context.Context, id uuid.UUID) error
+
+	// AddRolePermission grants the permission to the role. Granting it twice
+	// is not an error.
+	AddRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) error
+	RemoveRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) error
+	ListRolePermissions(ctx context.Context, roleID uuid.UUID) ([]Permission, error)
+	// ListRoleUserIDs returns the users who have the role.
+	ListRoleUserIDs(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error)
+	// ListPermissionUserIDs returns the users who have the permission through
+	// any of their roles, each once.
+	ListPermissionUserIDs(ctx context.Context, permissionID uuid.UUID) ([]uuid.UUID, error)
+
+	// AddUserRole assigns the role to the user. Assigning it twice is not an
+	// error.
+	AddUserRole(ctx context.Context, userID, roleID uuid.UUID) error
+	RemoveUserRole(ctx context.Context, userID, roleID uuid.UUID) error
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]Role, error)
+	// ListUserPermissions returns the permissions of all the user's roles,
+	// each once.
+	ListUserPermissions(ctx context.Context, userID uuid.UUID) ([]Permission, error)
+
+	FindRoleByName(ctx context.Context, name string) (*Role, error)
+	// UpsertSystemRole creates the role by name, or makes it a system role
+	// with the given description. It reports whether anything changed.
+	UpsertSystemRole(ctx context.Context, role *Role) (bool, error)
+	// UpsertPermission creates the permission by code, or sets its
+	// description. It reports whether anything changed.
+	UpsertPermission(ctx context.Context, permission *Permission) (bool, error)
+	// SetRolePermissions grants exactly the given permissions to the role.
+	SetRolePermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error
+
+	IsBootstrapped(ctx context.Context, name string) (bool, error)
+	// CompleteBootstrap assigns the role to the user and records the
+	// bootstrap as done, unless it already is. It reports whether it did.
+	CompleteBootstrap(ctx context.Context, name string, userID, roleID uuid.UUID) (bool, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) CreateRole(ctx context.Context, role *Role) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+func (r *repository) FindRoleByID(ctx context.Context, id uuid.UUID) (*Role, error) {
+	var role Role
+	result := r.db.WithContext(ctx).First(&role, id)
+	return &role, result.Error
+}
+
+func (r *repository) ListRoles(ctx context.Context) ([]Role, error) {
+	var roles []Role
+	result := r.db.WithContext(ctx).
+		Order("name").
+		Find(&roles)
+	return roles, result.Error
+}
+
+func (r *repository) UpdateRoleColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	result := r.db.WithContext(ctx).
+		Model(&Role{}).
+		Where("id = ?", id).
+		Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteRole deletes the role unless it is a system role. Its grants and
+// assignments go with it.
+func (r *repository) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("is_system = ?", false).
+		Delete(&Role{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) CreatePermission(ctx context.Context, permission *Permission) error {
+	return r.db.WithContext(ctx).Create(permission).Error
+}
+
+func (r *repository) FindPermissionByID(ctx context.Context, id uuid.UUID) (*Permission, error) {
+	var permission Permission
+	result := r.db.WithContext(ctx).First(&permission, id)
+	return &permission, result.Error
+}
+
+func (r *repository) ListPermissions(ctx context.Context) ([]Permission, error) {
+	var permissions []Permission
+	result := r.db.WithContext(ctx).
+		Order("code").
+		Find(&permissions)
+	return permissions, result.Error
+}
+
+func (r *repository) UpdatePermissionColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	result := r.db.WithContext(ctx).
+		Model(&Permission{}).
+		Where("id = ?", id).
+		Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) DeletePermission(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&Permission{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) AddRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RolePermission{RoleID: roleID, PermissionID: permissionID}).Error
+}
+
+func (r *repository) RemoveRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("role_id = ? AND permission_id = ?", roleID, permissionID).
+		Delete(&RolePermission{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) ListRolePermissions(ctx context.Context, roleID uuid.UUID) ([]Permission, error) {
+	var permissions []Permission
+	result := r.db.WithContext(ctx).
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Where("rp.role_id = ?", roleID).
+		Order("permissions.code").
+		Find(&permissions)
+	return permissions, result.Error
+}
+
+func (r *repository) ListRoleUserIDs(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	result := r.db.WithContext(ctx).
+		Model(&UserRole{}).
+		Where("role_id = ?", roleID).
+		Pluck("user_id", &userIDs)
+	return userIDs, result.Error
+}
+
+func (r *repository) ListPermissionUserIDs(ctx context.Context, permissionID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	result := r.db.WithContext(ctx).
+		Model(&UserRole{}).
+		Distinct().
+		Joins("JOIN role_permissions rp ON rp.role_id = user_roles.role_id").
+		Where("rp.permission_id = ?", permissionID).
+		Pluck("user_roles.user_id", &userIDs)
+	return userIDs, result.Error
+}
+
+func (r *repository) AddUserRole(ctx context.Context, userID, roleID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserRole{UserID: userID, RoleID: roleID}).Error
+}
+
+func (r *repository) RemoveUserRole(ctx context.Context, userID, roleID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND role_id = ?", userID, roleID).
+		Delete(&UserRole{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]Role, error) {
+	var roles []Role
+	result := r.db.WithContext(ctx).
+		Joins("JOIN user_roles ur ON ur.role_id = roles.id").
+		Where("ur.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles)
+	return roles, result.Error
+}
+
+func (r *repository) ListUserPermissions(ctx context.Context, userID uuid.UUID) ([]Permission, error) {
+	var permissions []Permission
+	result := r.db.WithContext(ctx).
+		Where("id IN (?)", r.db.
+			Table("role_permissions rp").
+			Select("rp.permission_id").
+			Joins("JOIN user_roles ur ON ur.role_id = rp.role_id").
+			Where("ur.user_id = ?", userID)).
+		Order("code").
+		Find(&permissions)
+	return permissions, result.Error
+}
+
+func (r *repository) FindRoleByName(ctx context.Context, name string) (*Role, error) {
+	var role Role
+	result := r.db.WithContext(ctx).
+		Where("name = ?", name).
+		First(&role)
+	return &role, result.Error
+}
+
+func (r *repository) UpsertSystemRole(ctx context.Context, role *Role) (bool, error) {
+	role.IsSystem = true
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "is_system", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				gorm.Expr("roles.description IS DISTINCT FROM EXCLUDED.description OR NOT roles.is_system"),
+			}},
+		}).
+		Create(role)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *repository) UpsertPermission(ctx context.Context, permission *Permission) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "code"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				gorm.Expr("permissions.description IS DISTINCT FROM EXCLUDED.description"),
+			}},
+		}).
+		Create(permission)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *repository) SetRolePermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stale := tx.Where("role_id = ?", roleID)
+		if len(permissionIDs) > 0 {
+			stale = stale.Where("permission_id NOT IN ?", permissionIDs)
+		}
+		if err := stale.Delete(&RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		if len(permissionIDs) == 0 {
+			return nil
+		}
+		grants := make([]RolePermission, 0, len(permissionIDs))
+		for _, permissionID := range permissionIDs {
+			grants = append(grants, RolePermission{RoleID: roleID, PermissionID: permissionID})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grants).Error
+	})
+}
+
+func (r *repository) IsBootstrapped(ctx context.Context, name string) (bool, error) {
+	var count int64
+	result := r.db.WithContext(ctx).
+		Model(&Bootstrap{}).
+		Where("name = ?", name).
+		Count(&count)
+	return count > 0, result.Error
+}
+
+func (r *repository) CompleteBootstrap(ctx context.Context, name string, userID, roleID uuid.UUID) (bool, error) {
+	completed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Claiming the name first keeps concurrent instances from both
+		// assigning the role
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&Bootstrap{Name: name, UserID: &userID, CompletedAt: time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&UserRole{UserID: userID, RoleID: roleID}).Error
+		if err != nil {
+			return err
+		}
+		completed = true
+		return nil
+	})
+	return completed, err
+}
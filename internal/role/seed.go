@@ -0,0 +1,218 @@
+package role
+
+import (
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	userModel "auth-service/internal/user"
+	dberrors "auth-service/pkg/error"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+)
+
+// bootstrapAdmin names the bootstrap that creates the first administrator
+const bootstrapAdmin = "admin"
+
+type (
+	// Seed declares the system roles and the permissions they grant.
+	Seed struct {
+		Permissions []SeedPermission `mapstructure:"permissions"`
+		Roles       []SeedRole       `mapstructure:"roles"`
+	}
+
+	SeedPermission struct {
+		Code        string  `mapstructure:"code"`
+		Description *string `mapstructure:"description"`
+	}
+
+	SeedRole struct {
+		Name        string   `mapstructure:"name"`
+		Description *string  `mapstructure:"description"`
+		Permissions []string `mapstructure:"permissions"`
+	}
+
+	// BootstrapAdminParam names the first administrator. The user is created
+	// with Password when no user has Email.
+	BootstrapAdminParam struct {
+		Email    string
+		Username *string
+		Password string
+		Role     string
+	}
+)
+
+// LoadSeed reads and checks a seed file.
+func LoadSeed(path string) (*Seed, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var seed Seed
+	if err := v.Unmarshal(&seed); err != nil {
+		return nil, err
+	}
+
+	if err := seed.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &seed, nil
+}
+
+func (seed *Seed) validate() error {
+	codes := make(map[string]struct{}, len(seed.Permissions))
+	for _, permission := range seed.Permissions {
+		if !permissionPattern.MatchString(permission.Code) {
+			return fmt.Errorf("invalid permission code %q", permission.Code)
+		}
+		codes[permission.Code] = struct{}{}
+	}
+
+	names := make(map[string]struct{}, len(seed.Roles))
+	for _, role := range seed.Roles {
+		if !rolePattern.MatchString(role.Name) {
+			return fmt.Errorf("invalid role name %q", role.Name)
+		} else if _, ok := names[role.Name]; ok {
+			return fmt.Errorf("role %q is declared twice", role.Name)
+		}
+		names[role.Name] = struct{}{}
+
+		for _, code := range role.Permissions {
+			if _, ok := codes[code]; !ok {
+				return fmt.Errorf("role %q grants undeclared permission %q", role.Name, code)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *service) ReconcileSeed(ctx context.Context, seed *Seed) error {
+	const op = "service.ReconcileSeed"
+
+	for _, p := range seed.Permissions {
+		permission := &Permission{ID: uuid.New(), Code: p.Code, Description: p.Description}
+		changed, err := s.repo.UpsertPermission(ctx, permission)
+		if err != nil {
+			return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+		} else if changed {
+			s.logger.Info("Seeded permission", zap.String("code", p.Code))
+		}
+	}
+
+	permissions, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+	permissionIDs := make(map[string]uuid.UUID, len(permissions))
+	for _, permission := range permissions {
+		permissionIDs[permission.Code] = permission.ID
+	}
+
+	for _, r := range seed.Roles {
+		role := &Role{ID: uuid.New(), Name: r.Name, Description: r.Description}
+		changed, err := s.repo.UpsertSystemRole(ctx, role)
+		if err != nil {
+			return dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+		} else if changed {
+			s.logger.Info("Seeded role", zap.String("name", r.Name))
+		}
+
+		// The role's ID is not known when it already existed
+		role, err = s.repo.FindRoleByName(ctx, r.Name)
+		if err != nil {
+			return dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+		}
+
+		grants := make([]uuid.UUID, 0, len(r.Permissions))
+		for _, code := range r.Permissions {
+			grants = append(grants, permissionIDs[code])
+		}
+		err = s.repo.SetRolePermissions(ctx, role.ID, grants)
+		if err != nil {
+			return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) BootstrapAdmin(ctx context.Context, param BootstrapAdminParam) error {
+	const op = "service.BootstrapAdmin"
+
+	if param.Email == "" {
+		return nil
+	}
+
+	done, err := s.repo.IsBootstrapped(ctx, bootstrapAdmin)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if done {
+		s.logger.Debug("Admin already bootstrapped")
+		return nil
+	}
+
+	role, err := s.repo.FindRoleByName(ctx, param.Role)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	user, err := s.userSvc.GetUserByEmail(ctx, param.Email)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		user, err = s.createAdmin(ctx, op, param)
+	} else if err == nil && !user.EmailVerified {
+		// Anyone can register an unverified address, so they do not get to
+		// become admin with it. The bootstrap runs again once it is verified.
+		s.logger.Error("Bootstrap admin skipped: the account with the bootstrap email is not verified",
+			zap.String("user_id", user.ID.String()))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	completed, err := s.repo.CompleteBootstrap(ctx, bootstrapAdmin, user.ID, role.ID)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if !completed {
+		// Another instance got there first
+		return nil
+	}
+
+	s.logger.Info("Bootstrapped admin", zap.String("user_id", user.ID.String()), zap.String("role", role.Name))
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &user.ID,
+		Action:   securitylog.ActionAdminBootstrapped,
+		Status:   securitylog.StatusSuccess,
+		Metadata: securitylog.Metadata{"role": role.Name},
+	})
+	return nil
+}
+
+// createAdmin registers the bootstrap administrator. Their email is trusted
+// because it comes from the deployment's configuration.
+func (s *service) createAdmin(ctx context.Context, op string, param BootstrapAdminParam) (*userModel.User, error) {
+	if param.Password == "" {
+		return nil, apperrors.ErrXNotFound.WithField(consts.UserField).WithOp(op).
+			Wrap(fmt.Errorf("no user has the bootstrap admin email and no password is set to create one"))
+	}
+
+	user, err := s.userSvc.CreateUser(ctx, &userModel.CreateUserParam{
+		Username: param.Username,
+		Email:    param.Email,
+		Password: param.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userSvc.MarkEmailVerified(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,297 @@
+package role
+
+import (
+	"auth-service/internal/securitylog"
+	userModel "auth-service/internal/user"
+	"context"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"sort"
+	"testing"
+)
+
+const adminEmail = "admin@example.com"
+
+// accountUserService keeps the users the bootstrap looks up and creates.
+type accountUserService struct {
+	fakeUserService
+	users map[string]*userModel.User // by email
+}
+
+func (s *accountUserService) GetUserByEmail(_ context.Context, email string) (*userModel.User, error) {
+	user, ok := s.users[email]
+	if !ok {
+		return nil, apperrors.ErrXNotFound
+	}
+	return user, nil
+}
+
+func (s *accountUserService) CreateUser(_ context.Context, param *userModel.CreateUserParam) (*userModel.User, error) {
+	user := &userModel.User{ID: uuid.New(), Username: param.Username, Email: &param.Email, PasswordHash: &param.Password, IsActive: true}
+	s.users[param.Email] = user
+	return user, nil
+}
+
+func (s *accountUserService) MarkEmailVerified(_ context.Context, id uuid.UUID) error {
+	for _, user := range s.users {
+		if user.ID == id {
+			user.EmailVerified = true
+		}
+	}
+	return nil
+}
+
+func testSeed() *Seed {
+	description := "Everything"
+	return &Seed{
+		Permissions: []SeedPermission{
+			{Code: "*", Description: &description},
+			{Code: "users:read"},
+			{Code: "users:write"},
+		},
+		Roles: []SeedRole{
+			{Name: "super_admin", Permissions: []string{"*"}},
+			{Name: "user_admin", Permissions: []string{"users:read", "users:write"}},
+		},
+	}
+}
+
+// grantedCodes lists the permission codes the role grants.
+func (r *fakeRepository) grantedCodes(t *testing.T, name string) []string {
+	t.Helper()
+	role, err := r.FindRoleByName(context.Background(), name)
+	if err != nil {
+		t.Fatalf("role %q: %v", name, err)
+	}
+	var codes []string
+	for id := range r.grants[role.ID] {
+		codes = append(codes, r.permissions[id].Code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func TestSeedValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Seed)
+		wantErr bool
+	}{
+		{"valid", func(*Seed) {}, false},
+		{"invalid permission code", func(s *Seed) { s.Permissions[1].Code = "Users Read" }, true},
+		{"invalid role name", func(s *Seed) { s.Roles[0].Name = "Super Admin" }, true},
+		{"role declared twice", func(s *Seed) { s.Roles[1].Name = s.Roles[0].Name }, true},
+		{"undeclared permission", func(s *Seed) { s.Roles[1].Permissions = append(s.Roles[1].Permissions, "users:delete") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seed := testSeed()
+			tt.change(seed)
+			if err := seed.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSeed_ShippedFile(t *testing.T) {
+	seed, err := LoadSeed("../../configs/roles.yaml")
+	if err != nil {
+		t.Fatalf("LoadSeed: %v", err)
+	}
+	if len(seed.Roles) == 0 || len(seed.Permissions) == 0 {
+		t.Errorf("seed = %+v, want roles and permissions", seed)
+	}
+}
+
+func TestReconcileSeed(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	svc := NewService(repo, fakeUserService{}, &fakeSecurityLog{}, zap.NewNop())
+
+	// An admin's role with a seeded name becomes the system role
+	custom := uuid.New()
+	repo.roles[custom] = &Role{ID: custom, Name: "user_admin"}
+
+	if err := svc.ReconcileSeed(ctx, testSeed()); err != nil {
+		t.Fatalf("ReconcileSeed: %v", err)
+	}
+	if len(repo.roles) != 2 || len(repo.permissions) != 3 {
+		t.Fatalf("%d roles and %d permissions, want 2 and 3", len(repo.roles), len(repo.permissions))
+	}
+	if !repo.roles[custom].IsSystem {
+		t.Error("existing role not made a system role")
+	}
+	if codes := repo.grantedCodes(t, "user_admin"); len(codes) != 2 || codes[0] != "users:read" || codes[1] != "users:write" {
+		t.Errorf("user_admin grants %v", codes)
+	}
+
+	// Running it again changes nothing
+	roles, permissions := len(repo.roles), len(repo.permissions)
+	superAdmin, _ := repo.FindRoleByName(ctx, "super_admin")
+	if err := svc.ReconcileSeed(ctx, testSeed()); err != nil {
+		t.Fatalf("ReconcileSeed again: %v", err)
+	}
+	again, _ := repo.FindRoleByName(ctx, "super_admin")
+	if len(repo.roles) != roles || len(repo.permissions) != permissions || again.ID != superAdmin.ID {
+		t.Error("second run created new rows")
+	}
+
+	// Each role grants exactly what the seed lists now
+	seed := testSeed()
+	seed.Roles[1].Permissions = []string{"users:read"}
+	description := "Every permission"
+	seed.Permissions[0].Description = &description
+	if err := svc.ReconcileSeed(ctx, seed); err != nil {
+		t.Fatalf("ReconcileSeed with changes: %v", err)
+	}
+	if codes := repo.grantedCodes(t, "user_admin"); len(codes) != 1 || codes[0] != "users:read" {
+		t.Errorf("user_admin grants %v after the seed dropped users:write", codes)
+	}
+	for _, permission := range repo.permissions {
+		if permission.Code == "*" && *permission.Description != description {
+			t.Errorf("description = %q, want it updated", *permission.Description)
+		}
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	tests := []struct {
+		name         string
+		param        BootstrapAdminParam
+		existing     *userModel.User // holds adminEmail
+		bootstrapped bool            // by an earlier run
+		want         *apperrors.Error
+		wantAdmin    bool
+	}{
+		{
+			name:  "not configured",
+			param: BootstrapAdminParam{Role: "super_admin"},
+		},
+		{
+			name:      "verified user",
+			param:     BootstrapAdminParam{Email: adminEmail, Role: "super_admin"},
+			existing:  &userModel.User{ID: uuid.New(), EmailVerified: true},
+			wantAdmin: true,
+		},
+		{
+			name:     "unverified user",
+			param:    BootstrapAdminParam{Email: adminEmail, Role: "super_admin"},
+			existing: &userModel.User{ID: uuid.New()},
+		},
+		{
+			name:      "new user with a password",
+			param:     BootstrapAdminParam{Email: adminEmail, Password: "correct horse battery", Role: "super_admin"},
+			wantAdmin: true,
+		},
+		{
+			name:  "no user and no password",
+			param: BootstrapAdminParam{Email: adminEmail, Role: "super_admin"},
+			want:  apperrors.ErrXNotFound,
+		},
+		{
+			name:  "unknown role",
+			param: BootstrapAdminParam{Email: adminEmail, Password: "correct horse battery", Role: "root"},
+			want:  apperrors.ErrXNotFound,
+		},
+		{
+			name:         "already bootstrapped",
+			param:        BootstrapAdminParam{Email: adminEmail, Role: "super_admin"},
+			existing:     &userModel.User{ID: uuid.New(), EmailVerified: true},
+			bootstrapped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeRepository()
+			users := &accountUserService{users: map[string]*userModel.User{}}
+			log := &fakeSecurityLog{}
+			svc := NewService(repo, users, log, zap.NewNop())
+			if err := svc.ReconcileSeed(ctx, testSeed()); err != nil {
+				t.Fatalf("ReconcileSeed: %v", err)
+			}
+			if tt.existing != nil {
+				users.users[adminEmail] = tt.existing
+			}
+			if tt.bootstrapped {
+				repo.bootstraps[bootstrapAdmin] = uuid.New()
+			}
+
+			err := svc.BootstrapAdmin(ctx, tt.param)
+			wantErr(t, err, tt.want)
+
+			user := users.users[adminEmail]
+			isAdmin := false
+			if user != nil {
+				superAdmin, _ := repo.FindRoleByName(ctx, "super_admin")
+				isAdmin = repo.userRoles[user.ID][superAdmin.ID]
+			}
+			if isAdmin != tt.wantAdmin {
+				t.Fatalf("admin = %v, want %v", isAdmin, tt.wantAdmin)
+			}
+			if !tt.wantAdmin {
+				if _, done := repo.bootstraps[bootstrapAdmin]; done != tt.bootstrapped {
+					t.Errorf("bootstrap marked done = %v", done)
+				}
+				if len(log.events) != 0 {
+					t.Errorf("events = %v, want none", log.events)
+				}
+				return
+			}
+
+			if !user.EmailVerified {
+				t.Error("admin's email not trusted")
+			}
+			if tt.param.Password != "" && (user.PasswordHash == nil || *user.PasswordHash != tt.param.Password) {
+				t.Error("admin created without the configured password")
+			}
+			if repo.bootstraps[bootstrapAdmin] != user.ID {
+				t.Error("bootstrap not recorded against the admin")
+			}
+			if len(log.events) != 1 || log.events[0].Action != securitylog.ActionAdminBootstrapped || *log.events[0].UserID != user.ID {
+				t.Errorf("events = %+v, want the bootstrap", log.events)
+			}
+		})
+	}
+}
+
+func TestBootstrapAdmin_RunsOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	users := &accountUserService{users: map[string]*userModel.User{}}
+	svc := NewService(repo, users, &fakeSecurityLog{}, zap.NewNop())
+	if err := svc.ReconcileSeed(ctx, testSeed()); err != nil {
+		t.Fatalf("ReconcileSeed: %v", err)
+	}
+	superAdmin, _ := repo.FindRoleByName(ctx, "super_admin")
+
+	// Unverified, so skipped until the address is verified
+	admin := &userModel.User{ID: uuid.New()}
+	users.users[adminEmail] = admin
+	param := BootstrapAdminParam{Email: adminEmail, Role: "super_admin"}
+	if err := svc.BootstrapAdmin(ctx, param); err != nil {
+		t.Fatalf("BootstrapAdmin: %v", err)
+	}
+	admin.EmailVerified = true
+	if err := svc.BootstrapAdmin(ctx, param); err != nil {
+		t.Fatalf("BootstrapAdmin after verification: %v", err)
+	}
+	if !repo.userRoles[admin.ID][superAdmin.ID] {
+		t.Fatal("verified user not made admin")
+	}
+
+	// Removing the role is not undone by a restart, nor is a new email honoured
+	_ = repo.RemoveUserRole(ctx, admin.ID, superAdmin.ID)
+	other := &userModel.User{ID: uuid.New(), EmailVerified: true}
+	users.users["other@example.com"] = other
+	for _, email := range []string{adminEmail, "other@example.com"} {
+		if err := svc.BootstrapAdmin(ctx, BootstrapAdminParam{Email: email, Role: "super_admin"}); err != nil {
+			t.Fatalf("BootstrapAdmin(%s): %v", email, err)
+		}
+	}
+	if repo.userRoles[admin.ID][superAdmin.ID] || repo.userRoles[other.ID][superAdmin.ID] {
+		t.Error("bootstrap ran a second time")
+	}
+}
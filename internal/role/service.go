@@ -0,0 +1,507 @@
+package role
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	"auth-service/internal/securitylog"
+	authconsts "auth-service/internal/shared/consts"
+	autherrors "auth-service/internal/shared/errors"
+	userModel "auth-service/internal/user"
+	dberrors "auth-service/pkg/error"
+	token "auth-service/pkg/jwt"
+	"context"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"regexp"
+	"time"
+)
+
+var (
+	rolePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+	// "users:*" grants every users permission and "*" grants everything
+	permissionPattern = regexp.MustCompile(`^(\*|[a-z][a-z0-9_.-]*(:[a-z0-9_.*-]+)*)$`)
+)
+
+type Service interface {
+	ListRoles(ctx context.Context) ([]Role, error)
+	GetRole(ctx context.Context, id uuid.UUID) (*RoleResponse, error)
+	CreateRole(ctx context.Context, adminID uuid.UUID, param CreateRoleParam) (*Role, error)
+	// UpdateRole changes a role. System roles can only change their
+	// description.
+	UpdateRole(ctx context.Context, adminID, id uuid.UUID, param UpdateRoleParam) (*Role, error)
+	// DeleteRole deletes a role and takes it away from its users, whose
+	// tokens are revoked. System roles cannot be deleted, and the
+	// administrator must hold every permission the role grants.
+	DeleteRole(ctx context.Context, adminID, id uuid.UUID) error
+
+	ListPermissions(ctx context.Context) ([]Permission, error)
+	CreatePermission(ctx context.Context, adminID uuid.UUID, param CreatePermissionParam) (*Permission, error)
+	UpdatePermission(ctx context.Context, adminID, id uuid.UUID, param UpdatePermissionParam) (*Permission, error)
+	// DeletePermission deletes a permission and takes it away from every
+	// role, revoking the tokens of the users who had it. The administrator
+	// must hold it.
+	DeletePermission(ctx context.Context, adminID, id uuid.UUID) error
+
+	// GrantPermission and RevokePermission change what a role grants. The
+	// administrator must hold the permission, and system roles only grant
+	// what is seeded. Revoking also revokes the tokens of the role's users.
+	GrantPermission(ctx context.Context, adminID, roleID, permissionID uuid.UUID) error
+	RevokePermission(ctx context.Context, adminID, roleID, permissionID uuid.UUID) error
+
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]Role, error)
+	// AssignRole and UnassignRole change a user's roles. The administrator
+	// must hold every permission of the role and of the user. Unassigning
+	// also revokes the user's tokens.
+	AssignRole(ctx context.Context, adminID, userID, roleID uuid.UUID) error
+	UnassignRole(ctx context.Context, adminID, userID, roleID uuid.UUID) error
+	// UserAuthorization returns the names of the user's roles and the codes
+	// of the permissions they grant.
+	UserAuthorization(ctx context.Context, userID uuid.UUID) (*Authorization, error)
+
+	// ReconcileSeed creates or updates the seeded permissions and system
+	// roles, and makes each role grant exactly its seeded permissions.
+	// Running it again changes nothing.
+	ReconcileSeed(ctx context.Context, seed *Seed) error
+	// BootstrapAdmin gives the configured user the admin role, creating the
+	// user if needed. It only ever happens once per database.
+	BootstrapAdmin(ctx context.Context, param BootstrapAdminParam) error
+}
+
+type service struct {
+	repo           Repository
+	userSvc        userModel.Service
+	securityLogSvc securitylog.Service
+	logger         *zap.Logger
+}
+
+func NewService(repo Repository, userSvc userModel.Service, securityLogSvc securitylog.Service, logger *zap.Logger) Service {
+	return &service{repo: repo, userSvc: userSvc, securityLogSvc: securityLogSvc, logger: logger}
+}
+
+func (s *service) ListRoles(ctx context.Context) ([]Role, error) {
+	const op = "service.ListRoles"
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+	return roles, nil
+}
+
+func (s *service) GetRole(ctx context.Context, id uuid.UUID) (*RoleResponse, error) {
+	const op = "service.GetRole"
+
+	role, err := s.repo.FindRoleByID(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	permissions, err := s.repo.ListRolePermissions(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	return &RoleResponse{Role: *role, Permissions: permissions}, nil
+}
+
+func (s *service) CreateRole(ctx context.Context, adminID uuid.UUID, param CreateRoleParam) (*Role, error) {
+	const op = "service.CreateRole"
+
+	if !rolePattern.MatchString(param.Name) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.RoleField).WithOp(op)
+	}
+
+	role := &Role{
+		ID:          uuid.New(),
+		Name:        param.Name,
+		Description: param.Description,
+	}
+	if err := s.repo.CreateRole(ctx, role); err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionRoleCreated, securitylog.Metadata{"role": role.Name})
+	return role, nil
+}
+
+func (s *service) UpdateRole(ctx context.Context, adminID, id uuid.UUID, param UpdateRoleParam) (*Role, error) {
+	const op = "service.UpdateRole"
+
+	role, err := s.repo.FindRoleByID(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	metadata := securitylog.Metadata{"role": role.Name}
+	columns := map[string]interface{}{}
+	if param.Name != nil && *param.Name != role.Name {
+		if role.IsSystem {
+			return nil, autherrors.ErrSystemRole.WithOp(op)
+		} else if !rolePattern.MatchString(*param.Name) {
+			return nil, apperrors.ErrInvalidX.WithField(authconsts.RoleField).WithOp(op)
+		}
+		role.Name = *param.Name
+		columns["name"] = role.Name
+		metadata["new_name"] = role.Name
+	}
+	if param.Description != nil {
+		role.Description = param.Description
+		columns["description"] = role.Description
+	}
+
+	if len(columns) == 0 {
+		return role, nil
+	}
+
+	if err := s.repo.UpdateRoleColumns(ctx, id, columns); err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionRoleUpdated, metadata)
+	return role, nil
+}
+
+func (s *service) DeleteRole(ctx context.Context, adminID, id uuid.UUID) error {
+	const op = "service.DeleteRole"
+
+	role, err := s.repo.FindRoleByID(ctx, id)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	} else if role.IsSystem {
+		return autherrors.ErrSystemRole.WithOp(op)
+	}
+
+	admin, err := s.UserAuthorization(ctx, adminID)
+	if err != nil {
+		return err
+	}
+	err = s.checkHoldsRole(ctx, op, admin, id)
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := s.repo.ListRoleUserIDs(ctx, id)
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+
+	if err := s.repo.DeleteRole(ctx, id); err != nil {
+		return dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionRoleDeleted, securitylog.Metadata{"role": role.Name})
+	return s.revokeTokens(ctx, op, userIDs...)
+}
+
+func (s *service) ListPermissions(ctx context.Context) ([]Permission, error) {
+	const op = "service.ListPermissions"
+	permissions, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+	return permissions, nil
+}
+
+func (s *service) CreatePermission(ctx context.Context, adminID uuid.UUID, param CreatePermissionParam) (*Permission, error) {
+	const op = "service.CreatePermission"
+
+	if !permissionPattern.MatchString(param.Code) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.PermissionField).WithOp(op)
+	}
+
+	permission := &Permission{
+		ID:          uuid.New(),
+		Code:        param.Code,
+		Description: param.Description,
+	}
+	if err := s.repo.CreatePermission(ctx, permission); err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionPermissionCreated, securitylog.Metadata{"permission": permission.Code})
+	return permission, nil
+}
+
+func (s *service) UpdatePermission(ctx context.Context, adminID, id uuid.UUID, param UpdatePermissionParam) (*Permission, error) {
+	const op = "service.UpdatePermission"
+
+	permission, err := s.repo.FindPermissionByID(ctx, id)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	if param.Description == nil {
+		return permission, nil
+	}
+
+	permission.Description = param.Description
+	err = s.repo.UpdatePermissionColumns(ctx, id, map[string]interface{}{"description": permission.Description})
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionPermissionUpdated, securitylog.Metadata{"permission": permission.Code})
+	return permission, nil
+}
+
+func (s *service) DeletePermission(ctx context.Context, adminID, id uuid.UUID) error {
+	const op = "service.DeletePermission"
+
+	permission, err := s.repo.FindPermissionByID(ctx, id)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	admin, err := s.UserAuthorization(ctx, adminID)
+	if err != nil {
+		return err
+	}
+	err = checkHolds(op, admin, []string{permission.Code})
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := s.repo.ListPermissionUserIDs(ctx, id)
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+
+	if err := s.repo.DeletePermission(ctx, id); err != nil {
+		return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionPermissionDeleted, securitylog.Metadata{"permission": permission.Code})
+	return s.revokeTokens(ctx, op, userIDs...)
+}
+
+func (s *service) GrantPermission(ctx context.Context, adminID, roleID, permissionID uuid.UUID) error {
+	const op = "service.GrantPermission"
+
+	role, permission, err := s.findGrant(ctx, op, adminID, roleID, permissionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.AddRolePermission(ctx, roleID, permissionID); err != nil {
+		return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionPermissionGranted, securitylog.Metadata{
+		"role":       role.Name,
+		"permission": permission.Code,
+	})
+	return nil
+}
+
+func (s *service) RevokePermission(ctx context.Context, adminID, roleID, permissionID uuid.UUID) error {
+	const op = "service.RevokePermission"
+
+	role, permission, err := s.findGrant(ctx, op, adminID, roleID, permissionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveRolePermission(ctx, roleID, permissionID); err != nil {
+		return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionPermissionRevoked, securitylog.Metadata{
+		"role":       role.Name,
+		"permission": permission.Code,
+	})
+
+	userIDs, err := s.repo.ListRoleUserIDs(ctx, roleID)
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+	return s.revokeTokens(ctx, op, userIDs...)
+}
+
+// findGrant loads the role and permission of a grant the administrator is
+// about to change, and checks that they may change it.
+func (s *service) findGrant(ctx context.Context, op string, adminID, roleID, permissionID uuid.UUID) (*Role, *Permission, error) {
+	role, err := s.repo.FindRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	} else if role.IsSystem {
+		// Seeding would undo the change on the next start anyway
+		return nil, nil, autherrors.ErrSystemRole.WithOp(op)
+	}
+
+	permission, err := s.repo.FindPermissionByID(ctx, permissionID)
+	if err != nil {
+		return nil, nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	admin, err := s.UserAuthorization(ctx, adminID)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = checkHolds(op, admin, []string{permission.Code})
+	if err != nil {
+		return nil, nil, err
+	}
+	return role, permission, nil
+}
+
+func (s *service) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]Role, error) {
+	const op = "service.ListUserRoles"
+
+	if _, err := s.userSvc.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.repo.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+	return roles, nil
+}
+
+func (s *service) AssignRole(ctx context.Context, adminID, userID, roleID uuid.UUID) error {
+	const op = "service.AssignRole"
+
+	if _, err := s.userSvc.GetUser(ctx, userID); err != nil {
+		return err
+	}
+
+	role, err := s.checkCanAssign(ctx, op, adminID, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.AddUserRole(ctx, userID, roleID); err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionRoleAssigned, securitylog.Metadata{
+		"role":    role.Name,
+		"user_id": userID.String(),
+	})
+	return nil
+}
+
+func (s *service) UnassignRole(ctx context.Context, adminID, userID, roleID uuid.UUID) error {
+	const op = "service.UnassignRole"
+
+	role, err := s.checkCanAssign(ctx, op, adminID, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveUserRole(ctx, userID, roleID); err != nil {
+		return dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	s.recordChange(ctx, adminID, securitylog.ActionRoleUnassigned, securitylog.Metadata{
+		"role":    role.Name,
+		"user_id": userID.String(),
+	})
+	return s.revokeTokens(ctx, op, userID)
+}
+
+// checkCanAssign refuses to let an administrator give or take away a role
+// that grants a permission they lack, or change the roles of a user who
+// holds one. Otherwise roles:assign would be enough to become a super admin,
+// or to demote one.
+func (s *service) checkCanAssign(ctx context.Context, op string, adminID, userID, roleID uuid.UUID) (*Role, error) {
+	role, err := s.repo.FindRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	admin, err := s.UserAuthorization(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkHoldsRole(ctx, op, admin, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.UserAuthorization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkHolds(op, admin, user.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// checkHoldsRole checks that the administrator holds every permission the
+// role grants.
+func (s *service) checkHoldsRole(ctx context.Context, op string, admin *Authorization, roleID uuid.UUID) error {
+	permissions, err := s.repo.ListRolePermissions(ctx, roleID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	codes := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		codes = append(codes, permission.Code)
+	}
+	return checkHolds(op, admin, codes)
+}
+
+// checkHolds refuses permissions the administrator does not hold, so nobody
+// can hand out or take away more than they have.
+func checkHolds(op string, admin *Authorization, codes []string) error {
+	for _, code := range codes {
+		if !authmiddleware.HasPermission(admin.Permissions, code) {
+			return autherrors.ErrForbidden.WithOp(op)
+		}
+	}
+	return nil
+}
+
+// revokeTokens signs the users out everywhere after they lose a permission.
+// Access tokens carry permissions, so they would otherwise keep working
+// until they expire.
+func (s *service) revokeTokens(ctx context.Context, op string, userIDs ...uuid.UUID) error {
+	now := time.Now()
+	for _, userID := range userIDs {
+		if err := token.RevokeUserTokens(ctx, userID, now); err != nil {
+			return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+	}
+	return nil
+}
+
+// recordChange logs a change to roles or permissions against the
+// administrator who made it.
+func (s *service) recordChange(ctx context.Context, adminID uuid.UUID, action securitylog.Action, metadata securitylog.Metadata) {
+	s.securityLogSvc.Record(ctx, securitylog.Event{
+		UserID:   &adminID,
+		Action:   action,
+		Status:   securitylog.StatusSuccess,
+		Metadata: metadata,
+	})
+}
+
+func (s *service) UserAuthorization(ctx context.Context, userID uuid.UUID) (*Authorization, error) {
+	const op = "service.UserAuthorization"
+
+	roles, err := s.repo.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.RoleField).WithOp(op)
+	}
+
+	permissions, err := s.repo.ListUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.PermissionField).WithOp(op)
+	}
+
+	authz := &Authorization{
+		Roles:       make([]string, 0, len(roles)),
+		Permissions: make([]string, 0, len(permissions)),
+	}
+	for _, role := range roles {
+		authz.Roles = append(authz.Roles, role.Name)
+	}
+	for _, permission := range permissions {
+		authz.Permissions = append(authz.Permissions, permission.Code)
+	}
+	return authz, nil
+}
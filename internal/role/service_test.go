@@ -0,0 +1,673 @@
+package role
+
+import (
+	"auth-service/internal/securitylog"
+	autherrors "auth-service/internal/shared/errors"
+	"auth-service/internal/testutil"
+	userModel "auth-service/internal/user"
+	token "auth-service/pkg/jwt"
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+// fakeRepository keeps roles, permissions and their links in memory.
+type fakeRepository struct {
+	roles       map[uuid.UUID]*Role
+	permissions map[uuid.UUID]*Permission
+	grants      map[uuid.UUID]map[uuid.UUID]bool // role to permissions
+	userRoles   map[uuid.UUID]map[uuid.UUID]bool // user to roles
+	bootstraps  map[string]uuid.UUID
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		roles:       map[uuid.UUID]*Role{},
+		permissions: map[uuid.UUID]*Permission{},
+		grants:      map[uuid.UUID]map[uuid.UUID]bool{},
+		userRoles:   map[uuid.UUID]map[uuid.UUID]bool{},
+		bootstraps:  map[string]uuid.UUID{},
+	}
+}
+
+func (r *fakeRepository) CreateRole(_ context.Context, role *Role) error {
+	if _, err := r.FindRoleByName(context.Background(), role.Name); err == nil {
+		return gorm.ErrDuplicatedKey
+	}
+	copied := *role
+	r.roles[role.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepository) FindRoleByID(_ context.Context, id uuid.UUID) (*Role, error) {
+	role, ok := r.roles[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *role
+	return &copied, nil
+}
+
+func (r *fakeRepository) ListRoles(context.Context) ([]Role, error) {
+	roles := make([]Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, *role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *fakeRepository) UpdateRoleColumns(_ context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	role, ok := r.roles[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if name, ok := columns["name"]; ok {
+		role.Name = name.(string)
+	}
+	if description, ok := columns["description"]; ok {
+		role.Description = description.(*string)
+	}
+	return nil
+}
+
+func (r *fakeRepository) DeleteRole(_ context.Context, id uuid.UUID) error {
+	role, ok := r.roles[id]
+	if !ok || role.IsSystem {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.roles, id)
+	delete(r.grants, id)
+	for _, roles := range r.userRoles {
+		delete(roles, id)
+	}
+	return nil
+}
+
+func (r *fakeRepository) CreatePermission(_ context.Context, permission *Permission) error {
+	for _, existing := range r.permissions {
+		if existing.Code == permission.Code {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	copied := *permission
+	r.permissions[permission.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepository) FindPermissionByID(_ context.Context, id uuid.UUID) (*Permission, error) {
+	permission, ok := r.permissions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *permission
+	return &copied, nil
+}
+
+func (r *fakeRepository) ListPermissions(context.Context) ([]Permission, error) {
+	return r.sortedPermissions(func(uuid.UUID) bool { return true }), nil
+}
+
+func (r *fakeRepository) UpdatePermissionColumns(_ context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	permission, ok := r.permissions[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if description, ok := columns["description"]; ok {
+		permission.Description = description.(*string)
+	}
+	return nil
+}
+
+func (r *fakeRepository) DeletePermission(_ context.Context, id uuid.UUID) error {
+	if _, ok := r.permissions[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.permissions, id)
+	for _, permissions := range r.grants {
+		delete(permissions, id)
+	}
+	return nil
+}
+
+func (r *fakeRepository) AddRolePermission(_ context.Context, roleID, permissionID uuid.UUID) error {
+	if r.grants[roleID] == nil {
+		r.grants[roleID] = map[uuid.UUID]bool{}
+	}
+	r.grants[roleID][permissionID] = true
+	return nil
+}
+
+func (r *fakeRepository) RemoveRolePermission(_ context.Context, roleID, permissionID uuid.UUID) error {
+	if !r.grants[roleID][permissionID] {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.grants[roleID], permissionID)
+	return nil
+}
+
+func (r *fakeRepository) ListRolePermissions(_ context.Context, roleID uuid.UUID) ([]Permission, error) {
+	return r.sortedPermissions(func(id uuid.UUID) bool { return r.grants[roleID][id] }), nil
+}
+
+func (r *fakeRepository) ListRoleUserIDs(_ context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	for userID, roles := range r.userRoles {
+		if roles[roleID] {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (r *fakeRepository) ListPermissionUserIDs(_ context.Context, permissionID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	for userID, roles := range r.userRoles {
+		for roleID := range roles {
+			if r.grants[roleID][permissionID] {
+				userIDs = append(userIDs, userID)
+				break
+			}
+		}
+	}
+	return userIDs, nil
+}
+
+func (r *fakeRepository) AddUserRole(_ context.Context, userID, roleID uuid.UUID) error {
+	if r.userRoles[userID] == nil {
+		r.userRoles[userID] = map[uuid.UUID]bool{}
+	}
+	r.userRoles[userID][roleID] = true
+	return nil
+}
+
+func (r *fakeRepository) RemoveUserRole(_ context.Context, userID, roleID uuid.UUID) error {
+	if !r.userRoles[userID][roleID] {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.userRoles[userID], roleID)
+	return nil
+}
+
+func (r *fakeRepository) ListUserRoles(_ context.Context, userID uuid.UUID) ([]Role, error) {
+	var roles []Role
+	for roleID := range r.userRoles[userID] {
+		roles = append(roles, *r.roles[roleID])
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *fakeRepository) ListUserPermissions(_ context.Context, userID uuid.UUID) ([]Permission, error) {
+	return r.sortedPermissions(func(id uuid.UUID) bool {
+		for roleID := range r.userRoles[userID] {
+			if r.grants[roleID][id] {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *fakeRepository) sortedPermissions(include func(uuid.UUID) bool) []Permission {
+	var permissions []Permission
+	for id, permission := range r.permissions {
+		if include(id) {
+			permissions = append(permissions, *permission)
+		}
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Code < permissions[j].Code })
+	return permissions
+}
+
+func (r *fakeRepository) FindRoleByName(_ context.Context, name string) (*Role, error) {
+	for _, role := range r.roles {
+		if role.Name == name {
+			copied := *role
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRepository) UpsertSystemRole(_ context.Context, role *Role) (bool, error) {
+	role.IsSystem = true
+	for _, existing := range r.roles {
+		if existing.Name != role.Name {
+			continue
+		}
+		role.ID = existing.ID
+		if existing.IsSystem && sameDescription(existing.Description, role.Description) {
+			return false, nil
+		}
+		existing.IsSystem, existing.Description = true, role.Description
+		return true, nil
+	}
+	copied := *role
+	r.roles[role.ID] = &copied
+	return true, nil
+}
+
+func (r *fakeRepository) UpsertPermission(_ context.Context, permission *Permission) (bool, error) {
+	for _, existing := range r.permissions {
+		if existing.Code != permission.Code {
+			continue
+		}
+		permission.ID = existing.ID
+		if sameDescription(existing.Description, permission.Description) {
+			return false, nil
+		}
+		existing.Description = permission.Description
+		return true, nil
+	}
+	copied := *permission
+	r.permissions[permission.ID] = &copied
+	return true, nil
+}
+
+func sameDescription(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func (r *fakeRepository) SetRolePermissions(_ context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error {
+	r.grants[roleID] = map[uuid.UUID]bool{}
+	for _, id := range permissionIDs {
+		r.grants[roleID][id] = true
+	}
+	return nil
+}
+
+func (r *fakeRepository) IsBootstrapped(_ context.Context, name string) (bool, error) {
+	_, ok := r.bootstraps[name]
+	return ok, nil
+}
+
+func (r *fakeRepository) CompleteBootstrap(ctx context.Context, name string, userID, roleID uuid.UUID) (bool, error) {
+	if _, ok := r.bootstraps[name]; ok {
+		return false, nil
+	}
+	r.bootstraps[name] = userID
+	return true, r.AddUserRole(ctx, userID, roleID)
+}
+
+// fakeUserService knows every user; other methods are not used.
+type fakeUserService struct {
+	userModel.Service
+}
+
+func (fakeUserService) GetUser(_ context.Context, id uuid.UUID) (*userModel.User, error) {
+	return &userModel.User{ID: id, IsActive: true, CreatedAt: time.Now()}, nil
+}
+
+type fakeSecurityLog struct {
+	events []securitylog.Event
+}
+
+func (l *fakeSecurityLog) Record(_ context.Context, event securitylog.Event) {
+	l.events = append(l.events, event)
+}
+
+// fixture is a service with some of the seeded roles, a custom support role
+// and a user holding each.
+type fixture struct {
+	svc  Service
+	repo *fakeRepository
+	log  *fakeSecurityLog
+
+	superAdmin, accessAdmin, userAdmin, supportAgent, member    uuid.UUID
+	superAdminRole, accessAdminRole, userAdminRole, supportRole uuid.UUID
+	permissions                                                 map[string]uuid.UUID
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	repo := newFakeRepository()
+	log := &fakeSecurityLog{}
+	f := &fixture{
+		svc:          NewService(repo, fakeUserService{}, log, zap.NewNop()),
+		repo:         repo,
+		log:          log,
+		superAdmin:   uuid.New(),
+		accessAdmin:  uuid.New(),
+		userAdmin:    uuid.New(),
+		supportAgent: uuid.New(),
+		member:       uuid.New(),
+		permissions:  map[string]uuid.UUID{},
+	}
+
+	for _, code := range []string{"*", "users:read", "users:write", "roles:read", "roles:write", "roles:assign"} {
+		id := uuid.New()
+		repo.permissions[id] = &Permission{ID: id, Code: code}
+		f.permissions[code] = id
+	}
+
+	addRole := func(name string, system bool, codes ...string) uuid.UUID {
+		id := uuid.New()
+		repo.roles[id] = &Role{ID: id, Name: name, IsSystem: system}
+		for _, code := range codes {
+			_ = repo.AddRolePermission(context.Background(), id, f.permissions[code])
+		}
+		return id
+	}
+	f.superAdminRole = addRole("super_admin", true, "*")
+	f.accessAdminRole = addRole("access_admin", true, "roles:read", "roles:write", "roles:assign")
+	f.userAdminRole = addRole("user_admin", true, "users:read", "users:write")
+	f.supportRole = addRole("support", false, "users:read")
+
+	for userID, roleID := range map[uuid.UUID]uuid.UUID{
+		f.superAdmin:   f.superAdminRole,
+		f.accessAdmin:  f.accessAdminRole,
+		f.userAdmin:    f.userAdminRole,
+		f.supportAgent: f.supportRole,
+	} {
+		_ = repo.AddUserRole(context.Background(), userID, roleID)
+	}
+	return f
+}
+
+func (f *fixture) lastAction() securitylog.Action {
+	if len(f.log.events) == 0 {
+		return ""
+	}
+	return f.log.events[len(f.log.events)-1].Action
+}
+
+// wantErr checks err against want, where nil means success.
+func wantErr(t *testing.T, err error, want *apperrors.Error) {
+	t.Helper()
+	if want == nil && err != nil {
+		t.Errorf("err = %v, want success", err)
+	} else if want != nil && !apperrors.Is(err, want) {
+		t.Errorf("err = %v, want %v", err, want)
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		admin func(*fixture) uuid.UUID
+		role  func(*fixture) uuid.UUID
+		want  *apperrors.Error
+	}{
+		{"custom role", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, nil},
+		{"system role", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.userAdminRole }, autherrors.ErrSystemRole},
+		{"role grants a permission the admin lacks", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, autherrors.ErrForbidden},
+		{"unknown role", func(f *fixture) uuid.UUID { return f.superAdmin }, func(*fixture) uuid.UUID { return uuid.New() }, apperrors.ErrXNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			roleID := tt.role(f)
+
+			err := f.svc.DeleteRole(context.Background(), tt.admin(f), roleID)
+			wantErr(t, err, tt.want)
+
+			_, exists := f.repo.roles[roleID]
+			if tt.want == nil && (exists || f.lastAction() != securitylog.ActionRoleDeleted) {
+				t.Errorf("role still exists: %v, last event %q", exists, f.lastAction())
+			} else if tt.want != nil && len(f.log.events) != 0 {
+				t.Errorf("refused change recorded as %q", f.lastAction())
+			}
+		})
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	rename := func(name string) UpdateRoleParam { return UpdateRoleParam{Name: &name} }
+	description := "Changed"
+
+	tests := []struct {
+		name  string
+		role  func(*fixture) uuid.UUID
+		param UpdateRoleParam
+		want  *apperrors.Error
+	}{
+		{"rename custom role", func(f *fixture) uuid.UUID { return f.supportRole }, rename("helpdesk"), nil},
+		{"rename system role", func(f *fixture) uuid.UUID { return f.superAdminRole }, rename("root"), autherrors.ErrSystemRole},
+		{"system role keeps its name", func(f *fixture) uuid.UUID { return f.superAdminRole }, rename("super_admin"), nil},
+		{"describe system role", func(f *fixture) uuid.UUID { return f.superAdminRole }, UpdateRoleParam{Description: &description}, nil},
+		{"invalid name", func(f *fixture) uuid.UUID { return f.supportRole }, rename("Help Desk"), apperrors.ErrInvalidX},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			roleID := tt.role(f)
+			before := *f.repo.roles[roleID]
+
+			_, err := f.svc.UpdateRole(context.Background(), f.superAdmin, roleID, tt.param)
+			wantErr(t, err, tt.want)
+
+			if tt.want != nil && *f.repo.roles[roleID] != before {
+				t.Errorf("refused update changed the role to %+v", *f.repo.roles[roleID])
+			}
+		})
+	}
+}
+
+func TestGrantPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		admin      func(*fixture) uuid.UUID
+		role       func(*fixture) uuid.UUID
+		permission string
+		want       *apperrors.Error
+	}{
+		{"held permission", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "roles:read", nil},
+		{"wildcard covers it", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "users:write", nil},
+		{"everything to a custom role", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "*", autherrors.ErrForbidden},
+		{"permission not held", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "users:write", autherrors.ErrForbidden},
+		{"everything to the admin's own system role", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.accessAdminRole }, "*", autherrors.ErrSystemRole},
+		{"system role, even by a super admin", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.userAdminRole }, "roles:read", autherrors.ErrSystemRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			roleID, permissionID := tt.role(f), f.permissions[tt.permission]
+
+			err := f.svc.GrantPermission(context.Background(), tt.admin(f), roleID, permissionID)
+			wantErr(t, err, tt.want)
+
+			if granted := f.repo.grants[roleID][permissionID]; granted != (tt.want == nil) {
+				t.Errorf("granted = %v", granted)
+			}
+			if tt.want == nil && f.lastAction() != securitylog.ActionPermissionGranted {
+				t.Errorf("last event %q, want the grant recorded", f.lastAction())
+			}
+		})
+	}
+}
+
+func TestRevokePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		admin      func(*fixture) uuid.UUID
+		role       func(*fixture) uuid.UUID
+		permission string
+		want       *apperrors.Error
+	}{
+		{"held permission", func(f *fixture) uuid.UUID { return f.userAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "users:read", nil},
+		{"permission not held", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "users:read", autherrors.ErrForbidden},
+		{"system role", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.superAdminRole }, "*", autherrors.ErrSystemRole},
+		{"not granted", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, "users:write", apperrors.ErrXNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			roleID, permissionID := tt.role(f), f.permissions[tt.permission]
+			granted := f.repo.grants[roleID][permissionID]
+
+			err := f.svc.RevokePermission(context.Background(), tt.admin(f), roleID, permissionID)
+			wantErr(t, err, tt.want)
+
+			if f.repo.grants[roleID][permissionID] != (granted && tt.want != nil) {
+				t.Errorf("granted = %v after revoking", f.repo.grants[roleID][permissionID])
+			}
+			if tt.want == nil && f.lastAction() != securitylog.ActionPermissionRevoked {
+				t.Errorf("last event %q, want the revoke recorded", f.lastAction())
+			}
+		})
+	}
+}
+
+func TestAssignRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin, user func(*fixture) uuid.UUID
+		role        func(*fixture) uuid.UUID
+		want        *apperrors.Error
+	}{
+		{"role within the admin's permissions", func(f *fixture) uuid.UUID { return f.userAdmin }, func(f *fixture) uuid.UUID { return f.member }, func(f *fixture) uuid.UUID { return f.supportRole }, nil},
+		{"super admin assigns anything", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.member }, func(f *fixture) uuid.UUID { return f.superAdminRole }, nil},
+		{"super admin to themselves", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.superAdminRole }, autherrors.ErrForbidden},
+		{"role with a permission the admin lacks", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.member }, func(f *fixture) uuid.UUID { return f.supportRole }, autherrors.ErrForbidden},
+		{"user who outranks the admin", func(f *fixture) uuid.UUID { return f.userAdmin }, func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.supportRole }, autherrors.ErrForbidden},
+		{"unknown role", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.member }, func(*fixture) uuid.UUID { return uuid.New() }, apperrors.ErrXNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			userID, roleID := tt.user(f), tt.role(f)
+			had := f.repo.userRoles[userID][roleID]
+
+			err := f.svc.AssignRole(context.Background(), tt.admin(f), userID, roleID)
+			wantErr(t, err, tt.want)
+
+			if has := f.repo.userRoles[userID][roleID]; has != (had || tt.want == nil) {
+				t.Errorf("user has role = %v", has)
+			}
+			if tt.want == nil && f.lastAction() != securitylog.ActionRoleAssigned {
+				t.Errorf("last event %q, want the assignment recorded", f.lastAction())
+			}
+		})
+	}
+}
+
+func TestUnassignRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin, user func(*fixture) uuid.UUID
+		role        func(*fixture) uuid.UUID
+		want        *apperrors.Error
+	}{
+		{"role within the admin's permissions", func(f *fixture) uuid.UUID { return f.userAdmin }, func(f *fixture) uuid.UUID { return f.supportAgent }, func(f *fixture) uuid.UUID { return f.supportRole }, nil},
+		{"super admin demotes anyone", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.accessAdminRole }, nil},
+		{"lower admin demotes a super admin", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.superAdminRole }, autherrors.ErrForbidden},
+		{"user who outranks the admin", func(f *fixture) uuid.UUID { return f.accessAdmin }, func(f *fixture) uuid.UUID { return f.userAdmin }, func(f *fixture) uuid.UUID { return f.userAdminRole }, autherrors.ErrForbidden},
+		{"role the user does not have", func(f *fixture) uuid.UUID { return f.superAdmin }, func(f *fixture) uuid.UUID { return f.member }, func(f *fixture) uuid.UUID { return f.supportRole }, apperrors.ErrXNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			userID, roleID := tt.user(f), tt.role(f)
+			had := f.repo.userRoles[userID][roleID]
+
+			err := f.svc.UnassignRole(context.Background(), tt.admin(f), userID, roleID)
+			wantErr(t, err, tt.want)
+
+			if has := f.repo.userRoles[userID][roleID]; has != (had && tt.want != nil) {
+				t.Errorf("user has role = %v", has)
+			}
+			if tt.want == nil && f.lastAction() != securitylog.ActionRoleUnassigned {
+				t.Errorf("last event %q, want the unassignment recorded", f.lastAction())
+			}
+		})
+	}
+}
+
+func TestDeletePermission_NotHeld(t *testing.T) {
+	f := newFixture(t)
+	permissionID := f.permissions["users:write"]
+
+	err := f.svc.DeletePermission(context.Background(), f.accessAdmin, permissionID)
+	wantErr(t, err, autherrors.ErrForbidden)
+	if _, ok := f.repo.permissions[permissionID]; !ok {
+		t.Error("permission deleted by an admin who does not hold it")
+	}
+
+	err = f.svc.DeletePermission(context.Background(), f.superAdmin, permissionID)
+	wantErr(t, err, nil)
+}
+
+func TestChangesRevokeTokensOfUsersWhoLoseAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*fixture) error
+		revoked func(*fixture) []uuid.UUID
+	}{
+		{
+			"unassign role",
+			func(f *fixture) error {
+				return f.svc.UnassignRole(context.Background(), f.superAdmin, f.supportAgent, f.supportRole)
+			},
+			func(f *fixture) []uuid.UUID { return []uuid.UUID{f.supportAgent} },
+		},
+		{
+			"revoke permission",
+			func(f *fixture) error {
+				return f.svc.RevokePermission(context.Background(), f.superAdmin, f.supportRole, f.permissions["users:read"])
+			},
+			func(f *fixture) []uuid.UUID { return []uuid.UUID{f.supportAgent} },
+		},
+		{
+			"delete role",
+			func(f *fixture) error { return f.svc.DeleteRole(context.Background(), f.superAdmin, f.supportRole) },
+			func(f *fixture) []uuid.UUID { return []uuid.UUID{f.supportAgent} },
+		},
+		{
+			"delete permission",
+			func(f *fixture) error {
+				return f.svc.DeletePermission(context.Background(), f.superAdmin, f.permissions["users:read"])
+			},
+			func(f *fixture) []uuid.UUID { return []uuid.UUID{f.userAdmin, f.supportAgent} },
+		},
+		{
+			"assign role",
+			func(f *fixture) error {
+				return f.svc.AssignRole(context.Background(), f.superAdmin, f.member, f.supportRole)
+			},
+			func(*fixture) []uuid.UUID { return nil },
+		},
+		{
+			"grant permission",
+			func(f *fixture) error {
+				return f.svc.GrantPermission(context.Background(), f.superAdmin, f.supportRole, f.permissions["users:write"])
+			},
+			func(*fixture) []uuid.UUID { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			issuedAt := jwt.NewNumericDate(time.Now().Add(-time.Second))
+
+			if err := tt.change(f); err != nil {
+				t.Fatalf("change: %v", err)
+			}
+
+			want := map[uuid.UUID]bool{}
+			for _, userID := range tt.revoked(f) {
+				want[userID] = true
+			}
+			for _, userID := range []uuid.UUID{f.superAdmin, f.accessAdmin, f.userAdmin, f.supportAgent, f.member} {
+				revoked, err := token.IsRevokedForUser(context.Background(), userID, issuedAt)
+				if err != nil {
+					t.Fatalf("IsRevokedForUser: %v", err)
+				}
+				if revoked != want[userID] {
+					t.Errorf("user %s: revoked = %v, want %v", userID, revoked, want[userID])
+				}
+			}
+		})
+	}
+}
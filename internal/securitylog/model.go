@@ -0,0 +1,93 @@
+package securitylog
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionLogin                  Action = "login"
+	ActionLoginFailed            Action = "login_failed"
+	ActionLogout                 Action = "logout"
+	ActionTokenRefresh           Action = "token_refresh"
+	ActionPasswordChange         Action = "password_change"
+	ActionEmailChange            Action = "email_change"
+	ActionTokenReuse             Action = "token_reuse"
+	ActionPasswordReset          Action = "password_reset"
+	ActionMFAEnabled             Action = "mfa_enabled"
+	ActionMFADisabled            Action = "mfa_disabled"
+	ActionRecoveryCodesGenerated Action = "recovery_codes_generated"
+	ActionRecoveryCodeUsed       Action = "recovery_code_used"
+	ActionPasskeyAdded           Action = "passkey_added"
+	ActionPasskeyRemoved         Action = "passkey_removed"
+	ActionAuthMethodLinked       Action = "auth_method_linked"
+	ActionAuthMethodUnlinked     Action = "auth_method_unlinked"
+	ActionOAuthClientCreated     Action = "oauth_client_created"
+	ActionOAuthClientUpdated     Action = "oauth_client_updated"
+	ActionOAuthClientRotated     Action = "oauth_client_secret_rotated"
+	ActionOAuthClientDeleted     Action = "oauth_client_deleted"
+	ActionAdminBootstrapped      Action = "admin_bootstrapped"
+	ActionRoleCreated            Action = "role_created"
+	ActionRoleUpdated            Action = "role_updated"
+	ActionRoleDeleted            Action = "role_deleted"
+	ActionPermissionCreated      Action = "permission_created"
+	ActionPermissionUpdated      Action = "permission_updated"
+	ActionPermissionDeleted      Action = "permission_deleted"
+	ActionPermissionGranted      Action = "permission_granted"
+	ActionPermissionRevoked      Action = "permission_revoked"
+	ActionRoleAssigned           Action = "role_assigned"
+	ActionRoleUnassigned         Action = "role_unassigned"
+	ActionAccountClosed          Action = "account_closed"
+)
+
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+	StatusRevoked Status = "revoked"
+	StatusExpired Status = "expired"
+)
+
+type SecurityLog struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	UserID            *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	Action            Action     `json:"action" db:"action"`
+	Status            Status     `json:"status" db:"status"`
+	IPAddress         *string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent         *string    `json:"user_agent,omitempty" db:"user_agent"`
+	DeviceFingerprint *string    `json:"device_fingerprint,omitempty" db:"device_fingerprint"`
+	Metadata          Metadata   `json:"metadata" db:"metadata"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Metadata is stored as JSONB.
+type Metadata map[string]interface{}
+
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *Metadata) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = Metadata{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported metadata type")
+	}
+	return json.Unmarshal(data, m)
+}
@@ -0,0 +1,22 @@
+package securitylog
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, log *SecurityLog) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, log *SecurityLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
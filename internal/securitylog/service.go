@@ -0,0 +1,57 @@
+package securitylog
+
+import (
+	"auth-service/internal/shared/clientinfo"
+	"context"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"time"
+)
+
+type Event struct {
+	UserID   *uuid.UUID
+	Action   Action
+	Status   Status
+	Metadata Metadata
+}
+
+type Service interface {
+	// Record stores a security event, attributed to the client in ctx.
+	// Failures are logged rather than returned so auditing never blocks a request.
+	Record(ctx context.Context, event Event)
+}
+
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{repo: repo, logger: logger}
+}
+
+func (s *service) Record(ctx context.Context, event Event) {
+	client := clientinfo.FromContext(ctx)
+
+	log := &SecurityLog{
+		ID:        uuid.New(),
+		UserID:    event.UserID,
+		Action:    event.Action,
+		Status:    event.Status,
+		Metadata:  event.Metadata,
+		CreatedAt: time.Now().UTC(),
+	}
+	if client.IPAddress != "" {
+		log.IPAddress = &client.IPAddress
+	}
+	if client.UserAgent != "" {
+		log.UserAgent = &client.UserAgent
+	}
+
+	if err := s.repo.Create(ctx, log); err != nil {
+		s.logger.Error("Record security event error",
+			zap.String("action", string(event.Action)),
+			zap.Any("user_id", event.UserID),
+			zap.Error(err))
+	}
+}
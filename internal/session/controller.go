@@ -0,0 +1,125 @@
+package session
+
+import (
+	authmiddleware "auth-service/internal/middleware"
+	authconsts "auth-service/internal/shared/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"go.uber.org/zap"
+)
+
+type Controller struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func NewController(service Service, logger *zap.Logger) *Controller {
+	return &Controller{service: service, logger: logger}
+}
+
+// ListSessions godoc
+// @Summary List sessions
+// @Description List the devices the current user is logged in on
+// @Tags Sessions
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]Response} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/sessions [get]
+func (ctrl *Controller) ListSessions(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+	currentID, _ := authmiddleware.SessionIDFromContext(c)
+
+	ctx := c.Request.Context()
+	sessions, err := ctrl.service.ListSessions(ctx, userID)
+	if err != nil {
+		ctrl.logger.Error("ListSessions error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	resp := make([]*Response, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, session.Response(currentID))
+	}
+
+	response.Success(c, success.XFound.WithField(authconsts.SessionField), resp)
+}
+
+// RevokeSession godoc
+// @Summary Revoke session
+// @Description Log out one of the current user's devices
+// @Tags Sessions
+// @Produce json
+// @Security BearerTokenAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 404 {object} response.Response "Session not found"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/sessions/{id} [delete]
+func (ctrl *Controller) RevokeSession(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ctrl.logger.Debug("Invalid session ID", zap.Error(err))
+		response.Error(c, apperrors.ErrInvalidX.WithField(authconsts.SessionField))
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		ctrl.logger.Error("RevokeSession error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.SessionField), nil)
+}
+
+// RevokeOtherSessions godoc
+// @Summary Revoke other sessions
+// @Description Log out every device except the current one
+// @Tags Sessions
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/sessions [delete]
+func (ctrl *Controller) RevokeOtherSessions(c *gin.Context) {
+	userID, err := authmiddleware.UserIDFromContext(c)
+	if err != nil {
+		ctrl.logger.Debug("Missing user in context", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+	currentID, _ := authmiddleware.SessionIDFromContext(c)
+
+	ctx := c.Request.Context()
+	err = ctrl.service.RevokeOtherSessions(ctx, userID, currentID)
+	if err != nil {
+		ctrl.logger.Error("RevokeOtherSessions error", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XDeleted.WithField(authconsts.SessionField), nil)
+}
@@ -0,0 +1,46 @@
+package session
+
+import (
+	"auth-service/db"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Session is a logged-in device. Its ID is the refresh token family ID, so
+// every refresh token rotated from a login belongs to the same session.
+type Session struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	DeviceName *string    `json:"device_name,omitempty" db:"device_name"`
+	UserAgent  *string    `json:"user_agent,omitempty" db:"user_agent"`
+	IPAddress  *string    `json:"ip_address,omitempty" db:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+
+	// How the user logged in, as RFC 8176 amr values
+	AuthMethods db.StringArray `json:"auth_methods" db:"auth_methods"`
+}
+
+type Response struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName *string   `json:"device_name,omitempty"`
+	UserAgent  *string   `json:"user_agent,omitempty"`
+	IPAddress  *string   `json:"ip_address,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func (s *Session) Response(currentID uuid.UUID) *Response {
+	return &Response{
+		ID:         s.ID,
+		DeviceName: s.DeviceName,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		Current:    s.ID == currentID,
+	}
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, session *Session) error
+	FindActive(ctx context.Context, id uuid.UUID) (*Session, error)
+	ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	Touch(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	RevokeAllByUser(ctx context.Context, userID uuid.UUID, exceptIDs ...uuid.UUID) ([]uuid.UUID, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) activeQuery(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Model(&Session{}).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now().UTC())
+}
+
+func (r *repository) Create(ctx context.Context, session *Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *repository) FindActive(ctx context.Context, id uuid.UUID) (*Session, error) {
+	var session Session
+	result := r.activeQuery(ctx).
+		Where("id = ?", id).
+		First(&session)
+	return &session, result.Error
+}
+
+func (r *repository) ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	var sessions []Session
+	result := r.activeQuery(ctx).
+		Where("user_id = ?", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions)
+	return sessions, result.Error
+}
+
+func (r *repository) Touch(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	result := r.activeQuery(ctx).
+		Where("id = ?", id).
+		Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	result := r.activeQuery(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAllByUser revokes the user's active sessions except exceptIDs and
+// returns the IDs of the sessions it revoked.
+func (r *repository) RevokeAllByUser(ctx context.Context, userID uuid.UUID, exceptIDs ...uuid.UUID) ([]uuid.UUID, error) {
+	now := time.Now().UTC()
+
+	var sessions []Session
+	query := r.db.WithContext(ctx).
+		Model(&sessions).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now)
+
+	if len(exceptIDs) > 0 {
+		query = query.Where("id NOT IN ?", exceptIDs)
+	}
+
+	if err := query.Update("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	return ids, nil
+}
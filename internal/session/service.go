@@ -0,0 +1,153 @@
+package session
+
+import (
+	"auth-service/internal/shared/clientinfo"
+	authconsts "auth-service/internal/shared/consts"
+	dberrors "auth-service/pkg/error"
+	token "auth-service/pkg/jwt"
+	"context"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+type Service interface {
+	// CreateSession records a login. authMethods says how the user
+	// authenticated, as RFC 8176 amr values.
+	CreateSession(ctx context.Context, userID, sessionID uuid.UUID, deviceName *string, authMethods []string) error
+	// GetSession returns one of the user's active sessions.
+	GetSession(ctx context.Context, userID, sessionID uuid.UUID) (*Session, error)
+	TouchSession(ctx context.Context, sessionID uuid.UUID) error
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{repo: repo, logger: logger}
+}
+
+func (s *service) CreateSession(ctx context.Context, userID, sessionID uuid.UUID, deviceName *string, authMethods []string) error {
+	const op = "service.CreateSession"
+
+	now := time.Now().UTC()
+	session := &Session{
+		ID:          sessionID,
+		UserID:      userID,
+		DeviceName:  deviceName,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(token.RefreshExpiry()),
+		AuthMethods: authMethods,
+	}
+
+	client := clientinfo.FromContext(ctx)
+	if client.IPAddress != "" {
+		session.IPAddress = &client.IPAddress
+	}
+	if client.UserAgent != "" {
+		session.UserAgent = &client.UserAgent
+	}
+
+	if err := s.repo.Create(ctx, session); err != nil {
+		return dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	}
+	return nil
+}
+
+// TouchSession records activity on the session and extends it for the
+// lifetime of the newly issued refresh token.
+func (s *service) TouchSession(ctx context.Context, sessionID uuid.UUID) error {
+	const op = "service.TouchSession"
+
+	now := time.Now().UTC()
+	fields := map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   now.Add(token.RefreshExpiry()),
+	}
+
+	client := clientinfo.FromContext(ctx)
+	if client.IPAddress != "" {
+		fields["ip_address"] = client.IPAddress
+	}
+	if client.UserAgent != "" {
+		fields["user_agent"] = client.UserAgent
+	}
+
+	if err := s.repo.Touch(ctx, sessionID, fields); err != nil {
+		return dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	}
+	return nil
+}
+
+func (s *service) GetSession(ctx context.Context, userID, sessionID uuid.UUID) (*Session, error) {
+	const op = "service.GetSession"
+
+	session, err := s.repo.FindActive(ctx, sessionID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	} else if session.UserID != userID {
+		return nil, apperrors.ErrXNotFound.WithField(authconsts.SessionField).WithOp(op)
+	}
+	return session, nil
+}
+
+func (s *service) ListSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	const op = "service.ListSessions"
+	sessions, err := s.repo.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	}
+	return sessions, nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	const op = "service.RevokeSession"
+
+	if err := s.repo.Revoke(ctx, userID, sessionID); err != nil {
+		return dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	}
+
+	if err := token.RevokeRefreshTokenFamily(ctx, sessionID.String()); err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	return nil
+}
+
+func (s *service) RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID) error {
+	const op = "service.RevokeOtherSessions"
+
+	sessionIDs, err := s.repo.RevokeAllByUser(ctx, userID, currentID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	}
+
+	return s.revokeTokenFamilies(ctx, op, sessionIDs)
+}
+
+func (s *service) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	const op = "service.RevokeAllSessions"
+
+	sessionIDs, err := s.repo.RevokeAllByUser(ctx, userID)
+	if err != nil {
+		return dberrors.WrapDBError(err, authconsts.SessionField).WithOp(op)
+	}
+
+	return s.revokeTokenFamilies(ctx, op, sessionIDs)
+}
+
+func (s *service) revokeTokenFamilies(ctx context.Context, op string, sessionIDs []uuid.UUID) error {
+	for _, sessionID := range sessionIDs {
+		if err := token.RevokeRefreshTokenFamily(ctx, sessionID.String()); err != nil {
+			return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package session
+
+import (
+	"auth-service/internal/config"
+	authconsts "auth-service/internal/shared/consts"
+	"auth-service/internal/testutil"
+	token "auth-service/pkg/jwt"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xinyi-chong/common-lib/consts"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("JWT_SECRET_KEY", "test-secret-key-of-at-least-32-bytes")
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+// fakeRepository keeps sessions in memory, scoped like the SQL queries.
+type fakeRepository struct {
+	sessions map[uuid.UUID]*Session
+}
+
+func (r *fakeRepository) isActive(session *Session) bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(time.Now())
+}
+
+func (r *fakeRepository) Create(_ context.Context, session *Session) error {
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *fakeRepository) FindActive(_ context.Context, id uuid.UUID) (*Session, error) {
+	session, ok := r.sessions[id]
+	if !ok || !r.isActive(session) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return session, nil
+}
+
+func (r *fakeRepository) ListActiveByUser(_ context.Context, userID uuid.UUID) ([]Session, error) {
+	var sessions []Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && r.isActive(session) {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeRepository) Touch(context.Context, uuid.UUID, map[string]interface{}) error {
+	return nil
+}
+
+func (r *fakeRepository) Revoke(_ context.Context, userID, id uuid.UUID) error {
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || !r.isActive(session) {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return nil
+}
+
+func (r *fakeRepository) RevokeAllByUser(_ context.Context, userID uuid.UUID, exceptIDs ...uuid.UUID) ([]uuid.UUID, error) {
+	now := time.Now()
+	var ids []uuid.UUID
+	for _, session := range r.sessions {
+		if session.UserID == userID && r.isActive(session) && !slices.Contains(exceptIDs, session.ID) {
+			session.RevokedAt = &now
+			ids = append(ids, session.ID)
+		}
+	}
+	return ids, nil
+}
+
+type fixture struct {
+	repo   *fakeRepository
+	router *gin.Engine
+	// Two sessions for each user; the first of ada's makes the requests
+	ada, grace    uuid.UUID
+	adaSessions   []uuid.UUID
+	graceSessions []uuid.UUID
+}
+
+// newFixture logs two users in twice each and serves the session routes as
+// the first of ada's sessions.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	if err := token.Init(&config.Config{}); err != nil {
+		t.Fatalf("token.Init: %v", err)
+	}
+
+	f := &fixture{
+		repo:  &fakeRepository{sessions: map[uuid.UUID]*Session{}},
+		ada:   uuid.New(),
+		grace: uuid.New(),
+	}
+	svc := NewService(f.repo, zap.NewNop())
+	login := func(userID uuid.UUID) uuid.UUID {
+		_, familyID, err := token.NewRefreshTokenFamily(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("NewRefreshTokenFamily: %v", err)
+		}
+		sessionID := uuid.MustParse(familyID)
+		if err := svc.CreateSession(context.Background(), userID, sessionID, nil, []string{"pwd"}); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return sessionID
+	}
+	f.adaSessions = []uuid.UUID{login(f.ada), login(f.ada)}
+	f.graceSessions = []uuid.UUID{login(f.grace), login(f.grace)}
+
+	ctrl := NewController(svc, zap.NewNop())
+	f.router = gin.New()
+	f.router.Use(func(c *gin.Context) {
+		c.Set(consts.CtxUserID, f.ada)
+		c.Set(authconsts.CtxSessionID, f.adaSessions[0].String())
+		c.Next()
+	})
+	f.router.GET("/sessions", ctrl.ListSessions)
+	f.router.DELETE("/sessions", ctrl.RevokeOtherSessions)
+	f.router.DELETE("/sessions/:id", ctrl.RevokeSession)
+	return f
+}
+
+func (f *fixture) serve(method, path string) int {
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+// active reports whether the session is neither revoked in the database nor
+// its refresh token family in Redis.
+func (f *fixture) active(t *testing.T, sessionID uuid.UUID) bool {
+	t.Helper()
+	revoked, err := token.IsRefreshTokenFamilyRevoked(context.Background(), sessionID.String())
+	if err != nil {
+		t.Fatalf("IsRefreshTokenFamilyRevoked: %v", err)
+	}
+	dbActive := f.repo.isActive(f.repo.sessions[sessionID])
+	if dbActive == revoked {
+		t.Errorf("session %s: active in the database = %v, family revoked = %v", sessionID, dbActive, revoked)
+	}
+	return dbActive
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     func(f *fixture) string
+		wantStatus int
+		revoked    func(f *fixture) []uuid.UUID
+	}{
+		{"own other session", func(f *fixture) string { return f.adaSessions[1].String() }, http.StatusOK,
+			func(f *fixture) []uuid.UUID { return f.adaSessions[1:] }},
+		{"own current session", func(f *fixture) string { return f.adaSessions[0].String() }, http.StatusOK,
+			func(f *fixture) []uuid.UUID { return f.adaSessions[:1] }},
+		{"another user's session", func(f *fixture) string { return f.graceSessions[0].String() }, http.StatusNotFound,
+			func(*fixture) []uuid.UUID { return nil }},
+		{"unknown session", func(*fixture) string { return uuid.NewString() }, http.StatusNotFound,
+			func(*fixture) []uuid.UUID { return nil }},
+		{"invalid ID", func(*fixture) string { return "not-a-uuid" }, http.StatusBadRequest,
+			func(*fixture) []uuid.UUID { return nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if code := f.serve(http.MethodDelete, "/sessions/"+tt.target(f)); code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", code, tt.wantStatus)
+			}
+
+			revoked := tt.revoked(f)
+			for _, sessionID := range append(f.adaSessions, f.graceSessions...) {
+				if want := !slices.Contains(revoked, sessionID); f.active(t, sessionID) != want {
+					t.Errorf("session %s active = %v, want %v", sessionID, !want, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	f := newFixture(t)
+	if code := f.serve(http.MethodDelete, "/sessions"); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	if !f.active(t, f.adaSessions[0]) {
+		t.Error("current session revoked")
+	}
+	if f.active(t, f.adaSessions[1]) {
+		t.Error("other session still active")
+	}
+	for _, sessionID := range f.graceSessions {
+		if !f.active(t, sessionID) {
+			t.Error("another user's session revoked")
+		}
+	}
+}
+
+func TestListSessions_MarksCurrent(t *testing.T) {
+	f := newFixture(t)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sessions", nil))
+
+	var body struct {
+		Data []Response `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data) != len(f.adaSessions) {
+		t.Fatalf("listed %d sessions, want %d", len(body.Data), len(f.adaSessions))
+	}
+	for _, session := range body.Data {
+		if want := session.ID == f.adaSessions[0]; session.Current != want {
+			t.Errorf("session %s current = %v, want %v", session.ID, session.Current, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package clientinfo
+
+import "context"
+
+type ctxKey struct{}
+
+// Info describes the client that sent the current request.
+type Info struct {
+	IPAddress string
+	UserAgent string
+	// Languages are the ?lang= value and Accept-Language header, in the
+	// order middleware.LocaleMiddleware uses them
+	Languages []string
+}
+
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
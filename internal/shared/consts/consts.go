@@ -1,5 +1,50 @@
 package consts
 
+import "github.com/xinyi-chong/common-lib/consts"
+
 const (
 	CookieRefreshToken = "refresh_token"
+	CookieSocialState  = "social_state" // ties a social login to the browser that started it
+)
+
+// Context keys
+const (
+	CtxSessionID   = "session_id"
+	CtxPermissions = "permissions" // set only when the access token embeds them
+)
+
+// Permissions checked by the admin routes
+const (
+	PermKeysRead          = "keys:read"
+	PermKeysRotate        = "keys:rotate"
+	PermUsersRead         = "users:read"
+	PermUsersWrite        = "users:write"
+	PermUsersDelete       = "users:delete"
+	PermUsersLock         = "users:lock"
+	PermRolesRead         = "roles:read"
+	PermRolesWrite        = "roles:write"
+	PermRolesAssign       = "roles:assign"
+	PermOAuthClientsRead  = "oauth_clients:read"
+	PermOAuthClientsWrite = "oauth_clients:write"
+)
+
+// Fields
+const (
+	SigningKeyField   consts.Field = "signing_key"
+	SessionField      consts.Field = "session"
+	CodeField         consts.Field = "code"
+	TokenField        consts.Field = "token"
+	MFAField          consts.Field = "mfa"
+	RecoveryCodeField consts.Field = "recovery_code"
+	PasskeyField      consts.Field = "passkey"
+	ChallengeField    consts.Field = "challenge"
+	ProviderField     consts.Field = "provider"
+	StateField        consts.Field = "state"
+	AuthMethodField   consts.Field = "auth_method"
+	ClientField       consts.Field = "client"
+	RedirectURIField  consts.Field = "redirect_uri"
+	ScopeField        consts.Field = "scope"
+	JWKSField         consts.Field = "jwks"
+	RoleField         consts.Field = "role"
+	PermissionField   consts.Field = "permission"
 )
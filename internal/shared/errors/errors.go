@@ -0,0 +1,18 @@
+package autherrors
+
+import (
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"net/http"
+)
+
+// General errors
+var (
+	ErrForbidden             = apperrors.New("forbidden", http.StatusForbidden)
+	ErrAccountLocked         = apperrors.New("account_locked", http.StatusLocked)
+	ErrEmailNotVerified      = apperrors.New("email_not_verified", http.StatusForbidden)
+	ErrLastAuthMethod        = apperrors.New("last_auth_method", http.StatusConflict)
+	ErrReauthRequired        = apperrors.New("reauthentication_required", http.StatusForbidden)
+	ErrSystemRole            = apperrors.New("system_role", http.StatusConflict)
+	ErrAccountDisabled       = apperrors.New("account_disabled", http.StatusForbidden)
+	ErrPasswordResetRequired = apperrors.New("password_reset_required", http.StatusForbidden)
+)
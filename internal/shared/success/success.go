@@ -0,0 +1,13 @@
+package authsuccess
+
+import (
+	"github.com/xinyi-chong/common-lib/success"
+	"net/http"
+)
+
+// General success
+var (
+	MFARequired  = success.New("mfa_required", http.StatusOK)
+	LinkRequired = success.New("link_required", http.StatusOK)
+	Authorized   = success.New("authorized", http.StatusOK)
+)
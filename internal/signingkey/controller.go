@@ -0,0 +1,62 @@
+package signingkey
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	token "auth-service/pkg/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"go.uber.org/zap"
+)
+
+type Controller struct {
+	logger *zap.Logger
+}
+
+func NewController(logger *zap.Logger) *Controller {
+	return &Controller{logger: logger}
+}
+
+// ListKeys godoc
+// @Summary List signing keys
+// @Description List the JWT signing keys currently accepted for verification
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 200 {object} response.Response{data=[]token.KeyInfo} "Success"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Router /admin/keys [get]
+func (ctrl *Controller) ListKeys(c *gin.Context) {
+	response.Success(c, success.XFound.WithField(authconsts.SigningKeyField), token.Keys())
+}
+
+// RotateKey godoc
+// @Summary Rotate signing key
+// @Description Generate a new signing key. It is published at once and signs after two key sync intervals; retired keys keep verifying tokens until they expire.
+// @Tags Admin
+// @Produce json
+// @Security BearerTokenAuth
+// @Success 201 {object} response.Response{data=token.KeyInfo} "Created"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 409 {object} response.Response "Rotation in progress"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /admin/keys/rotate [post]
+func (ctrl *Controller) RotateKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	key, err := token.RotateKey(ctx)
+	if err != nil {
+		ctrl.logger.Error("RotateKey error", zap.Error(err))
+		if errors.Is(err, token.ErrRotationInProgress) {
+			response.Error(c, apperrors.ErrXConflict.WithField(authconsts.SigningKeyField))
+			return
+		}
+		response.Error(c, apperrors.ErrInternalServerError.Wrap(err))
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.SigningKeyField), key)
+}
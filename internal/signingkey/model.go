@@ -0,0 +1,17 @@
+package signingkey
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type SigningKey struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	Kid                 string     `json:"kid" db:"kid"`
+	Algorithm           string     `json:"algorithm" db:"algorithm"`
+	PrivateKeyEncrypted string     `json:"-" db:"private_key_encrypted"` // never expose in JSON
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	ActivatesAt         time.Time  `json:"activates_at" db:"activates_at"`
+	RetiredAt           *time.Time `json:"retired_at,omitempty" db:"retired_at"`
+	ExpiresAt           *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
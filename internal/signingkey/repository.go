@@ -0,0 +1,94 @@
+package signingkey
+
+import (
+	token "auth-service/pkg/jwt"
+	"auth-service/pkg/secretbox"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Repository persists the JWT key ring. Private key material is encrypted at rest.
+type Repository interface {
+	token.KeyStore
+}
+
+type repository struct {
+	db  *gorm.DB
+	box *secretbox.Box
+}
+
+func NewRepository(db *gorm.DB, box *secretbox.Box) Repository {
+	return &repository{db: db, box: box}
+}
+
+func (r *repository) ListKeys(ctx context.Context) ([]token.KeyRecord, error) {
+	var keys []SigningKey
+	err := r.db.WithContext(ctx).
+		Order("created_at").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]token.KeyRecord, 0, len(keys))
+	for _, key := range keys {
+		secret, err := r.box.Open(key.PrivateKeyEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt signing key %s: %w", key.Kid, err)
+		}
+
+		records = append(records, token.KeyRecord{
+			KeyID:       key.Kid,
+			Algorithm:   key.Algorithm,
+			Secret:      secret,
+			CreatedAt:   key.CreatedAt,
+			ActivatesAt: key.ActivatesAt,
+			RetiredAt:   key.RetiredAt,
+			ExpiresAt:   key.ExpiresAt,
+		})
+	}
+	return records, nil
+}
+
+func (r *repository) CreateKey(ctx context.Context, record *token.KeyRecord) error {
+	encrypted, err := r.box.Seal(record.Secret)
+	if err != nil {
+		return err
+	}
+
+	key := &SigningKey{
+		ID:                  uuid.New(),
+		Kid:                 record.KeyID,
+		Algorithm:           record.Algorithm,
+		PrivateKeyEncrypted: encrypted,
+		CreatedAt:           record.CreatedAt,
+		ActivatesAt:         record.ActivatesAt,
+	}
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *repository) RetireKey(ctx context.Context, keyID string, retiredAt, expiresAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&SigningKey{}).
+		Where("kid = ? AND retired_at IS NULL", keyID).
+		Updates(map[string]interface{}{
+			"retired_at": retiredAt,
+			"expires_at": expiresAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) DeleteExpiredKeys(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ?", before).
+		Delete(&SigningKey{}).Error
+}
@@ -0,0 +1,25 @@
+package social
+
+type (
+	AuthorizeParam struct {
+		DeviceName *string `form:"device_name" json:"device_name" validate:"omitempty,max=255"`
+	}
+
+	// Authorization is where to send the user, and the binding to keep in
+	// their browser until the provider redirects back.
+	Authorization struct {
+		URL     string
+		Binding string
+	}
+
+	// CallbackParam is the provider's redirect back to us, as a query string
+	// or, for Apple, a posted form.
+	CallbackParam struct {
+		State            string `form:"state" json:"state" validate:"required"`
+		Code             string `form:"code" json:"code"`
+		Error            string `form:"error" json:"error"`
+		ErrorDescription string `form:"error_description" json:"error_description"`
+		// Binding is read from the browser, not from the provider's redirect
+		Binding string `form:"-" json:"-"`
+	}
+)
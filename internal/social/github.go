@@ -0,0 +1,91 @@
+package social
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/config"
+	"context"
+	"errors"
+	"golang.org/x/oauth2"
+	"strconv"
+)
+
+const githubUserURL = "https://api.github.com/user"
+
+var githubEndpoint = oauth2.Endpoint{
+	AuthURL:  "https://github.com/login/oauth/authorize",
+	TokenURL: "https://github.com/login/oauth/access_token",
+}
+
+type githubProvider struct {
+	oauth   *oauth2.Config
+	userURL string
+}
+
+func newGitHubProvider(cfg config.OAuthProvider) *githubProvider {
+	userURL := cfg.UserInfoURL
+	if userURL == "" {
+		userURL = githubUserURL
+	}
+
+	return &githubProvider{
+		oauth:   oauthConfig(cfg, githubEndpoint, []string{"read:user", "user:email"}),
+		userURL: userURL,
+	}
+}
+
+func (p *githubProvider) MethodType() authmethod.Type {
+	return authmethod.TypeGitHub
+}
+
+func (p *githubProvider) AuthCodeURL(state, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *githubProvider) Profile(ctx context.Context, code, verifier string) (*Profile, error) {
+	tok, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	client := p.oauth.Client(ctx, tok)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	err = fetchJSON(ctx, client, p.userURL, &user)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github user without id")
+	}
+
+	// The public profile email may be unset or unverified; the primary
+	// address from /user/emails is always present
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	err = fetchJSON(ctx, client, p.userURL+"/emails", &emails)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		ProviderID: strconv.FormatInt(user.ID, 10),
+		Name:       user.Name,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			profile.Email = e.Email
+			profile.EmailVerified = e.Verified
+			break
+		}
+	}
+	return profile, nil
+}
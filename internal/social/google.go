@@ -0,0 +1,69 @@
+package social
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/config"
+	"context"
+	"errors"
+	"golang.org/x/oauth2"
+)
+
+const googleUserInfoURL = "https://openidconnect.googleapis.com/v1/userinfo"
+
+var googleEndpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+}
+
+type googleProvider struct {
+	oauth       *oauth2.Config
+	userInfoURL string
+}
+
+func newGoogleProvider(cfg config.OAuthProvider) *googleProvider {
+	userInfoURL := cfg.UserInfoURL
+	if userInfoURL == "" {
+		userInfoURL = googleUserInfoURL
+	}
+
+	return &googleProvider{
+		oauth:       oauthConfig(cfg, googleEndpoint, []string{"openid", "email", "profile"}),
+		userInfoURL: userInfoURL,
+	}
+}
+
+func (p *googleProvider) MethodType() authmethod.Type {
+	return authmethod.TypeGoogle
+}
+
+func (p *googleProvider) AuthCodeURL(state, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *googleProvider) Profile(ctx context.Context, code, verifier string) (*Profile, error) {
+	tok, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	err = fetchJSON(ctx, p.oauth.Client(ctx, tok), p.userInfoURL, &info)
+	if err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, errors.New("google userinfo without sub")
+	}
+
+	return &Profile{
+		ProviderID:    info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+	}, nil
+}
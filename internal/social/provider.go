@@ -0,0 +1,78 @@
+package social
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"io"
+	"net/http"
+)
+
+// Provider names used in routes
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+	ProviderApple  = "apple"
+)
+
+// Profile is the user as the provider knows them.
+type Profile struct {
+	ProviderID    string `json:"provider_id"` // stable user ID at the provider
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Provider is an OAuth 2.0 identity provider.
+type Provider interface {
+	MethodType() authmethod.Type
+	// AuthCodeURL is where the user signs in. The provider redirects back to
+	// our callback with the state and an authorization code.
+	AuthCodeURL(state, verifier string) string
+	// Profile exchanges the authorization code and returns who signed in.
+	Profile(ctx context.Context, code, verifier string) (*Profile, error)
+}
+
+func oauthConfig(cfg config.OAuthProvider, endpoint oauth2.Endpoint, scopes []string) *oauth2.Config {
+	if cfg.AuthURL != "" {
+		endpoint.AuthURL = cfg.AuthURL
+	}
+	if cfg.TokenURL != "" {
+		endpoint.TokenURL = cfg.TokenURL
+	}
+	if len(cfg.Scopes) > 0 {
+		scopes = cfg.Scopes
+	}
+
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// fetchJSON decodes the JSON response of an authorized GET request.
+func fetchJSON(ctx context.Context, client *http.Client, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,187 @@
+package social
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/config"
+	authconsts "auth-service/internal/shared/consts"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"time"
+)
+
+const defaultStateTTL = 10 * time.Minute
+
+// Identity is the outcome of a sign-in at a provider.
+type Identity struct {
+	Profile
+	MethodType authmethod.Type
+	DeviceName *string
+	// LinkUserID is the user who started linking the provider, if any
+	LinkUserID *uuid.UUID
+}
+
+type Service interface {
+	// AuthorizationURL starts a login and returns the provider URL to send
+	// the user to, with the binding the callback must present.
+	AuthorizationURL(ctx context.Context, provider string, param AuthorizeParam) (*Authorization, error)
+	// LinkAuthorizationURL is AuthorizationURL for a signed-in user adding
+	// the provider to their account.
+	LinkAuthorizationURL(ctx context.Context, provider string, userID uuid.UUID) (*Authorization, error)
+	// Callback completes the sign-in from the provider's redirect, in the
+	// browser that started it.
+	Callback(ctx context.Context, provider string, param CallbackParam) (*Identity, error)
+
+	// SavePendingLink keeps an identity that may only be linked by the owner
+	// of its email, and returns the token to link it with.
+	SavePendingLink(ctx context.Context, identity *Identity) (string, error)
+	TakePendingLink(ctx context.Context, linkToken string) (*Identity, error)
+}
+
+type service struct {
+	providers map[string]Provider
+	states    *singleUseStore
+	links     *singleUseStore
+	logger    *zap.Logger
+}
+
+func NewService(cfg *config.Config, logger *zap.Logger) (Service, error) {
+	stateTTL := cfg.Social.StateTTL
+	if stateTTL <= 0 {
+		stateTTL = defaultStateTTL
+	}
+
+	providers := make(map[string]Provider)
+	if cfg.Social.Google.ClientID != "" {
+		providers[ProviderGoogle] = newGoogleProvider(cfg.Social.Google)
+	}
+	if cfg.Social.GitHub.ClientID != "" {
+		providers[ProviderGitHub] = newGitHubProvider(cfg.Social.GitHub)
+	}
+	if cfg.Social.Apple.ClientID != "" {
+		apple, err := newAppleProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		providers[ProviderApple] = apple
+	}
+
+	return &service{
+		providers: providers,
+		states:    &singleUseStore{prefix: redisStatePrefix, ttl: stateTTL},
+		links:     &singleUseStore{prefix: redisPendingLinkPrefix, ttl: stateTTL},
+		logger:    logger,
+	}, nil
+}
+
+func (s *service) provider(op, name string) (Provider, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, apperrors.ErrXNotFound.WithField(authconsts.ProviderField).WithOp(op)
+	}
+	return p, nil
+}
+
+func (s *service) AuthorizationURL(ctx context.Context, provider string, param AuthorizeParam) (*Authorization, error) {
+	return s.authorizationURL(ctx, "service.AuthorizationURL", &loginState{
+		Provider:   provider,
+		DeviceName: param.DeviceName,
+	})
+}
+
+func (s *service) LinkAuthorizationURL(ctx context.Context, provider string, userID uuid.UUID) (*Authorization, error) {
+	return s.authorizationURL(ctx, "service.LinkAuthorizationURL", &loginState{
+		Provider:   provider,
+		LinkUserID: &userID,
+	})
+}
+
+func (s *service) authorizationURL(ctx context.Context, op string, ls *loginState) (*Authorization, error) {
+	p, err := s.provider(op, ls.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	ls.Verifier = oauth2.GenerateVerifier()
+	state, err := s.states.save(ctx, ls)
+	if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return &Authorization{
+		URL:     p.AuthCodeURL(state, ls.Verifier),
+		Binding: stateBinding(state),
+	}, nil
+}
+
+func (s *service) Callback(ctx context.Context, provider string, param CallbackParam) (*Identity, error) {
+	const op = "service.Callback"
+
+	p, err := s.provider(op, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isStateBound(param.State, param.Binding) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.StateField).WithOp(op)
+	}
+
+	var ls loginState
+	err = s.states.take(ctx, param.State, &ls)
+	if errors.Is(err, errStateNotFound) || (err == nil && ls.Provider != provider) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.StateField).WithOp(op).Wrap(err)
+	} else if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	// The user cancelled or the provider refused
+	if param.Error != "" || param.Code == "" {
+		s.logger.Debug("Social login denied by provider",
+			zap.String("provider", provider),
+			zap.String("error", param.Error),
+			zap.String("error_description", param.ErrorDescription))
+		return nil, apperrors.ErrUnauthorized.WithOp(op)
+	}
+
+	profile, err := p.Profile(ctx, param.Code, ls.Verifier)
+	if err != nil {
+		return nil, apperrors.ErrUnauthorized.WithOp(op).Wrap(err)
+	}
+
+	return &Identity{
+		Profile:    *profile,
+		MethodType: p.MethodType(),
+		DeviceName: ls.DeviceName,
+		LinkUserID: ls.LinkUserID,
+	}, nil
+}
+
+func (s *service) SavePendingLink(ctx context.Context, identity *Identity) (string, error) {
+	const op = "service.SavePendingLink"
+
+	linkToken, err := s.links.save(ctx, &pendingLink{
+		Profile:    identity.Profile,
+		MethodType: identity.MethodType,
+	})
+	if err != nil {
+		return "", apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	return linkToken, nil
+}
+
+func (s *service) TakePendingLink(ctx context.Context, linkToken string) (*Identity, error) {
+	const op = "service.TakePendingLink"
+
+	var link pendingLink
+	err := s.links.take(ctx, linkToken, &link)
+	if errors.Is(err, errStateNotFound) {
+		return nil, apperrors.ErrInvalidX.WithField(authconsts.TokenField).WithOp(op).Wrap(err)
+	} else if err != nil {
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return &Identity{Profile: link.Profile, MethodType: link.MethodType}, nil
+}
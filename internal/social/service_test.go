@@ -0,0 +1,165 @@
+package social
+
+import (
+	"auth-service/internal/authmethod"
+	"auth-service/internal/testutil"
+	"context"
+	"fmt"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+const providerMock = "mock"
+
+// mockProvider signs in whoever it is told to, and records the PKCE
+// verifier it was given for each code.
+type mockProvider struct {
+	profile   Profile
+	verifiers map[string]string // state -> verifier from AuthCodeURL
+	exchanged string            // verifier given to Profile
+}
+
+func (p *mockProvider) MethodType() authmethod.Type {
+	return authmethod.TypeGoogle
+}
+
+func (p *mockProvider) AuthCodeURL(state, verifier string) string {
+	p.verifiers[state] = verifier
+	return "https://provider.test/authorize?state=" + url.QueryEscape(state)
+}
+
+func (p *mockProvider) Profile(_ context.Context, code, verifier string) (*Profile, error) {
+	if code != "good-code" {
+		return nil, fmt.Errorf("unknown code %q", code)
+	}
+	p.exchanged = verifier
+	profile := p.profile
+	return &profile, nil
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+func newTestService(t *testing.T) (*service, *mockProvider) {
+	t.Helper()
+	p := &mockProvider{
+		profile:   Profile{ProviderID: "12345", Email: "user@example.com", EmailVerified: true},
+		verifiers: map[string]string{},
+	}
+	return &service{
+		providers: map[string]Provider{providerMock: p},
+		states:    &singleUseStore{prefix: redisStatePrefix, ttl: time.Minute},
+		links:     &singleUseStore{prefix: redisPendingLinkPrefix, ttl: time.Minute},
+		logger:    zap.NewNop(),
+	}, p
+}
+
+// startLogin returns the state the provider would echo back, and the
+// binding kept in the browser.
+func startLogin(t *testing.T, s *service) (string, string) {
+	t.Helper()
+	authz, err := s.AuthorizationURL(context.Background(), providerMock, AuthorizeParam{})
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	u, err := url.Parse(authz.URL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	return u.Query().Get("state"), authz.Binding
+}
+
+func TestCallback_SameBrowser(t *testing.T) {
+	s, p := newTestService(t)
+	ctx := context.Background()
+	state, binding := startLogin(t, s)
+
+	identity, err := s.Callback(ctx, providerMock, CallbackParam{State: state, Code: "good-code", Binding: binding})
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if identity.ProviderID != "12345" || identity.LinkUserID != nil {
+		t.Errorf("identity = %+v", identity)
+	}
+	if p.exchanged == "" || p.exchanged != p.verifiers[state] {
+		t.Errorf("code exchanged with verifier %q, want %q", p.exchanged, p.verifiers[state])
+	}
+
+	// The state is single use
+	_, err = s.Callback(ctx, providerMock, CallbackParam{State: state, Code: "good-code", Binding: binding})
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("replayed callback: err = %v, want invalid state", err)
+	}
+}
+
+func TestCallback_RejectsUnboundState(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	// The attacker starts a login and sends the victim the callback link;
+	// the victim's browser has no binding, or one for its own login
+	attackerState, _ := startLogin(t, s)
+	_, victimBinding := startLogin(t, s)
+
+	for name, binding := range map[string]string{
+		"no cookie":          "",
+		"other login cookie": victimBinding,
+		"state as cookie":    attackerState,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Callback(ctx, providerMock, CallbackParam{State: attackerState, Code: "good-code", Binding: binding})
+			if !apperrors.Is(err, apperrors.ErrInvalidX) {
+				t.Errorf("err = %v, want invalid state", err)
+			}
+		})
+	}
+}
+
+func TestCallback_OtherProvider(t *testing.T) {
+	s, p := newTestService(t)
+	s.providers["other"] = p
+	state, binding := startLogin(t, s)
+
+	_, err := s.Callback(context.Background(), "other", CallbackParam{State: state, Code: "good-code", Binding: binding})
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("err = %v, want invalid state", err)
+	}
+}
+
+func TestCallback_ProviderDenied(t *testing.T) {
+	s, p := newTestService(t)
+	state, binding := startLogin(t, s)
+
+	_, err := s.Callback(context.Background(), providerMock, CallbackParam{State: state, Error: "access_denied", Binding: binding})
+	if !apperrors.Is(err, apperrors.ErrUnauthorized) {
+		t.Errorf("err = %v, want unauthorized", err)
+	}
+	if p.exchanged != "" {
+		t.Error("code exchanged after the provider denied the login")
+	}
+}
+
+func TestLinkAuthorizationURL_CarriesUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	userID := [16]byte{1}
+
+	authz, err := s.LinkAuthorizationURL(ctx, providerMock, userID)
+	if err != nil {
+		t.Fatalf("LinkAuthorizationURL: %v", err)
+	}
+	u, _ := url.Parse(authz.URL)
+
+	identity, err := s.Callback(ctx, providerMock, CallbackParam{State: u.Query().Get("state"), Code: "good-code", Binding: authz.Binding})
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if identity.LinkUserID == nil || *identity.LinkUserID != userID {
+		t.Errorf("LinkUserID = %v, want %v", identity.LinkUserID, userID)
+	}
+}
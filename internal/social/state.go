@@ -0,0 +1,99 @@
+package social
+
+import (
+	"auth-service/internal/authmethod"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"time"
+)
+
+const (
+	redisStatePrefix       = "auth:social_state:"
+	redisPendingLinkPrefix = "auth:social_link:"
+	keyBytes               = 32
+)
+
+var errStateNotFound = errors.New("social login state not found or expired")
+
+// loginState is kept while the user is at the provider. The state parameter
+// is its key; the PKCE verifier never leaves the server.
+type loginState struct {
+	Provider   string     `json:"provider"`
+	Verifier   string     `json:"verifier"`
+	DeviceName *string    `json:"device_name,omitempty"`
+	LinkUserID *uuid.UUID `json:"link_user_id,omitempty"` // set when linking instead of logging in
+}
+
+// stateBinding is kept in the browser that started the login. A callback
+// with a state from another browser, such as an attacker's own login
+// forwarded to the victim, has no matching binding.
+func stateBinding(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func isStateBound(state, binding string) bool {
+	return binding != "" && subtle.ConstantTimeCompare([]byte(stateBinding(state)), []byte(binding)) == 1
+}
+
+// pendingLink is a provider identity whose email belongs to an existing
+// account, kept until the owner logs in and links it.
+type pendingLink struct {
+	Profile
+	MethodType authmethod.Type `json:"method_type"`
+}
+
+// singleUseStore keeps values in Redis under random keys that can be taken
+// once.
+type singleUseStore struct {
+	prefix string
+	ttl    time.Duration
+}
+
+func (s *singleUseStore) save(ctx context.Context, value interface{}) (string, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := client.Set(ctx, s.prefix+key, data, s.ttl).Err(); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// take decodes the value into out and deletes it.
+func (s *singleUseStore) take(ctx context.Context, key string, out interface{}) error {
+	client, err := redisclient.Client()
+	if err != nil {
+		return err
+	}
+
+	data, err := client.GetDel(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return errStateNotFound
+	} else if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,47 @@
+// Package testutil holds helpers shared by the tests.
+package testutil
+
+import (
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/xinyi-chong/common-lib/logger"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// redisServer is the server RunWithRedis started
+var redisServer *miniredis.Miniredis
+
+// RunWithRedis runs the package's tests against an in-memory Redis, which
+// the shared client can only be pointed at once. Call it from TestMain.
+func RunWithRedis(m *testing.M) int {
+	if err := logger.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, "init logger:", err)
+		return 1
+	}
+
+	server, err := miniredis.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "start redis:", err)
+		return 1
+	}
+	defer server.Close()
+	redisServer = server
+
+	port, _ := strconv.Atoi(server.Port())
+	if _, err := redisclient.Init(redisclient.Config{Host: server.Host(), Port: port}); err != nil {
+		fmt.Fprintln(os.Stderr, "connect redis:", err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// FastForward expires Redis keys as if d had passed. The in-memory server
+// does not expire keys on its own.
+func FastForward(d time.Duration) {
+	redisServer.FastForward(d)
+}
@@ -1,5 +1,7 @@
 package user
 
+import "auth-service/internal/authmethod"
+
 type (
 	CreateUserParam struct {
 		Username *string `json:"username"`
@@ -7,8 +9,21 @@ type (
 		Password string  `json:"password"`
 	}
 
+	// CreateExternalUserParam creates a user who signs in with an identity
+	// provider and has no password.
+	CreateExternalUserParam struct {
+		Email         string
+		EmailVerified bool
+		MethodType    authmethod.Type
+		ProviderID    string
+	}
+
+	// UpdateUserParam changes the fields that are set. An empty Username
+	// removes it.
 	UpdateUserParam struct {
-		Email    *string `json:"email"`
-		Password *string `json:"password"`
+		Username      *string `json:"username"`
+		Email         *string `json:"email"`
+		EmailVerified *bool   `json:"email_verified"`
+		Password      *string `json:"password"`
 	}
 )
@@ -2,8 +2,13 @@ package user
 
 import (
 	"golang.org/x/crypto/bcrypt"
+	"regexp"
 )
 
+// usernamePattern allows 3 to 50 letters, digits, dots, dashes and
+// underscores, starting with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{2,49}$`)
+
 func hashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -1,17 +1,25 @@
 package user
 
 import (
+	"auth-service/internal/authmethod"
 	"context"
 	"github.com/google/uuid"
 	"github.com/xinyi-chong/common-lib/filters"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type Repository interface {
 	Create(ctx context.Context, user *User) error
+	CreateWithAuthMethod(ctx context.Context, user *User, method *authmethod.AuthMethod) error
 	FindByID(ctx context.Context, id uuid.UUID) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	Update(ctx context.Context, id uuid.UUID, user *User) error
+	UpdateColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error
+	// SetPassword stores the password hash and adds the password auth method
+	// if the user had none.
+	SetPassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	List(ctx context.Context, filter *Filter) ([]User, error)
 	Count(ctx context.Context, filter *Filter) (int64, error)
@@ -26,6 +34,7 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
+// filteredQuery selects the users matching the filter, without paginating.
 func (r *repository) filteredQuery(ctx context.Context, filter *Filter) *gorm.DB {
 	query := r.db.WithContext(ctx).Model(&User{})
 
@@ -41,8 +50,6 @@ func (r *repository) filteredQuery(ctx context.Context, filter *Filter) *gorm.DB
 		if filter.IsActive != nil {
 			query = query.Where("is_active = ?", *filter.IsActive)
 		}
-
-		query = filters.PaginateQuery(query, &filter.Pagination, []string{"username", "email", "is_active"})
 	}
 
 	return query
@@ -52,6 +59,18 @@ func (r *repository) Create(ctx context.Context, user *User) error {
 	return r.db.WithContext(ctx).Create(user).Error
 }
 
+// CreateWithAuthMethod creates the user and their first auth method together.
+func (r *repository) CreateWithAuthMethod(ctx context.Context, user *User, method *authmethod.AuthMethod) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		method.UserID = user.ID
+		return tx.Create(method).Error
+	})
+}
+
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	var user User
 	result := r.db.WithContext(ctx).First(&user, id)
@@ -79,6 +98,49 @@ func (r *repository) Update(ctx context.Context, id uuid.UUID, user *User) error
 	return nil
 }
 
+// UpdateColumns updates the given columns, including zero values such as NULL.
+func (r *repository) UpdateColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	result := r.db.WithContext(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) SetPassword(ctx context.Context, id uuid.UUID, passwordHash string, changedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"password_hash":           passwordHash,
+				"password_changed_at":     changedAt,
+				"password_reset_required": false,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "user_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "method_type", Value: authmethod.TypePassword}}},
+			DoNothing:   true,
+		}).Create(&authmethod.AuthMethod{
+			ID:         uuid.New(),
+			UserID:     id,
+			MethodType: authmethod.TypePassword,
+		}).Error
+	})
+}
+
 func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
 	result := r.db.WithContext(ctx).Delete(&User{}, id)
 	if result.Error != nil {
@@ -92,7 +154,12 @@ func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
 
 func (r *repository) List(ctx context.Context, filter *Filter) ([]User, error) {
 	var users []User
-	result := r.filteredQuery(ctx, filter).Find(&users)
+	query := r.filteredQuery(ctx, filter)
+	if filter != nil {
+		query = filters.PaginateQuery(query, &filter.Pagination, SortFields)
+	}
+	// Pages stay stable when the sort field has ties
+	result := query.Order("created_at").Order("id").Find(&users)
 	return users, result.Error
 }
 
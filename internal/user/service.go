@@ -1,22 +1,36 @@
 package user
 
 import (
+	"auth-service/internal/authmethod"
 	dberrors "auth-service/pkg/error"
 	"context"
 	"github.com/google/uuid"
 	"github.com/xinyi-chong/common-lib/consts"
 	apperrors "github.com/xinyi-chong/common-lib/errors"
 	"go.uber.org/zap"
+	"time"
 )
 
 type Service interface {
 	IsUsernameOrEmailRegistered(ctx context.Context, username *string, email string) (bool, error)
 	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
-	CreateUser(ctx context.Context, param *CreateUserParam) error
+	CreateUser(ctx context.Context, param *CreateUserParam) (*User, error)
+	CreateExternalUser(ctx context.Context, param *CreateExternalUserParam) (*User, error)
+	// UpdateUser changes the fields that are set. A changed email must be
+	// verified again unless EmailVerified says otherwise.
 	UpdateUser(ctx context.Context, id uuid.UUID, param *UpdateUserParam) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	SetActive(ctx context.Context, id uuid.UUID, active bool) error
+	// RequirePasswordReset stops the user from logging in with their password
+	// until they set a new one.
+	RequirePasswordReset(ctx context.Context, id uuid.UUID) error
+	SetAccountLockedUntil(ctx context.Context, id uuid.UUID, until *time.Time) error
+	RecordLogin(ctx context.Context, id uuid.UUID) error
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 	ListUsers(ctx context.Context, filter *Filter) ([]User, error)
+	// CountUsers counts the users matching the filter, ignoring pagination.
+	CountUsers(ctx context.Context, filter *Filter) (int64, error)
 }
 
 type service struct {
@@ -55,12 +69,12 @@ func (s *service) GetUserByEmail(ctx context.Context, email string) (*User, erro
 	return user, nil
 }
 
-func (s *service) CreateUser(ctx context.Context, param *CreateUserParam) error {
+func (s *service) CreateUser(ctx context.Context, param *CreateUserParam) (*User, error) {
 	const op = "service.CreateUser"
 
 	hashedPassword, err := hashPassword(param.Password)
 	if err != nil {
-		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+		return nil, apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 	}
 
 	user := &User{
@@ -74,35 +88,137 @@ func (s *service) CreateUser(ctx context.Context, param *CreateUserParam) error
 		user.Username = param.Username
 	}
 
-	err = s.repo.Create(ctx, user)
+	method := &authmethod.AuthMethod{
+		ID:         uuid.New(),
+		MethodType: authmethod.TypePassword,
+	}
+
+	err = s.repo.CreateWithAuthMethod(ctx, user, method)
 	if err != nil {
-		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+		return nil, dberrors.WrapDBError(err, consts.UserField).WithOp(op)
 	}
 
-	return nil
+	return user, nil
+}
+
+func (s *service) CreateExternalUser(ctx context.Context, param *CreateExternalUserParam) (*User, error) {
+	const op = "service.CreateExternalUser"
+
+	user := &User{
+		ID:            uuid.New(),
+		Email:         &param.Email,
+		EmailVerified: param.EmailVerified,
+		IsActive:      true,
+	}
+
+	method := &authmethod.AuthMethod{
+		ID:         uuid.New(),
+		MethodType: param.MethodType,
+		ProviderID: &param.ProviderID,
+	}
+
+	err := s.repo.CreateWithAuthMethod(ctx, user, method)
+	if err != nil {
+		return nil, dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+
+	return user, nil
 }
 
 func (s *service) UpdateUser(ctx context.Context, id uuid.UUID, param *UpdateUserParam) error {
 	const op = "service.UpdateUser"
 
-	user := &User{}
-
+	columns := map[string]interface{}{}
+	field := consts.UserField
+	if param.Username != nil {
+		if *param.Username == "" {
+			columns["username"] = nil
+		} else if !usernamePattern.MatchString(*param.Username) {
+			return apperrors.ErrInvalidX.WithField(consts.UsernameField).WithOp(op)
+		} else {
+			columns["username"] = *param.Username
+		}
+		field = consts.UsernameField
+	}
 	if param.Email != nil {
-		user.Email = param.Email
+		columns["email"] = *param.Email
+		columns["email_verified"] = false
+		field = consts.EmailField
+	}
+	if param.EmailVerified != nil {
+		columns["email_verified"] = *param.EmailVerified
+	}
+	if param.Username != nil && param.Email != nil {
+		field = consts.UserField
 	}
 
+	if len(columns) > 0 {
+		// A conflict names the field when only one unique field changed
+		if err := s.repo.UpdateColumns(ctx, id, columns); err != nil {
+			return dberrors.WrapDBError(err, field).WithOp(op)
+		}
+	}
+
+	// Users who signed up with a provider get a password method with their
+	// first password
 	if param.Password != nil {
 		hashedPassword, err := hashPassword(*param.Password)
 		if err != nil {
 			return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
 		}
-		user.PasswordHash = &hashedPassword
+
+		err = s.repo.SetPassword(ctx, id, hashedPassword, time.Now().UTC())
+		if err != nil {
+			return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+		}
 	}
 
-	if err := s.repo.Update(ctx, id, user); err != nil {
+	return nil
+}
+
+func (s *service) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	const op = "service.MarkEmailVerified"
+	err := s.repo.UpdateColumns(ctx, id, map[string]interface{}{"email_verified": true})
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+	return nil
+}
+
+// SetAccountLockedUntil locks the account until the given time, or unlocks it when nil.
+func (s *service) SetAccountLockedUntil(ctx context.Context, id uuid.UUID, until *time.Time) error {
+	const op = "service.SetAccountLockedUntil"
+	err := s.repo.UpdateColumns(ctx, id, map[string]interface{}{"account_locked_until": until})
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+	return nil
+}
+
+func (s *service) SetActive(ctx context.Context, id uuid.UUID, active bool) error {
+	const op = "service.SetActive"
+	err := s.repo.UpdateColumns(ctx, id, map[string]interface{}{"is_active": active})
+	if err != nil {
 		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
 	}
+	return nil
+}
 
+func (s *service) RequirePasswordReset(ctx context.Context, id uuid.UUID) error {
+	const op = "service.RequirePasswordReset"
+	err := s.repo.UpdateColumns(ctx, id, map[string]interface{}{"password_reset_required": true})
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+	return nil
+}
+
+func (s *service) RecordLogin(ctx context.Context, id uuid.UUID) error {
+	const op = "service.RecordLogin"
+	err := s.repo.UpdateColumns(ctx, id, map[string]interface{}{"last_login": time.Now().UTC()})
+	if err != nil {
+		return dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
 	return nil
 }
 
@@ -122,3 +238,12 @@ func (s *service) ListUsers(ctx context.Context, filter *Filter) ([]User, error)
 	}
 	return users, nil
 }
+
+func (s *service) CountUsers(ctx context.Context, filter *Filter) (int64, error) {
+	const op = "service.CountUsers"
+	count, err := s.repo.Count(ctx, filter)
+	if err != nil {
+		return 0, dberrors.WrapDBError(err, consts.UserField).WithOp(op)
+	}
+	return count, nil
+}
@@ -0,0 +1,116 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"math/big"
+	"time"
+)
+
+const (
+	codeDigits    = 6
+	fieldCodeHash = "code_hash"
+	fieldAttempts = "attempts"
+)
+
+var errInvalidCode = errors.New("invalid or expired code")
+
+// codeStore keeps single-use numeric codes hashed in Redis. A code is
+// discarded after maxAttempts wrong guesses.
+type codeStore struct {
+	prefix      string
+	ttl         time.Duration
+	maxAttempts int64
+}
+
+func generateCode() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < codeDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", codeDigits, n), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// issue replaces any outstanding code for the subject with a new one.
+func (s *codeStore) issue(ctx context.Context, subject string) (string, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return "", err
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return "", err
+	}
+
+	key := s.prefix + subject
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, fieldCodeHash, hashCode(code), fieldAttempts, 0)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// consume checks the code and deletes it on success.
+func (s *codeStore) consume(ctx context.Context, subject, code string) error {
+	client, err := redisclient.Client()
+	if err != nil {
+		return err
+	}
+
+	key := s.prefix + subject
+	attempts, err := client.HIncrBy(ctx, key, fieldAttempts, 1).Result()
+	if err != nil {
+		return err
+	}
+
+	storedHash, err := client.HGet(ctx, key, fieldCodeHash).Result()
+	if errors.Is(err, redis.Nil) {
+		// HINCRBY created the key; the code never existed or expired
+		client.Del(ctx, key)
+		return errInvalidCode
+	} else if err != nil {
+		return err
+	}
+
+	if attempts > s.maxAttempts {
+		client.Del(ctx, key)
+		return errInvalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(storedHash), []byte(hashCode(code))) != 1 {
+		return errInvalidCode
+	}
+
+	// Only the first request to delete the code may use it
+	deleted, err := client.Del(ctx, key).Result()
+	if err != nil {
+		return err
+	} else if deleted == 0 {
+		return errInvalidCode
+	}
+	return nil
+}
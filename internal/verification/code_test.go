@@ -0,0 +1,107 @@
+package verification
+
+import (
+	"auth-service/internal/testutil"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeStore(t *testing.T) {
+	store := &codeStore{prefix: "test:code:", ttl: time.Minute, maxAttempts: 3}
+
+	tests := []struct {
+		name string
+		// guesses are tried in order with the issued code standing for ""
+		guesses []string
+		// before runs between issuing the code and the guesses
+		before  func(t *testing.T, subject string)
+		wantErr []bool
+	}{
+		{
+			name:    "correct code",
+			guesses: []string{""},
+			wantErr: []bool{false},
+		},
+		{
+			name:    "single use",
+			guesses: []string{"", ""},
+			wantErr: []bool{false, true},
+		},
+		{
+			name:    "wrong guesses below the limit",
+			guesses: []string{"000000", "000001", ""},
+			wantErr: []bool{true, true, false},
+		},
+		{
+			name:    "discarded after too many wrong guesses",
+			guesses: []string{"000000", "000001", "000002", ""},
+			wantErr: []bool{true, true, true, true},
+		},
+		{
+			name:    "expired",
+			guesses: []string{""},
+			before:  func(*testing.T, string) { testutil.FastForward(time.Minute + time.Second) },
+			wantErr: []bool{true},
+		},
+		{
+			name:    "replaced by a new code",
+			guesses: []string{""},
+			before: func(t *testing.T, subject string) {
+				if _, err := store.issue(context.Background(), subject); err != nil {
+					t.Fatalf("issue: %v", err)
+				}
+			},
+			wantErr: []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			subject := uuid.NewString()
+			code, err := store.issue(ctx, subject)
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+			if tt.before != nil {
+				tt.before(t, subject)
+			}
+
+			for i, guess := range tt.guesses {
+				if guess == "" {
+					guess = code
+				}
+				err := store.consume(ctx, subject, guess)
+				if tt.wantErr[i] && !errors.Is(err, errInvalidCode) {
+					t.Errorf("guess %d: err = %v, want invalid code", i+1, err)
+				} else if !tt.wantErr[i] && err != nil {
+					t.Errorf("guess %d: err = %v, want accepted", i+1, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCodeStore_UnknownSubject(t *testing.T) {
+	store := &codeStore{prefix: "test:code:", ttl: time.Minute, maxAttempts: 3}
+
+	err := store.consume(context.Background(), uuid.NewString(), "123456")
+	if !errors.Is(err, errInvalidCode) {
+		t.Errorf("err = %v, want invalid code", err)
+	}
+}
+
+func TestGenerateCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := generateCode()
+		if err != nil {
+			t.Fatalf("generateCode: %v", err)
+		}
+		if len(code) != codeDigits || strings.Trim(code, "0123456789") != "" {
+			t.Fatalf("code = %q, want %d digits", code, codeDigits)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package verification
+
+import (
+	authconsts "auth-service/internal/shared/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/xinyi-chong/common-lib/consts"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"github.com/xinyi-chong/common-lib/response"
+	"github.com/xinyi-chong/common-lib/success"
+	"github.com/xinyi-chong/common-lib/validation"
+	"go.uber.org/zap"
+)
+
+type Controller struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func NewController(service Service, logger *zap.Logger) *Controller {
+	return &Controller{service: service, logger: logger}
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Confirm the email address with the code sent after registration
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body VerifyEmailParam true "Email and code"
+// @Success 200 {object} response.Response "Success"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/verify-email [post]
+func (ctrl *Controller) VerifyEmail(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[VerifyEmailParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.VerifyEmail(ctx, param.Email, param.Code)
+	if err != nil {
+		ctrl.logger.Error("VerifyEmail error", zap.String("email", param.Email), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, success.XUpdated.WithField(consts.EmailField), nil)
+}
+
+// ResendEmailVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification code. The response is the same whether or not the email is registered.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param body body ResendEmailVerificationParam true "Email"
+// @Success 201 {object} response.Response "Created"
+// @Failure 400 {object} response.Response "Bad Request"
+// @Failure 429 {object} response.Response "Too Many Requests"
+// @Failure 500 {object} response.Response "Internal Server Error"
+// @Router /auth/verify-email/resend [post]
+func (ctrl *Controller) ResendEmailVerification(c *gin.Context) {
+	param, err := validation.GinBindAndValidate[ResendEmailVerificationParam](c)
+	if err != nil {
+		ctrl.logger.Debug("Invalid request payload", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ctrl.service.ResendEmailVerification(ctx, param.Email)
+	if err != nil {
+		ctrl.logger.Error("ResendEmailVerification error", zap.String("email", param.Email), zap.Error(err))
+		response.Error(c, err, apperrors.ErrRequestFailed)
+		return
+	}
+
+	response.Success(c, success.XCreated.WithField(authconsts.CodeField), nil)
+}
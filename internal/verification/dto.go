@@ -0,0 +1,12 @@
+package verification
+
+type (
+	VerifyEmailParam struct {
+		Email string `json:"email" validate:"required,email"`
+		Code  string `json:"code" validate:"required,len=6,numeric"`
+	}
+
+	ResendEmailVerificationParam struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+)
@@ -0,0 +1,161 @@
+package verification
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/shared/clientinfo"
+	authconsts "auth-service/internal/shared/consts"
+	userModel "auth-service/internal/user"
+	"auth-service/pkg/mailer"
+	"context"
+	"errors"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	redisEmailCodePrefix     = "auth:email_verification:"
+	redisEmailCooldownPrefix = "auth:email_verification_cooldown:"
+
+	defaultCodeTTL        = 15 * time.Minute
+	defaultMaxAttempts    = 5
+	defaultResendCooldown = time.Minute
+)
+
+type Service interface {
+	SendEmailVerification(ctx context.Context, user *userModel.User) error
+	ResendEmailVerification(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, email, code string) error
+}
+
+type service struct {
+	userSvc        userModel.Service
+	mailer         mailer.Mailer
+	codes          *codeStore
+	resendCooldown time.Duration
+	logger         *zap.Logger
+}
+
+func NewService(cfg *config.Config, userSvc userModel.Service, mailer mailer.Mailer, logger *zap.Logger) Service {
+	codes := &codeStore{
+		prefix:      redisEmailCodePrefix,
+		ttl:         cfg.Verification.CodeTTL,
+		maxAttempts: int64(cfg.Verification.MaxAttempts),
+	}
+	if codes.ttl <= 0 {
+		codes.ttl = defaultCodeTTL
+	}
+	if codes.maxAttempts <= 0 {
+		codes.maxAttempts = defaultMaxAttempts
+	}
+
+	resendCooldown := cfg.Verification.ResendCooldown
+	if resendCooldown <= 0 {
+		resendCooldown = defaultResendCooldown
+	}
+
+	return &service{
+		userSvc:        userSvc,
+		mailer:         mailer,
+		codes:          codes,
+		resendCooldown: resendCooldown,
+		logger:         logger,
+	}
+}
+
+// SendEmailVerification issues a new code for the user's email, replacing any
+// previous one, and mails it.
+func (s *service) SendEmailVerification(ctx context.Context, user *userModel.User) error {
+	const op = "service.SendEmailVerification"
+
+	if user.Email == nil || user.EmailVerified {
+		return nil
+	}
+
+	code, err := s.codes.issue(ctx, codeSubject(user))
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	msg, err := mailer.Render(mailer.TemplateEmailVerification, struct {
+		Code             string
+		ExpiresInMinutes int
+	}{code, int(s.codes.ttl.Minutes())}, clientinfo.FromContext(ctx).Languages...)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+	msg.To = []string{*user.Email}
+
+	err = s.mailer.Send(ctx, msg)
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return nil
+}
+
+// ResendEmailVerification sends a new code unless one was sent recently. It
+// succeeds silently for unknown or verified addresses so it cannot be used to
+// find out which emails are registered.
+func (s *service) ResendEmailVerification(ctx context.Context, email string) error {
+	const op = "service.ResendEmailVerification"
+
+	user, err := s.userSvc.GetUserByEmail(ctx, email)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	client, err := redisclient.Client()
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	allowed, err := client.SetNX(ctx, redisEmailCooldownPrefix+user.ID.String(), 1, s.resendCooldown).Result()
+	if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	} else if !allowed {
+		s.logger.Debug("Verification resend within cooldown", zap.String("user_id", user.ID.String()))
+		return nil
+	}
+
+	return s.SendEmailVerification(ctx, user)
+}
+
+func (s *service) VerifyEmail(ctx context.Context, email, code string) error {
+	const op = "service.VerifyEmail"
+
+	user, err := s.userSvc.GetUserByEmail(ctx, email)
+	if apperrors.Is(err, apperrors.ErrXNotFound) {
+		return apperrors.ErrInvalidX.WithField(authconsts.CodeField).WithOp(op)
+	} else if err != nil {
+		return err
+	}
+
+	// Verified addresses get the same answer as a wrong code, so the
+	// endpoint does not tell which addresses are verified
+	if user.EmailVerified {
+		return apperrors.ErrInvalidX.WithField(authconsts.CodeField).WithOp(op)
+	}
+
+	err = s.codes.consume(ctx, codeSubject(user), code)
+	if errors.Is(err, errInvalidCode) {
+		return apperrors.ErrInvalidX.WithField(authconsts.CodeField).WithOp(op)
+	} else if err != nil {
+		return apperrors.ErrInternalServerError.WithOp(op).Wrap(err)
+	}
+
+	return s.userSvc.MarkEmailVerified(ctx, user.ID)
+}
+
+// codeSubject binds a code to the address it was sent to, so it cannot
+// verify an address the user changed to afterwards.
+func codeSubject(user *userModel.User) string {
+	return user.ID.String() + ":" + *user.Email
+}
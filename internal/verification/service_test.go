@@ -0,0 +1,129 @@
+package verification
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/testutil"
+	userModel "auth-service/internal/user"
+	"auth-service/pkg/mailer"
+	"context"
+	"github.com/google/uuid"
+	apperrors "github.com/xinyi-chong/common-lib/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+// fakeUserService keeps users in memory; other methods are not used.
+type fakeUserService struct {
+	userModel.Service
+	users map[uuid.UUID]*userModel.User
+}
+
+func (s *fakeUserService) GetUserByEmail(_ context.Context, email string) (*userModel.User, error) {
+	for _, user := range s.users {
+		if *user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrXNotFound.Wrap(gorm.ErrRecordNotFound)
+}
+
+func (s *fakeUserService) MarkEmailVerified(_ context.Context, id uuid.UUID) error {
+	s.users[id].EmailVerified = true
+	return nil
+}
+
+// fakeMailer keeps the last code it was asked to send.
+type fakeMailer struct {
+	code string
+}
+
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+func (m *fakeMailer) Send(_ context.Context, msg *mailer.Message) error {
+	m.code = codePattern.FindString(msg.TextBody)
+	return nil
+}
+
+type fixture struct {
+	*service
+	users  *fakeUserService
+	mailer *fakeMailer
+	user   *userModel.User
+}
+
+// newFixture returns a service with one unverified user.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Verification.MaxAttempts = 3
+
+	email := uuid.NewString() + "@example.com"
+	user := &userModel.User{ID: uuid.New(), Email: &email}
+	f := &fixture{
+		users:  &fakeUserService{users: map[uuid.UUID]*userModel.User{user.ID: user}},
+		mailer: &fakeMailer{},
+		user:   user,
+	}
+	f.service = NewService(cfg, f.users, f.mailer, zap.NewNop()).(*service)
+	return f
+}
+
+// send mails a code to the user's current address and returns it.
+func (f *fixture) send(t *testing.T) string {
+	t.Helper()
+	if err := f.SendEmailVerification(context.Background(), f.user); err != nil {
+		t.Fatalf("SendEmailVerification: %v", err)
+	}
+	if f.mailer.code == "" {
+		t.Fatal("no code in the email")
+	}
+	return f.mailer.code
+}
+
+func TestVerifyEmail(t *testing.T) {
+	f := newFixture(t)
+	code := f.send(t)
+
+	if err := f.VerifyEmail(context.Background(), *f.user.Email, code); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if !f.user.EmailVerified {
+		t.Error("email not marked verified")
+	}
+}
+
+func TestVerifyEmail_CodeForPreviousAddress(t *testing.T) {
+	f := newFixture(t)
+	code := f.send(t)
+
+	changed := uuid.NewString() + "@example.com"
+	f.user.Email = &changed
+
+	err := f.VerifyEmail(context.Background(), changed, code)
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Fatalf("err = %v, want invalid code", err)
+	}
+	if f.user.EmailVerified {
+		t.Error("new address verified with a code sent to the previous one")
+	}
+}
+
+func TestVerifyEmail_AlreadyVerified(t *testing.T) {
+	f := newFixture(t)
+	code := f.send(t)
+	f.user.EmailVerified = true
+
+	// Same answer as a wrong code
+	err := f.VerifyEmail(context.Background(), *f.user.Email, code)
+	if !apperrors.Is(err, apperrors.ErrInvalidX) {
+		t.Errorf("err = %v, want invalid code", err)
+	}
+}
@@ -0,0 +1,25 @@
+package wellknown
+
+import (
+	token "auth-service/pkg/jwt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+const jwksCacheControl = "public, max-age=300"
+
+type Controller struct {
+	logger *zap.Logger
+}
+
+func NewController(logger *zap.Logger) *Controller {
+	return &Controller{logger: logger}
+}
+
+// JWKS serves the public signing keys as a JSON Web Key Set (RFC 7517) so
+// other services can verify access tokens without holding a shared secret.
+func (ctrl *Controller) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", jwksCacheControl)
+	c.JSON(http.StatusOK, token.PublicJWKS())
+}
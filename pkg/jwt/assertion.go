@@ -0,0 +1,154 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"time"
+)
+
+// maxAssertionLifetime bounds how far in the future a client assertion may
+// expire, which bounds how long its jti has to be remembered.
+const maxAssertionLifetime = 10 * time.Minute
+
+var ErrNoMatchingKey = errors.New("no key matches the token")
+
+// AssertionAlgorithms are the algorithms client assertions may be signed with
+var AssertionAlgorithms = []string{AlgRS256, AlgES256, AlgEdDSA}
+
+// keyTypes maps the accepted algorithms to their JWK key type
+var keyTypes = map[string]string{
+	AlgRS256: "RSA",
+	AlgES256: "EC",
+	AlgEdDSA: "OKP",
+}
+
+// PublicKey decodes the key, the reverse of what PublicJWKS publishes.
+func (k *JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		return pub, nil
+	case "EC":
+		if k.Crv != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+// ParseClientAssertion verifies a private_key_jwt client assertion (RFC 7523)
+// signed with one of keys. Issuer and subject must be clientID and the
+// audience one of audiences. Callers must reject a reused jti.
+func ParseClientAssertion(assertion string, keys JWKSet, clientID string, audiences []string) (*jwt.RegisteredClaims, error) {
+	// Without a kid, every key of the right type is tried
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		set := jwt.VerificationKeySet{}
+		for i := range keys.Keys {
+			jwk := &keys.Keys[i]
+			if kid != "" && jwk.Kid != kid {
+				continue
+			}
+			if jwk.Alg != "" && jwk.Alg != token.Method.Alg() {
+				continue
+			}
+			if jwk.Kty != keyTypes[token.Method.Alg()] {
+				continue
+			}
+
+			key, err := jwk.PublicKey()
+			if err != nil {
+				continue
+			}
+			set.Keys = append(set.Keys, key)
+		}
+
+		if len(set.Keys) == 0 {
+			return nil, ErrNoMatchingKey
+		}
+		return set, nil
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(assertion, claims, keyFunc,
+		jwt.WithValidMethods(AssertionAlgorithms),
+		jwt.WithIssuer(clientID),
+		jwt.WithSubject(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" {
+		return nil, errors.New("client assertion has no jti")
+	}
+	if time.Until(claims.ExpiresAt.Time) > maxAssertionLifetime {
+		return nil, errors.New("client assertion expires too far in the future")
+	}
+
+	for _, aud := range claims.Audience {
+		for _, expected := range audiences {
+			if aud == expected {
+				return claims, nil
+			}
+		}
+	}
+	return nil, jwt.ErrTokenInvalidAudience
+}
+
+// UnverifiedSubject reads the sub claim without checking the signature, to
+// find the key a token must be verified with.
+func UnverifiedSubject(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(tokenString, claims)
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
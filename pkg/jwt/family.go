@@ -0,0 +1,137 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+)
+
+const (
+	redisRefreshFamilyPrefix = "auth:refresh_family:"
+	familyRevoked            = "revoked"
+)
+
+var (
+	// ErrRefreshTokenReused means an already rotated refresh token was presented.
+	// The whole family has been revoked when this is returned.
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrRefreshTokenRevoked = errors.New("refresh token family revoked")
+)
+
+// Results of rotateFamilyScript
+const (
+	rotateUnknown = 0
+	rotateOK      = 1
+	rotateReused  = -1
+	rotateRevoked = -2
+)
+
+// rotateFamilyScript swaps the family's current jti for a new one only when the
+// presented jti is the current one. A stale jti revokes the family.
+//
+// KEYS[1] family key, ARGV[1] presented jti, ARGV[2] new jti, ARGV[3] ttl in ms
+var rotateFamilyScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if current == 'revoked' then
+	return -2
+end
+if current ~= ARGV[1] then
+	redis.call('SET', KEYS[1], 'revoked', 'KEEPTTL')
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// NewRefreshTokenFamily starts a new refresh token family, e.g. on login, and
+// returns its first refresh token together with the family ID.
+func NewRefreshTokenFamily(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	familyID := uuid.NewString()
+	jti := uuid.NewString()
+
+	refreshToken, err := generateRefreshToken(userID, familyID, jti)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := redisclient.Set(ctx, redisRefreshFamilyPrefix+familyID, jti, refreshExpiry); err != nil {
+		return "", "", fmt.Errorf("storage failure: %w", err)
+	}
+
+	return refreshToken, familyID, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for the next one in its family.
+// Presenting a token that was already rotated returns ErrRefreshTokenReused
+// and revokes every token in the family.
+func RotateRefreshToken(ctx context.Context, claims *RefreshTokenClaims) (string, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return "", err
+	}
+
+	jti := uuid.NewString()
+	result, err := rotateFamilyScript.Run(ctx, client,
+		[]string{redisRefreshFamilyPrefix + claims.FamilyID},
+		claims.ID, jti, refreshExpiry.Milliseconds(),
+	).Int()
+	if err != nil {
+		return "", fmt.Errorf("storage failure: %w", err)
+	}
+
+	switch result {
+	case rotateOK:
+		return generateRefreshToken(claims.UserID, claims.FamilyID, jti)
+	case rotateReused:
+		return "", ErrRefreshTokenReused
+	case rotateRevoked:
+		return "", ErrRefreshTokenRevoked
+	default:
+		return "", ErrRefreshTokenRevoked
+	}
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token in the family along with
+// the access tokens issued with them.
+func RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	client, err := redisclient.Client()
+	if err != nil {
+		return err
+	}
+
+	// Keep the key until the last token of the family expires so reuse is still detected
+	err = client.SetArgs(ctx, redisRefreshFamilyPrefix+familyID, familyRevoked, redis.SetArgs{
+		Mode:    "XX",
+		KeepTTL: true,
+	}).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("storage failure: %w", err)
+	}
+	return nil
+}
+
+// IsRefreshTokenFamilyRevoked reports whether the family was revoked or has expired.
+func IsRefreshTokenFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	current, err := redisclient.Get(ctx, redisRefreshFamilyPrefix+familyID)
+	if errors.Is(err, redis.Nil) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return current == familyRevoked, nil
+}
+
+// RevokeRefreshToken revokes the family of the given refresh token.
+func RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	claims, err := ParseRefreshToken(refreshToken)
+	if err != nil {
+		return fmt.Errorf("token validation failed: %w", err)
+	}
+	return RevokeRefreshTokenFamily(ctx, claims.FamilyID)
+}
@@ -0,0 +1,161 @@
+package token
+
+import (
+	"auth-service/internal/config"
+	"auth-service/internal/testutil"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET_KEY", "test-secret-key-of-at-least-32-bytes")
+	os.Exit(testutil.RunWithRedis(m))
+}
+
+func initTokens(t *testing.T) {
+	t.Helper()
+	if err := Init(&config.Config{}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+}
+
+// newFamily starts a family and returns the claims of its first token.
+func newFamily(t *testing.T) *RefreshTokenClaims {
+	t.Helper()
+	initTokens(t)
+	refreshToken, familyID, err := NewRefreshTokenFamily(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatalf("NewRefreshTokenFamily: %v", err)
+	}
+	claims, err := ParseRefreshToken(refreshToken)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken: %v", err)
+	}
+	if claims.FamilyID != familyID {
+		t.Fatalf("family = %q, want %q", claims.FamilyID, familyID)
+	}
+	return claims
+}
+
+func rotate(t *testing.T, claims *RefreshTokenClaims) *RefreshTokenClaims {
+	t.Helper()
+	refreshToken, err := RotateRefreshToken(context.Background(), claims)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	next, err := ParseRefreshToken(refreshToken)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken: %v", err)
+	}
+	return next
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	first := newFamily(t)
+
+	second := rotate(t, first)
+	if second.FamilyID != first.FamilyID || second.UserID != first.UserID {
+		t.Errorf("rotated token left the family: %+v", second)
+	}
+	if second.ID == first.ID {
+		t.Error("rotated token kept its jti")
+	}
+	rotate(t, second)
+
+	revoked, err := IsRefreshTokenFamilyRevoked(context.Background(), first.FamilyID)
+	if err != nil || revoked {
+		t.Errorf("IsRefreshTokenFamilyRevoked = %v, %v, want false", revoked, err)
+	}
+}
+
+func TestRotateRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	first := newFamily(t)
+	second := rotate(t, first)
+
+	_, err := RotateRefreshToken(ctx, first)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token: err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// The legitimate holder is logged out too, since either side may be the thief
+	_, err = RotateRefreshToken(ctx, second)
+	if !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("latest token: err = %v, want ErrRefreshTokenRevoked", err)
+	}
+	revoked, err := IsRefreshTokenFamilyRevoked(ctx, first.FamilyID)
+	if err != nil || !revoked {
+		t.Errorf("IsRefreshTokenFamilyRevoked = %v, %v, want true", revoked, err)
+	}
+}
+
+func TestRotateRefreshToken_RevokedFamily(t *testing.T) {
+	ctx := context.Background()
+	claims := newFamily(t)
+
+	if err := RevokeRefreshTokenFamily(ctx, claims.FamilyID); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	_, err := RotateRefreshToken(ctx, claims)
+	if !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("err = %v, want ErrRefreshTokenRevoked", err)
+	}
+}
+
+func TestRotateRefreshToken_UnknownFamily(t *testing.T) {
+	claims := newFamily(t)
+	claims.FamilyID = uuid.NewString()
+
+	_, err := RotateRefreshToken(context.Background(), claims)
+	if !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("err = %v, want ErrRefreshTokenRevoked", err)
+	}
+}
+
+func TestRevokeRefreshTokenFamily_UnknownFamily(t *testing.T) {
+	initTokens(t)
+	ctx := context.Background()
+	familyID := uuid.NewString()
+
+	// Revoking must not create a family that could later be rotated into
+	if err := RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	_, err := redisclient.Get(ctx, redisRefreshFamilyPrefix+familyID)
+	if !errors.Is(err, redis.Nil) {
+		t.Errorf("family stored by revoke: err = %v", err)
+	}
+}
+
+func TestRotateRefreshToken_ConcurrentUse(t *testing.T) {
+	claims := newFamily(t)
+
+	const attempts = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := RotateRefreshToken(context.Background(), claims); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d of %d concurrent rotations succeeded, want 1", succeeded, attempts)
+	}
+}
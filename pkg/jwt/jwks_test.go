@@ -0,0 +1,136 @@
+package token
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestKey generates a key that signs from activatesAt.
+func newTestKey(t *testing.T, alg string, activatesAt time.Time) *signingKey {
+	t.Helper()
+	record, err := generateKeyRecord(alg)
+	if err != nil {
+		t.Fatalf("generateKeyRecord(%s): %v", alg, err)
+	}
+	record.ActivatesAt = activatesAt
+	key, err := keyFromRecord(*record)
+	if err != nil {
+		t.Fatalf("keyFromRecord(%s): %v", alg, err)
+	}
+	return key
+}
+
+// useKeys puts keys in the ring next to the configured key until the test
+// ends.
+func useKeys(t *testing.T, keys ...*signingKey) {
+	t.Helper()
+	initTokens(t)
+	ring.replace(keys)
+	t.Cleanup(func() { ring.replace(nil) })
+}
+
+func TestPublicJWKS(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	keys := map[string]*signingKey{
+		AlgRS256: newTestKey(t, AlgRS256, past),
+		AlgES256: newTestKey(t, AlgES256, past),
+		AlgEdDSA: newTestKey(t, AlgEdDSA, past),
+	}
+	useKeys(t, keys[AlgRS256], keys[AlgES256], keys[AlgEdDSA], newTestKey(t, AlgHS256, past))
+
+	published := PublicJWKS()
+	if len(published.Keys) != len(keys) {
+		t.Fatalf("published %d keys, want %d without the HMAC keys", len(published.Keys), len(keys))
+	}
+	for _, jwk := range published.Keys {
+		key, ok := keys[jwk.Alg]
+		if !ok {
+			t.Errorf("published %s key %s", jwk.Alg, jwk.Kid)
+			continue
+		}
+		if jwk.Kid != key.id || jwk.Use != "sig" {
+			t.Errorf("%s: kid = %q, use = %q, want %q, sig", jwk.Alg, jwk.Kid, jwk.Use, key.id)
+		}
+
+		// What verifiers decode is the key that signs
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			t.Errorf("%s: PublicKey: %v", jwk.Alg, err)
+			continue
+		}
+		if !reflect.DeepEqual(pub, key.verifyKey) {
+			t.Errorf("%s: published key differs from the signing key", jwk.Alg)
+		}
+
+		signed, err := jwt.NewWithClaims(key.method, jwt.RegisteredClaims{Subject: "ada"}).SignedString(key.signKey)
+		if err != nil {
+			t.Fatalf("%s: sign: %v", jwk.Alg, err)
+		}
+		if _, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return pub, nil }); err != nil {
+			t.Errorf("%s: token does not verify with the published key: %v", jwk.Alg, err)
+		}
+	}
+}
+
+func TestPublicJWKS_SymmetricOnly(t *testing.T) {
+	useKeys(t)
+
+	data, err := json.Marshal(PublicJWKS())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `{"keys":[]}` {
+		t.Errorf("JWKS = %s, want an empty key list", data)
+	}
+}
+
+func TestPublicJWKS_ExpiredKey(t *testing.T) {
+	expired := newTestKey(t, AlgES256, time.Now().Add(-2*time.Hour))
+	expiresAt := time.Now().Add(-time.Minute)
+	expired.expiresAt = &expiresAt
+	useKeys(t, expired, newTestKey(t, AlgES256, time.Now().Add(-time.Hour)))
+
+	for _, jwk := range PublicJWKS().Keys {
+		if jwk.Kid == expired.id {
+			t.Error("expired key still published")
+		}
+	}
+}
+
+// RFC 7638 section 3.1
+func TestThumbprint(t *testing.T) {
+	jwk := &JWK{
+		Kty: "RSA",
+		E:   "AQAB",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+
+	got, err := thumbprint(jwk)
+	if err != nil {
+		t.Fatalf("thumbprint: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("thumbprint = %s, want %s", got, want)
+	}
+}
+
+func TestAccessToken_KeyIDHeader(t *testing.T) {
+	key := newTestKey(t, AlgEdDSA, time.Now().Add(-time.Hour))
+	useKeys(t, key)
+
+	accessToken, err := GenerateAccessToken(uuid.New(), nil, nil, "", nil)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(accessToken, &AccessTokenClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	if parsed.Header["kid"] != key.id || parsed.Header["alg"] != AlgEdDSA {
+		t.Errorf("header = %v, want kid %s and EdDSA", parsed.Header, key.id)
+	}
+}
@@ -0,0 +1,443 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xinyi-chong/common-lib/logger"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	redisKeyRotationLock = "auth:keys:rotation_lock"
+	rotationLockTTL      = 30 * time.Second
+	hmacSecretSize       = 32
+)
+
+var (
+	ErrUnknownKey         = errors.New("unknown signing key")
+	ErrKeyStoreNotSet     = errors.New("key store not configured")
+	ErrRotationInProgress = errors.New("key rotation already in progress")
+	ring                  = &keyRing{keys: map[string]*signingKey{}}
+	keyStore              KeyStore
+	rotationAlgorithm     = AlgHS256
+
+	// keyActivationDelay is how long a new key is only published before it
+	// signs, so every instance and JWKS consumer knows it by then.
+	keyActivationDelay = 2 * time.Minute
+)
+
+type (
+	// KeyRecord is the persisted form of a signing key.
+	KeyRecord struct {
+		KeyID       string
+		Algorithm   string
+		Secret      []byte // PEM encoded private key, or the raw HMAC secret
+		CreatedAt   time.Time
+		ActivatesAt time.Time  // used for signing from then on
+		RetiredAt   *time.Time // no longer used for signing
+		ExpiresAt   *time.Time // no longer accepted for verification
+	}
+
+	// KeyStore persists the key ring so every instance signs with the same key
+	// and rotation survives restarts.
+	KeyStore interface {
+		ListKeys(ctx context.Context) ([]KeyRecord, error)
+		CreateKey(ctx context.Context, key *KeyRecord) error
+		RetireKey(ctx context.Context, keyID string, retiredAt, expiresAt time.Time) error
+		DeleteExpiredKeys(ctx context.Context, before time.Time) error
+	}
+
+	KeyInfo struct {
+		KeyID       string     `json:"kid"`
+		Algorithm   string     `json:"alg"`
+		Current     bool       `json:"current"`
+		Static      bool       `json:"static"`
+		CreatedAt   *time.Time `json:"created_at,omitempty"`
+		ActivatesAt *time.Time `json:"activates_at,omitempty"`
+		RetiredAt   *time.Time `json:"retired_at,omitempty"`
+		ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	}
+)
+
+// keyRing holds the key used for signing and every key still accepted for
+// verification. The configured key signs until the first rotation, which
+// records it in the key store to be retired like any other key.
+type keyRing struct {
+	mu      sync.RWMutex
+	static  *signingKey
+	current *signingKey
+	keys    map[string]*signingKey
+}
+
+func (r *keyRing) signing() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+func (r *keyRing) verification(kid interface{}) (*signingKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Tokens issued before key IDs were introduced carry no kid
+	id, ok := kid.(string)
+	if kid == nil {
+		id = r.static.id
+	} else if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	key, ok := r.keys[id]
+	if !ok || (key.expiresAt != nil && time.Now().After(*key.expiresAt)) {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (r *keyRing) verificationKeys() []*signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]*signingKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.expiresAt == nil || now.Before(*key.expiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].id < keys[j].id })
+	return keys
+}
+
+// pending reports whether a rotated key is waiting to sign.
+func (r *keyRing) pending(now time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.activatesAt != nil && now.Before(*key.activatesAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *keyRing) replace(stored []*signingKey) {
+	now := time.Now()
+	keys := make(map[string]*signingKey, len(stored)+1)
+	var current *signingKey
+	for _, key := range stored {
+		keys[key.id] = key
+		if key.canSign(now) && (current == nil || key.activatesAt.After(*current.activatesAt)) {
+			current = key
+		}
+	}
+
+	if current == nil {
+		if len(stored) > 0 {
+			logger.Error("No stored signing key is active, signing with the configured key")
+		}
+		current = r.static
+		keys[r.static.id] = r.static
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = keys
+	r.current = current
+}
+
+// UseKeyStore loads persisted keys and enables rotation.
+func UseKeyStore(ctx context.Context, store KeyStore) error {
+	keyStore = store
+	return ReloadKeys(ctx)
+}
+
+// ReloadKeys refreshes the key ring from the key store, picking up rotations
+// performed by other instances.
+func ReloadKeys(ctx context.Context) error {
+	if keyStore == nil {
+		return ErrKeyStoreNotSet
+	}
+
+	records, err := keyStore.ListKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("list signing keys: %w", err)
+	}
+
+	now := time.Now()
+	stored := make([]*signingKey, 0, len(records))
+	for _, record := range records {
+		if record.ExpiresAt != nil && now.After(*record.ExpiresAt) {
+			continue
+		}
+
+		key, err := keyFromRecord(record)
+		if err != nil {
+			logger.Error("ReloadKeys: skip invalid signing key", zap.String("kid", record.KeyID), zap.Error(err))
+			continue
+		}
+		stored = append(stored, key)
+	}
+
+	ring.replace(stored)
+	return nil
+}
+
+// RotateKey generates a new signing key and retires the current one. The
+// retired key keeps verifying tokens until the longest token lifetime elapses,
+// so rotation never invalidates outstanding sessions.
+func RotateKey(ctx context.Context) (*KeyInfo, error) {
+	if keyStore == nil {
+		return nil, ErrKeyStoreNotSet
+	}
+
+	client, err := redisclient.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := client.SetNX(ctx, redisKeyRotationLock, "1", rotationLockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("acquire rotation lock: %w", err)
+	} else if !locked {
+		return nil, ErrRotationInProgress
+	}
+	defer client.Del(context.WithoutCancel(ctx), redisKeyRotationLock)
+
+	// Another instance may have rotated since our last sync
+	if err := ReloadKeys(ctx); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if ring.pending(now) {
+		return nil, ErrRotationInProgress
+	}
+	previous := ring.signing()
+
+	// The configured key has no record until now
+	if previous == ring.static {
+		static, err := previous.record(now)
+		if err != nil {
+			return nil, fmt.Errorf("record configured signing key: %w", err)
+		}
+		if err := keyStore.CreateKey(ctx, static); err != nil {
+			return nil, fmt.Errorf("store configured signing key: %w", err)
+		}
+	}
+
+	record, err := generateKeyRecord(rotationAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+
+	// Published now, signing once every instance has synced
+	record.ActivatesAt = now.Add(keyActivationDelay).UTC()
+	if err := keyStore.CreateKey(ctx, record); err != nil {
+		return nil, fmt.Errorf("store signing key: %w", err)
+	}
+
+	retiredAt := record.ActivatesAt
+	if err := keyStore.RetireKey(ctx, previous.id, retiredAt, retiredAt.Add(refreshExpiry)); err != nil {
+		return nil, fmt.Errorf("retire signing key: %w", err)
+	}
+
+	if err := keyStore.DeleteExpiredKeys(ctx, time.Now()); err != nil {
+		logger.Warn("RotateKey: delete expired keys failed", zap.Error(err))
+	}
+
+	if err := ReloadKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Signing key rotated",
+		zap.String("kid", record.KeyID),
+		zap.String("previous_kid", previous.id),
+		zap.Time("activates_at", record.ActivatesAt))
+
+	key, err := ring.verification(record.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return key.info(ring.signing()), nil
+}
+
+// StartKeyRotation periodically syncs the key ring and rotates the signing key
+// once it is older than rotationInterval. A zero interval only syncs.
+func StartKeyRotation(ctx context.Context, rotationInterval, syncInterval time.Duration) {
+	if syncInterval <= 0 {
+		syncInterval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ReloadKeys(ctx); err != nil {
+					logger.Warn("StartKeyRotation: reload keys failed", zap.Error(err))
+					continue
+				}
+
+				current := ring.signing()
+				if rotationInterval <= 0 || ring.pending(time.Now()) ||
+					(current.activatesAt != nil && time.Since(*current.activatesAt) < rotationInterval) {
+					continue
+				}
+
+				if _, err := RotateKey(ctx); err != nil && !errors.Is(err, ErrRotationInProgress) {
+					logger.Error("StartKeyRotation: rotate key failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Keys describes every key in the ring, for administration.
+func Keys() []KeyInfo {
+	current := ring.signing()
+	keys := ring.verificationKeys()
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, *key.info(current))
+	}
+	return infos
+}
+
+func (k *signingKey) info(current *signingKey) *KeyInfo {
+	return &KeyInfo{
+		KeyID:       k.id,
+		Algorithm:   k.method.Alg(),
+		Current:     k == current,
+		Static:      k.id == ring.static.id,
+		CreatedAt:   k.createdAt,
+		ActivatesAt: k.activatesAt,
+		RetiredAt:   k.retiredAt,
+		ExpiresAt:   k.expiresAt,
+	}
+}
+
+// canSign reports whether the key is activated and not yet retired.
+func (k *signingKey) canSign(now time.Time) bool {
+	return k.activatesAt != nil && !now.Before(*k.activatesAt) &&
+		(k.retiredAt == nil || now.Before(*k.retiredAt))
+}
+
+// record is the persisted form of the key, active from now.
+func (k *signingKey) record(now time.Time) (*KeyRecord, error) {
+	var secret []byte
+	if k.isSymmetric() {
+		secret = k.signKey.([]byte)
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+		if err != nil {
+			return nil, err
+		}
+		secret = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	return &KeyRecord{
+		KeyID:       k.id,
+		Algorithm:   k.method.Alg(),
+		Secret:      secret,
+		CreatedAt:   now.UTC(),
+		ActivatesAt: now.UTC(),
+	}, nil
+}
+
+func keyFromRecord(record KeyRecord) (*signingKey, error) {
+	var key *signingKey
+	if record.Algorithm == AlgHS256 {
+		key = newHMACKey(record.KeyID, record.Secret)
+	} else {
+		var err error
+		key, err = newAsymmetricKey(record.Algorithm, record.Secret, record.KeyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	createdAt, activatesAt := record.CreatedAt, record.ActivatesAt
+	key.createdAt = &createdAt
+	key.activatesAt = &activatesAt
+	key.retiredAt = record.RetiredAt
+	key.expiresAt = record.ExpiresAt
+	return key, nil
+}
+
+func generateKeyRecord(alg string) (*KeyRecord, error) {
+	var secret []byte
+	switch alg {
+	case AlgHS256:
+		secret = make([]byte, hmacSecretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	case AlgRS256, AlgES256, AlgEdDSA:
+		privateKey, err := generatePrivateKey(alg)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		secret = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+
+	record := &KeyRecord{
+		Algorithm: alg,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	key, err := keyFromRecord(*record)
+	if err != nil {
+		return nil, err
+	}
+	record.KeyID = key.id
+	return record, nil
+}
+
+func generatePrivateKey(alg string) (interface{}, error) {
+	switch alg {
+	case AlgRS256:
+		return rsa.GenerateKey(rand.Reader, minRSABits)
+	case AlgES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+}
+
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	key, err := ring.verification(token.Header["kid"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", err, token.Header["kid"])
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
@@ -0,0 +1,248 @@
+package token
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryKeyStore keeps key records in memory.
+type memoryKeyStore struct {
+	mu      sync.Mutex
+	records map[string]*KeyRecord
+}
+
+func (s *memoryKeyStore) ListKeys(context.Context) ([]KeyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]KeyRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, *record)
+	}
+	return records, nil
+}
+
+func (s *memoryKeyStore) CreateKey(_ context.Context, key *KeyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *key
+	s.records[key.KeyID] = &copied
+	return nil
+}
+
+func (s *memoryKeyStore) RetireKey(_ context.Context, keyID string, retiredAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[keyID].RetiredAt = &retiredAt
+	s.records[keyID].ExpiresAt = &expiresAt
+	return nil
+}
+
+func (s *memoryKeyStore) DeleteExpiredKeys(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, record := range s.records {
+		if record.ExpiresAt != nil && record.ExpiresAt.Before(before) {
+			delete(s.records, id)
+		}
+	}
+	return nil
+}
+
+// shift moves every timestamp of the key back by d, as if d had passed.
+func (s *memoryKeyStore) shift(keyID string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[keyID]
+	record.CreatedAt = record.CreatedAt.Add(-d)
+	record.ActivatesAt = record.ActivatesAt.Add(-d)
+	for _, at := range []*time.Time{record.RetiredAt, record.ExpiresAt} {
+		if at != nil {
+			*at = at.Add(-d)
+		}
+	}
+}
+
+// useKeyStore rotates to alg keys kept in a fresh store until the test ends.
+func useKeyStore(t *testing.T, alg string) *memoryKeyStore {
+	t.Helper()
+	initTokens(t)
+	store := &memoryKeyStore{records: map[string]*KeyRecord{}}
+	previousAlg := rotationAlgorithm
+	rotationAlgorithm = alg
+	if err := UseKeyStore(context.Background(), store); err != nil {
+		t.Fatalf("UseKeyStore: %v", err)
+	}
+	t.Cleanup(func() {
+		keyStore = nil
+		rotationAlgorithm = previousAlg
+		ring.replace(nil)
+	})
+	return store
+}
+
+func kidOf(t *testing.T, tokenString string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, &AccessTokenClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestRotateKey(t *testing.T) {
+	store := useKeyStore(t, AlgES256)
+	ctx := context.Background()
+	configured := ring.signing()
+
+	before, err := GenerateAccessToken(uuid.New(), nil, nil, "", nil)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	info, err := RotateKey(ctx)
+	if err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	if info.Algorithm != AlgES256 || info.Current {
+		t.Errorf("rotated key = %+v, want a published ES256 key that does not sign yet", info)
+	}
+
+	// Published before it signs, so verifiers learn it in time
+	published := false
+	for _, jwk := range PublicJWKS().Keys {
+		published = published || jwk.Kid == info.KeyID
+	}
+	if !published {
+		t.Error("rotated key not published")
+	}
+	if ring.signing().id != configured.id {
+		t.Error("rotated key signs before it activates")
+	}
+	if _, err := RotateKey(ctx); !errors.Is(err, ErrRotationInProgress) {
+		t.Errorf("second rotation: err = %v, want rotation in progress", err)
+	}
+
+	store.shift(info.KeyID, keyActivationDelay)
+	store.shift(configured.id, keyActivationDelay)
+	if err := ReloadKeys(ctx); err != nil {
+		t.Fatalf("ReloadKeys: %v", err)
+	}
+
+	after, err := GenerateAccessToken(uuid.New(), nil, nil, "", nil)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	if kid := kidOf(t, after); kid != info.KeyID {
+		t.Errorf("signed with %s, want the rotated key %s", kid, info.KeyID)
+	}
+
+	// Rotation logs nobody out
+	for name, accessToken := range map[string]string{"before": before, "after": after} {
+		if _, err := ParseAccessToken(accessToken); err != nil {
+			t.Errorf("token issued %s rotation: %v", name, err)
+		}
+	}
+}
+
+func TestRotateKey_RetiredKeyExpires(t *testing.T) {
+	store := useKeyStore(t, AlgHS256)
+	ctx := context.Background()
+	configured := ring.signing()
+
+	old, err := GenerateAccessToken(uuid.New(), nil, nil, "", nil)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	info, err := RotateKey(ctx)
+	if err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+
+	// Past the activation and the longest token lifetime
+	elapsed := keyActivationDelay + refreshExpiry + time.Minute
+	store.shift(info.KeyID, elapsed)
+	store.shift(configured.id, elapsed)
+	if err := ReloadKeys(ctx); err != nil {
+		t.Fatalf("ReloadKeys: %v", err)
+	}
+
+	if _, err := ParseAccessToken(old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of the expired key: err = %v, want unknown key", err)
+	}
+	for _, key := range Keys() {
+		if key.KeyID == configured.id {
+			t.Error("expired key still listed")
+		}
+	}
+}
+
+func TestKeyFunc_WrongAlgorithm(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	rsaKey := newTestKey(t, AlgRS256, past)
+	initTokens(t)
+	// Recorded by the first rotation, as RotateKey does
+	hmacKey := ring.static
+	useKeys(t, rsaKey, hmacKey)
+
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.verifyKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	rsaPublicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	claims := AccessTokenClaims{
+		UserID: uuid.New(),
+		Type:   TypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	sign := func(method jwt.SigningMethod, kid interface{}, key interface{}) string {
+		t.Helper()
+		unsigned := jwt.NewWithClaims(method, claims)
+		if kid != nil {
+			unsigned.Header["kid"] = kid
+		}
+		signed, err := unsigned.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign %s: %v", method.Alg(), err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		accepted bool
+	}{
+		{"RSA key", sign(jwt.SigningMethodRS256, rsaKey.id, rsaKey.signKey), true},
+		{"configured key without kid", sign(jwt.SigningMethodHS256, nil, hmacKey.signKey), true},
+		{"HS256 with the RSA public key", sign(jwt.SigningMethodHS256, rsaKey.id, rsaPublicPEM), false},
+		{"RS256 under the HMAC kid", sign(jwt.SigningMethodRS256, hmacKey.id, rsaKey.signKey), false},
+		{"HS384 under the HMAC kid", sign(jwt.SigningMethodHS384, hmacKey.id, hmacKey.signKey), false},
+		{"alg none", sign(jwt.SigningMethodNone, rsaKey.id, jwt.UnsafeAllowNoneSignatureType), false},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "unknown", rsaKey.signKey), false},
+		{"numeric kid", sign(jwt.SigningMethodHS256, 7, hmacKey.signKey), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAccessToken(tt.token)
+			if tt.accepted && err != nil {
+				t.Errorf("err = %v, want accepted", err)
+			} else if !tt.accepted && err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"time"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+const minRSABits = 2048
+
+// signingKey is a key able to sign and verify tokens with a single algorithm.
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+
+	// Unset for the configured key until a rotation records it
+	createdAt   *time.Time
+	activatesAt *time.Time
+	retiredAt   *time.Time
+	expiresAt   *time.Time
+}
+
+func (k *signingKey) isSymmetric() bool {
+	_, ok := k.method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+func newHMACKey(kid string, secret []byte) *signingKey {
+	if kid == "" {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte("kid"))
+		kid = base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:16]
+	}
+	return &signingKey{
+		id:        kid,
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+func loadPrivateKeyFile(alg, path, kid string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	return newAsymmetricKey(alg, data, kid)
+}
+
+func newAsymmetricKey(alg string, pemData []byte, kid string) (*signingKey, error) {
+	signer, err := parsePrivateKeyPEM(pemData)
+	if err != nil {
+		return nil, err
+	}
+
+	var method jwt.SigningMethod
+	switch alg {
+	case AlgRS256:
+		rsaKey, ok := signer.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 requires an RSA private key")
+		}
+		if rsaKey.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case AlgES256:
+		ecKey, ok := signer.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires an ECDSA P-256 private key")
+		}
+		method = jwt.SigningMethodES256
+	case AlgEdDSA:
+		if _, ok := signer.(ed25519.PrivateKey); !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 private key")
+		}
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported asymmetric algorithm: %s", alg)
+	}
+
+	key := &signingKey{
+		id:        kid,
+		method:    method,
+		signKey:   signer,
+		verifyKey: signer.Public(),
+	}
+
+	if key.id == "" {
+		jwk, err := publicJWK(key)
+		if err != nil {
+			return nil, err
+		}
+		key.id, err = thumbprint(jwk)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// parsePrivateKeyPEM accepts PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) encoded keys.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported PKCS#8 private key type")
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+}
@@ -0,0 +1,88 @@
+package token
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"time"
+)
+
+// TypeMFAPending marks the token a password login returns when the user still
+// has to pass a second factor. It only grants the right to try one.
+const TypeMFAPending = "mfa_pending"
+
+const redisMFAClaimPrefix = "auth:mfa_claimed:"
+
+var mfaExpiry = 5 * time.Minute
+
+type MFATokenClaims struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Type       string    `json:"typ"`
+	DeviceName *string   `json:"device_name,omitempty"`
+	AMR        []string  `json:"amr,omitempty"` // how the first factor was passed
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAToken issues a short-lived token for the second login step.
+// deviceName and amr are carried over to the session the second step opens.
+func GenerateMFAToken(userID uuid.UUID, deviceName *string, amr []string) (string, error) {
+	claims := MFATokenClaims{
+		UserID:     userID,
+		Type:       TypeMFAPending,
+		DeviceName: deviceName,
+		AMR:        amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaExpiry)),
+		},
+	}
+	return generateToken(claims)
+}
+
+func ParseMFAToken(mfaToken string) (*MFATokenClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(mfaToken, &MFATokenClaims{}, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := parsedToken.Claims.(*MFATokenClaims)
+	if !ok || !parsedToken.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if claims.Type != TypeMFAPending || claims.ID == "" {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
+
+// ClaimMFAToken takes the single use of an MFA token. It reports false when
+// the token was already used, or another request is verifying it.
+func ClaimMFAToken(ctx context.Context, claims *MFATokenClaims) (bool, error) {
+	client, err := redisclient.Client()
+	if err != nil {
+		return false, err
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return client.SetNX(ctx, redisMFAClaimPrefix+claims.ID, 1, ttl).Result()
+}
+
+// ReleaseMFAToken hands back a claimed MFA token whose second factor failed,
+// so the user can try again.
+func ReleaseMFAToken(ctx context.Context, claims *MFATokenClaims) error {
+	client, err := redisclient.Client()
+	if err != nil {
+		return err
+	}
+	return client.Del(ctx, redisMFAClaimPrefix+claims.ID).Err()
+}
+
+// IsMFATokenClaimed reports whether the MFA token is used or being verified.
+func IsMFATokenClaimed(ctx context.Context, claims *MFATokenClaims) (bool, error) {
+	return redisclient.Exists(ctx, redisMFAClaimPrefix+claims.ID)
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func newMFAClaims(t *testing.T) *MFATokenClaims {
+	t.Helper()
+	initTokens(t)
+	mfaToken, err := GenerateMFAToken(uuid.New(), nil, []string{"pwd"})
+	if err != nil {
+		t.Fatalf("GenerateMFAToken: %v", err)
+	}
+	claims, err := ParseMFAToken(mfaToken)
+	if err != nil {
+		t.Fatalf("ParseMFAToken: %v", err)
+	}
+	return claims
+}
+
+func TestClaimMFAToken(t *testing.T) {
+	ctx := context.Background()
+	claims := newMFAClaims(t)
+
+	if claimed, err := IsMFATokenClaimed(ctx, claims); err != nil || claimed {
+		t.Fatalf("IsMFATokenClaimed = %v, %v, want unclaimed", claimed, err)
+	}
+	if claimed, err := ClaimMFAToken(ctx, claims); err != nil || !claimed {
+		t.Fatalf("ClaimMFAToken = %v, %v, want claimed", claimed, err)
+	}
+	if claimed, err := ClaimMFAToken(ctx, claims); err != nil || claimed {
+		t.Errorf("second ClaimMFAToken = %v, %v, want refused", claimed, err)
+	}
+	if claimed, _ := IsMFATokenClaimed(ctx, claims); !claimed {
+		t.Error("claimed token reported unclaimed")
+	}
+
+	// A failed factor hands the token back
+	if err := ReleaseMFAToken(ctx, claims); err != nil {
+		t.Fatalf("ReleaseMFAToken: %v", err)
+	}
+	if claimed, err := ClaimMFAToken(ctx, claims); err != nil || !claimed {
+		t.Errorf("ClaimMFAToken after release = %v, %v, want claimed", claimed, err)
+	}
+}
+
+func TestClaimMFAToken_Concurrent(t *testing.T) {
+	claims := newMFAClaims(t)
+
+	const attempts = 8
+	var claimed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := ClaimMFAToken(context.Background(), claims)
+			if err != nil {
+				t.Errorf("ClaimMFAToken: %v", err)
+			} else if ok {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := claimed.Load(); n != 1 {
+		t.Errorf("%d of %d claims succeeded, want 1", n, attempts)
+	}
+}
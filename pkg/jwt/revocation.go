@@ -0,0 +1,53 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"strconv"
+	"time"
+)
+
+const redisUserRevokedPrefix = "auth:user_revoked_before:"
+
+// RevokeUserTokens invalidates every token issued to the user up to and
+// including the millisecond of revokedAt. The watermark lives as long as the
+// longest token lifetime, after which older tokens have expired anyway.
+func RevokeUserTokens(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	err := redisclient.Set(ctx, redisUserRevokedPrefix+userID.String(), revokedAt.UnixMilli(), refreshExpiry)
+	if err != nil {
+		return fmt.Errorf("storage failure: %w", err)
+	}
+	return nil
+}
+
+// IsRevokedForUser reports whether a token issued at issuedAt predates the
+// user's revocation watermark.
+func IsRevokedForUser(ctx context.Context, userID uuid.UUID, issuedAt *jwt.NumericDate) (bool, error) {
+	value, err := redisclient.Get(ctx, redisUserRevokedPrefix+userID.String())
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	watermark, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid revocation watermark: %w", err)
+	}
+
+	return IssuedNotAfter(issuedAt, time.UnixMilli(watermark)), nil
+}
+
+// IssuedNotAfter reports whether issuedAt is missing or not after t, at the
+// millisecond precision of the timestamps in our tokens.
+func IssuedNotAfter(issuedAt *jwt.NumericDate, t time.Time) bool {
+	if issuedAt == nil {
+		return true
+	}
+	return !issuedAt.Time.After(t.Truncate(jwt.TimePrecision))
+}
@@ -0,0 +1,75 @@
+package token
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	redisclient "github.com/xinyi-chong/common-lib/redis"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIsRevokedForUser(t *testing.T) {
+	initTokens(t)
+	revokedAt := time.Date(2026, 3, 1, 12, 0, 0, 250*int(time.Millisecond), time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt *jwt.NumericDate
+		want     bool
+	}{
+		{"no issue time", nil, true},
+		{"long before", jwt.NewNumericDate(revokedAt.Add(-time.Hour)), true},
+		{"same second, earlier", jwt.NewNumericDate(revokedAt.Add(-100 * time.Millisecond)), true},
+		{"same millisecond", jwt.NewNumericDate(revokedAt.Add(400 * time.Microsecond)), true},
+		// Tokens issued right after a logout everywhere stay valid
+		{"next millisecond", jwt.NewNumericDate(revokedAt.Add(time.Millisecond)), false},
+		{"same second, later", jwt.NewNumericDate(revokedAt.Add(500 * time.Millisecond)), false},
+	}
+
+	userID := uuid.New()
+	if err := RevokeUserTokens(context.Background(), userID, revokedAt); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsRevokedForUser(context.Background(), userID, tt.issuedAt)
+			if err != nil {
+				t.Fatalf("IsRevokedForUser: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("revoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRevokedForUser_WatermarkInMilliseconds(t *testing.T) {
+	initTokens(t)
+	userID := uuid.New()
+	revokedAt := time.Now()
+
+	if err := RevokeUserTokens(context.Background(), userID, revokedAt); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	value, err := redisclient.Get(context.Background(), redisUserRevokedPrefix+userID.String())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if want := strconv.FormatInt(revokedAt.UnixMilli(), 10); value != want {
+		t.Errorf("watermark = %s, want %s", value, want)
+	}
+}
+
+func TestIsRevokedForUser_NoWatermark(t *testing.T) {
+	initTokens(t)
+
+	got, err := IsRevokedForUser(context.Background(), uuid.New(), jwt.NewNumericDate(time.Now().Add(-time.Hour)))
+	if err != nil {
+		t.Fatalf("IsRevokedForUser: %v", err)
+	}
+	if got {
+		t.Error("revoked without a watermark")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	redisclient "github.com/xinyi-chong/common-lib/redis"
 	"go.uber.org/zap"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -24,11 +25,22 @@ var (
 	configOnce    sync.Once
 	accessExpiry  = time.Hour
 	refreshExpiry = 30 * 24 * time.Hour
+	issuer        string // iss of tokens issued to OAuth clients
 )
 
+// Token types, carried in the typ claim so one kind of token cannot be used as another
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var ErrWrongTokenType = errors.New("wrong token type")
+
 type (
 	RefreshTokenClaims struct {
-		UserID uuid.UUID `json:"user_id"`
+		UserID   uuid.UUID `json:"user_id"`
+		Type     string    `json:"typ"`
+		FamilyID string    `json:"fid"`
 		jwt.RegisteredClaims
 	}
 
@@ -36,8 +48,22 @@ type (
 		UserID   uuid.UUID `json:"user_id"`
 		Username *string   `json:"username"`
 		Email    *string   `json:"email"`
+		Type     string    `json:"typ"`
+		FamilyID string    `json:"fid,omitempty"`
+		// Absent unless authorization is embedded
+		Authz *Authorization `json:"authz,omitempty"`
 		jwt.RegisteredClaims
 	}
+
+	// Authorization is what the user may do, so other services can decide
+	// without calling back. PermissionsOmitted is set instead of Permissions
+	// when the user has too many to embed; they are then served by
+	// /api/v1/auth/permissions.
+	Authorization struct {
+		Roles              []string `json:"roles"`
+		Permissions        []string `json:"permissions,omitempty"`
+		PermissionsOmitted bool     `json:"permissions_omitted,omitempty"`
+	}
 )
 
 func Init(cfg *config.Config) error {
@@ -55,22 +81,67 @@ func Init(cfg *config.Config) error {
 			logger.Warn("Invalid refresh duration, using default : ", zap.Duration("refreshExpiry", refreshExpiry))
 		}
 
+		if cfg.MFA.TokenDuration > 0 {
+			mfaExpiry = cfg.MFA.TokenDuration
+		}
+
+		if cfg.JWT.KeySyncInterval > 0 {
+			// One missed sync still leaves an instance time to learn the key
+			keyActivationDelay = 2 * cfg.JWT.KeySyncInterval
+		}
+
+		issuer = strings.TrimSuffix(cfg.OAuth.Issuer, "/")
+
+		// Whole seconds would revoke the tokens issued in the same second as
+		// a password change or a logout everywhere, including the new ones.
+		jwt.TimePrecision = time.Millisecond
+
 		key := os.Getenv("JWT_SECRET_KEY")
 		if key == "" {
 			err = errors.New("JWT secret key is not set in environment variables")
 			return
 		}
 		secretKey = []byte(key)
+
+		ring.static, err = loadSigningKey(cfg)
+		if err != nil {
+			return
+		}
+		ring.replace(nil)
 	})
 	return err
 }
 
-func GenerateAccessToken(userID uuid.UUID, username, email *string) (string, error) {
+func loadSigningKey(cfg *config.Config) (*signingKey, error) {
+	alg := cfg.JWT.Algorithm
+	if alg == "" {
+		alg = AlgHS256
+	}
+	rotationAlgorithm = alg
+
+	if alg == AlgHS256 {
+		return newHMACKey(cfg.JWT.KeyID, secretKey), nil
+	}
+
+	if cfg.JWT.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("private key path is required for %s", alg)
+	}
+	return loadPrivateKeyFile(alg, cfg.JWT.PrivateKeyPath, cfg.JWT.KeyID)
+}
+
+// GenerateAccessToken issues an access token. familyID links it to the refresh
+// token family it was issued with, so revoking the family revokes it too.
+// authz is nil when authorization is not embedded.
+func GenerateAccessToken(userID uuid.UUID, username, email *string, familyID string, authz *Authorization) (string, error) {
 	accessClaims := AccessTokenClaims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
+		Type:     TypeAccess,
+		FamilyID: familyID,
+		Authz:    authz,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessExpiry)),
 		},
@@ -78,10 +149,13 @@ func GenerateAccessToken(userID uuid.UUID, username, email *string) (string, err
 	return generateToken(accessClaims)
 }
 
-func GenerateRefreshToken(userID uuid.UUID) (string, error) {
+func generateRefreshToken(userID uuid.UUID, familyID, jti string) (string, error) {
 	refreshClaims := RefreshTokenClaims{
-		UserID: userID,
+		UserID:   userID,
+		Type:     TypeRefresh,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(refreshExpiry)),
 		},
@@ -99,6 +173,9 @@ func ParseAccessToken(accessToken string) (*AccessTokenClaims, error) {
 	if !ok || !parsedAccessToken.Valid {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
+	if claims.Type != TypeAccess {
+		return nil, ErrWrongTokenType
+	}
 	return claims, nil
 }
 
@@ -112,6 +189,9 @@ func ParseRefreshToken(refreshToken string) (*RefreshTokenClaims, error) {
 	if !ok || !parsedRefreshToken.Valid {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
+	if claims.Type != TypeRefresh || claims.FamilyID == "" || claims.ID == "" {
+		return nil, ErrWrongTokenType
+	}
 	return claims, nil
 }
 
@@ -153,15 +233,10 @@ func IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
 }
 
 func generateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
-}
-
-func keyFunc(token *jwt.Token) (interface{}, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-	}
-	return secretKey, nil
+	key := ring.signing()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.signKey)
 }
 
 func secureHash(data string) string {
@@ -169,3 +244,13 @@ func secureHash(data string) string {
 	h.Write([]byte(data))
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+// SigningAlgorithm is the algorithm new tokens are signed with.
+func SigningAlgorithm() string {
+	return ring.signing().method.Alg()
+}
+
+// RefreshExpiry is the lifetime of refresh tokens, and so of a login session.
+func RefreshExpiry() time.Duration {
+	return refreshExpiry
+}
@@ -0,0 +1,97 @@
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAccessToken_Authorization(t *testing.T) {
+	initTokens(t)
+	authz := &Authorization{Roles: []string{"support"}, Permissions: []string{"users:read"}}
+
+	accessToken, err := GenerateAccessToken(uuid.New(), nil, nil, "", authz)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	claims, err := ParseAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if !reflect.DeepEqual(claims.Authz, authz) {
+		t.Errorf("Authz = %+v, want %+v", claims.Authz, authz)
+	}
+}
+
+func TestAccessToken_PermissionsOmitted(t *testing.T) {
+	initTokens(t)
+
+	accessToken, err := GenerateAccessToken(uuid.New(), nil, nil, "", &Authorization{Roles: []string{"auditor"}, PermissionsOmitted: true})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	claims, err := ParseAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if claims.Authz == nil || !claims.Authz.PermissionsOmitted || claims.Authz.Permissions != nil {
+		t.Errorf("Authz = %+v, want permissions omitted", claims.Authz)
+	}
+}
+
+func TestAccessToken_WithoutAuthorization(t *testing.T) {
+	initTokens(t)
+
+	accessToken, err := GenerateAccessToken(uuid.New(), nil, nil, "", nil)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	claims, err := ParseAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if claims.Authz != nil {
+		t.Errorf("Authz = %+v, want none", claims.Authz)
+	}
+}
+
+func TestParseAccessToken_RejectsTampering(t *testing.T) {
+	initTokens(t)
+
+	accessToken, err := GenerateAccessToken(uuid.New(), nil, nil, "", &Authorization{Roles: []string{"user"}, Permissions: []string{}})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	// Swap in a payload that grants everything, keeping the signature
+	claims, err := ParseAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	claims.Authz = &Authorization{Roles: []string{"admin"}, Permissions: []string{"*"}}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	parts := strings.Split(accessToken, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	if _, err := ParseAccessToken(strings.Join(parts, ".")); err == nil {
+		t.Error("token with a forged payload accepted")
+	}
+}
+
+func TestParseAccessToken_RejectsRefreshToken(t *testing.T) {
+	initTokens(t)
+
+	refreshToken, err := generateRefreshToken(uuid.New(), uuid.NewString(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("generateRefreshToken: %v", err)
+	}
+	if _, err := ParseAccessToken(refreshToken); err == nil {
+		t.Error("refresh token accepted as an access token")
+	}
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import "context"
+
+// Message is a single email. From defaults to the sender configured on the
+// mailer.
+type Message struct {
+	From     string
+	To       []string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type outboxMailer struct {
+	dir  string
+	from string
+}
+
+// NewOutboxMailer returns a mailer that writes every message to dir as an
+// RFC 5322 .eml file instead of sending it, for local development and tests.
+func NewOutboxMailer(dir, from string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &outboxMailer{dir: dir, from: from}, nil
+}
+
+func (m *outboxMailer) Send(_ context.Context, msg *Message) error {
+	if _, _, err := msg.envelope(m.from); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data, err := msg.encode(m.from, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := now.UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	// Write to a temporary name first so readers never see a partial message
+	tmp := filepath.Join(m.dir, "."+name)
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.dir, name))
+}
@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+const defaultSMTPTimeout = 30 * time.Second
+
+// ErrSMTPTLSRequired means the server offers no STARTTLS, so the credentials
+// would cross the network in clear text.
+var ErrSMTPTLSRequired = errors.New("smtp: server does not offer STARTTLS, refusing to authenticate without TLS")
+
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// ImplicitTLS connects over TLS from the start (usually port 465).
+	// Otherwise STARTTLS is used whenever the server offers it, and is
+	// required to authenticate.
+	ImplicitTLS bool `mapstructure:"implicit_tls"`
+}
+
+type smtpMailer struct {
+	cfg     SMTPConfig
+	from    string
+	rootCAs *x509.CertPool // nil for the system roots
+}
+
+// NewSMTPMailer returns a mailer that delivers through an SMTP server.
+func NewSMTPMailer(cfg SMTPConfig, from string) Mailer {
+	return &smtpMailer{cfg: cfg, from: from}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	sender, recipients, err := msg.envelope(m.from)
+	if err != nil {
+		return err
+	}
+
+	data, err := msg.encode(m.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultSMTPTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	encrypted := m.cfg.ImplicitTLS
+	if !encrypted {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(m.tlsConfig()); err != nil {
+				return err
+			}
+			encrypted = true
+		}
+	}
+
+	if m.cfg.Username != "" {
+		// PlainAuth would still send the password in clear to localhost,
+		// which may be a relay forwarding it anywhere
+		if !encrypted {
+			return ErrSMTPTLSRequired
+		}
+		auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(sender); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (m *smtpMailer) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	dialer := &net.Dialer{Timeout: defaultSMTPTimeout}
+
+	if m.cfg.ImplicitTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: m.tlsConfig()}
+		return tlsDialer.DialContext(ctx, "tcp", addr)
+	}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+func (m *smtpMailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.cfg.Host, RootCAs: m.rootCAs, MinVersion: tls.VersionTLS12}
+}
@@ -0,0 +1,294 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer speaks just enough SMTP for net/smtp: EHLO, STARTTLS,
+// AUTH PLAIN, MAIL, RCPT, DATA and QUIT.
+type fakeSMTPServer struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	offerTLS    bool // advertise STARTTLS
+
+	mu         sync.Mutex
+	auths      []string // decoded AUTH PLAIN credentials
+	authInTLS  []bool
+	recipients []string
+	data       string
+}
+
+func newFakeSMTPServer(t *testing.T, implicitTLS, offerTLS bool) (*fakeSMTPServer, *x509.CertPool) {
+	t.Helper()
+	cert, pool := selfSignedCert(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &fakeSMTPServer{
+		listener:    listener,
+		tlsConfig:   &tls.Config{Certificates: []tls.Certificate{cert}},
+		implicitTLS: implicitTLS,
+		offerTLS:    offerTLS,
+	}
+	if implicitTLS {
+		srv.listener = tls.NewListener(listener, srv.tlsConfig)
+	}
+	t.Cleanup(func() { srv.listener.Close() })
+
+	go srv.serve()
+	return srv, pool
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	encrypted := s.implicitTLS
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if s.offerTLS && !encrypted {
+				reply("250-fake")
+				reply("250-STARTTLS")
+				reply("250 AUTH PLAIN")
+			} else {
+				reply("250-fake")
+				reply("250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 go ahead")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, encrypted = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			s.mu.Lock()
+			s.auths = append(s.auths, string(decoded))
+			s.authInTLS = append(s.authInTLS, encrypted)
+			s.mu.Unlock()
+			reply("235 authenticated")
+		case "MAIL":
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.recipients = append(s.recipients, arg)
+			s.mu.Unlock()
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var body strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				body.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.data = body.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func testMessage() *Message {
+	return &Message{
+		To:       []string{"user@example.com"},
+		Subject:  "Hello",
+		TextBody: "Hello there",
+	}
+}
+
+func newTestSMTPMailer(srv *fakeSMTPServer, pool *x509.CertPool, username string) *smtpMailer {
+	return &smtpMailer{
+		cfg: SMTPConfig{
+			Host:        "127.0.0.1",
+			Port:        srv.port(),
+			Username:    username,
+			Password:    "secret",
+			ImplicitTLS: srv.implicitTLS,
+		},
+		from:    "Auth Service <no-reply@example.com>",
+		rootCAs: pool,
+	}
+}
+
+func sendTestMessage(t *testing.T, m *smtpMailer) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.Send(ctx, testMessage())
+}
+
+func TestSMTPMailer_RefusesAuthWithoutTLS(t *testing.T) {
+	srv, pool := newFakeSMTPServer(t, false, false)
+
+	err := sendTestMessage(t, newTestSMTPMailer(srv, pool, "mailer"))
+	if !errors.Is(err, ErrSMTPTLSRequired) {
+		t.Fatalf("err = %v, want ErrSMTPTLSRequired", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.auths) != 0 {
+		t.Errorf("credentials sent in clear text: %q", srv.auths)
+	}
+	if srv.data != "" {
+		t.Error("message sent after refusing to authenticate")
+	}
+}
+
+func TestSMTPMailer_AuthAfterSTARTTLS(t *testing.T) {
+	srv, pool := newFakeSMTPServer(t, false, true)
+
+	if err := sendTestMessage(t, newTestSMTPMailer(srv, pool, "mailer")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.auths) != 1 || !srv.authInTLS[0] {
+		t.Fatalf("auths = %q, in TLS = %v, want one over TLS", srv.auths, srv.authInTLS)
+	}
+	if srv.auths[0] != "\x00mailer\x00secret" {
+		t.Errorf("credentials = %q", srv.auths[0])
+	}
+	if len(srv.recipients) != 1 || !strings.Contains(srv.recipients[0], "user@example.com") {
+		t.Errorf("recipients = %q", srv.recipients)
+	}
+	if !strings.Contains(srv.data, "Subject: Hello") {
+		t.Errorf("message not delivered: %q", srv.data)
+	}
+}
+
+func TestSMTPMailer_AuthOverImplicitTLS(t *testing.T) {
+	srv, pool := newFakeSMTPServer(t, true, false)
+
+	if err := sendTestMessage(t, newTestSMTPMailer(srv, pool, "mailer")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.auths) != 1 || !srv.authInTLS[0] {
+		t.Errorf("auths = %q, in TLS = %v, want one over TLS", srv.auths, srv.authInTLS)
+	}
+}
+
+func TestSMTPMailer_UntrustedCertificate(t *testing.T) {
+	srv, _ := newFakeSMTPServer(t, false, true)
+
+	// The system roots do not know the test certificate
+	if err := sendTestMessage(t, newTestSMTPMailer(srv, nil, "mailer")); err == nil {
+		t.Fatal("Send succeeded with an untrusted certificate")
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.auths) != 0 {
+		t.Errorf("credentials sent after a failed handshake: %q", srv.auths)
+	}
+}
+
+func TestSMTPMailer_NoAuthWithoutTLS(t *testing.T) {
+	srv, pool := newFakeSMTPServer(t, false, false)
+
+	// Without credentials there is nothing to protect
+	if err := sendTestMessage(t, newTestSMTPMailer(srv, pool, "")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.auths) != 0 {
+		t.Errorf("auths = %q, want none", srv.auths)
+	}
+	if srv.data == "" {
+		t.Error("message not delivered")
+	}
+}
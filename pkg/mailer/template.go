@@ -0,0 +1,150 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// Template names
+const (
+	TemplateEmailVerification = "email_verification"
+	TemplatePasswordReset     = "password_reset"
+)
+
+// Every template file defines a "subject", "text" and "html" block. Files
+// live in templates/<language>/<name>.tmpl.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var defaultLanguage = language.English
+
+type localizedTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var (
+	templates     map[language.Tag]map[string]*localizedTemplate
+	matcher       language.Matcher
+	supported     []language.Tag
+	templatesErr  error
+	templatesOnce sync.Once
+)
+
+func loadTemplates() {
+	templates = make(map[language.Tag]map[string]*localizedTemplate)
+	// The default language goes first so the matcher falls back to it
+	supported = []language.Tag{defaultLanguage}
+
+	dirs, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		templatesErr = err
+		return
+	}
+
+	for _, dir := range dirs {
+		tag, err := language.Parse(dir.Name())
+		if err != nil {
+			templatesErr = fmt.Errorf("template language %q: %w", dir.Name(), err)
+			return
+		}
+
+		files, err := fs.Glob(templateFS, path.Join("templates", dir.Name(), "*.tmpl"))
+		if err != nil {
+			templatesErr = err
+			return
+		}
+
+		templates[tag] = make(map[string]*localizedTemplate, len(files))
+		for _, file := range files {
+			text, err := texttemplate.ParseFS(templateFS, file)
+			if err != nil {
+				templatesErr = err
+				return
+			}
+			html, err := htmltemplate.ParseFS(templateFS, file)
+			if err != nil {
+				templatesErr = err
+				return
+			}
+			name := strings.TrimSuffix(path.Base(file), ".tmpl")
+			templates[tag][name] = &localizedTemplate{text: text, html: html}
+		}
+
+		if tag != defaultLanguage {
+			supported = append(supported, tag)
+		}
+	}
+
+	if _, ok := templates[defaultLanguage]; !ok {
+		templatesErr = fmt.Errorf("no templates for default language %s", defaultLanguage)
+		return
+	}
+	matcher = language.NewMatcher(supported)
+}
+
+// Render fills the named template in the language that best matches langs.
+// langs are ?lang= values or Accept-Language headers, the same inputs
+// middleware.LocaleMiddleware picks the response language from. Recipients
+// are left for the caller to set.
+func Render(name string, data any, langs ...string) (*Message, error) {
+	templatesOnce.Do(loadTemplates)
+	if templatesErr != nil {
+		return nil, templatesErr
+	}
+
+	tmpl, ok := templates[matchLanguage(langs)][name]
+	if !ok {
+		tmpl, ok = templates[defaultLanguage][name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: text.String(),
+		HTMLBody: html.String(),
+	}, nil
+}
+
+func matchLanguage(langs []string) language.Tag {
+	var tags []language.Tag
+	for _, lang := range langs {
+		parsed, _, err := language.ParseAcceptLanguage(lang)
+		if err == nil {
+			tags = append(tags, parsed...)
+		}
+	}
+
+	if len(tags) == 0 {
+		return defaultLanguage
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return defaultLanguage
+	}
+	return supported[index]
+}
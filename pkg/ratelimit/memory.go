@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often keys that went quiet are dropped. Keys are
+// otherwise only cleaned up when they are used again, and most never are.
+const sweepInterval = time.Minute
+
+type memoryLimiter struct {
+	mu        sync.Mutex
+	requests  map[string]*window
+	nextSweep time.Time
+}
+
+type window struct {
+	hits     []time.Time
+	duration time.Duration
+}
+
+// NewMemoryLimiter returns a sliding window limiter local to the process, for
+// tests and single instance deployments without Redis.
+func NewMemoryLimiter() Limiter {
+	return &memoryLimiter{requests: map[string]*window{}}
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, limit int, duration time.Duration) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w := l.requests[key]
+	if w == nil {
+		w = &window{}
+	}
+	w.duration = duration
+
+	cutoff := now.Add(-duration)
+	kept := w.hits[:0]
+	for _, at := range w.hits {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+
+	allowed := len(kept) < limit
+	if allowed {
+		kept = append(kept, now)
+	}
+
+	w.hits = kept
+	if len(kept) == 0 {
+		delete(l.requests, key)
+	} else {
+		l.requests[key] = w
+	}
+
+	result := &Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(limit-len(kept), 0),
+	}
+	if len(kept) > 0 {
+		result.ResetAfter = kept[0].Add(duration).Sub(now)
+	}
+	return result, nil
+}
+
+// sweep drops the keys whose newest hit has left their window.
+func (l *memoryLimiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	l.nextSweep = now.Add(sweepInterval)
+
+	for key, w := range l.requests {
+		if !w.hits[len(w.hits)-1].After(now.Add(-w.duration)) {
+			delete(l.requests, key)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func newTestRedisLimiter(t *testing.T) Limiter {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiter(client)
+}
+
+// forEachLimiter runs the test against every Limiter implementation, which
+// must behave the same.
+func forEachLimiter(t *testing.T, test func(t *testing.T, l Limiter)) {
+	t.Run("memory", func(t *testing.T) { test(t, NewMemoryLimiter()) })
+	t.Run("redis", func(t *testing.T) { test(t, newTestRedisLimiter(t)) })
+}
+
+func allow(t *testing.T, l Limiter, key string, limit int, window time.Duration) *Result {
+	t.Helper()
+	result, err := l.Allow(context.Background(), key, limit, window)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	return result
+}
+
+func TestAllow_Limit(t *testing.T) {
+	forEachLimiter(t, func(t *testing.T, l Limiter) {
+		for i := 1; i <= 3; i++ {
+			result := allow(t, l, "key", 3, time.Minute)
+			if !result.Allowed || result.Remaining != 3-i || result.Limit != 3 {
+				t.Fatalf("request %d: %+v", i, result)
+			}
+		}
+
+		result := allow(t, l, "key", 3, time.Minute)
+		if result.Allowed || result.Remaining != 0 {
+			t.Errorf("request over the limit: %+v", result)
+		}
+		if result.ResetAfter <= 0 || result.ResetAfter > time.Minute {
+			t.Errorf("ResetAfter = %v, want within the window", result.ResetAfter)
+		}
+	})
+}
+
+func TestAllow_KeysAreIndependent(t *testing.T) {
+	forEachLimiter(t, func(t *testing.T, l Limiter) {
+		allow(t, l, "a", 1, time.Minute)
+		if result := allow(t, l, "b", 1, time.Minute); !result.Allowed {
+			t.Errorf("key b limited by key a: %+v", result)
+		}
+	})
+}
+
+func TestAllow_WindowSlides(t *testing.T) {
+	forEachLimiter(t, func(t *testing.T, l Limiter) {
+		const window = 100 * time.Millisecond
+		allow(t, l, "key", 2, window)
+		time.Sleep(window / 2)
+		allow(t, l, "key", 2, window)
+
+		if result := allow(t, l, "key", 2, window); result.Allowed {
+			t.Fatalf("request over the limit allowed: %+v", result)
+		}
+
+		// Only the first request has left the window
+		time.Sleep(window/2 + 10*time.Millisecond)
+		if result := allow(t, l, "key", 2, window); !result.Allowed || result.Remaining != 0 {
+			t.Errorf("after the first request expired: %+v", result)
+		}
+		if result := allow(t, l, "key", 2, window); result.Allowed {
+			t.Errorf("second request left the window early: %+v", result)
+		}
+	})
+}
+
+func TestAllow_RejectedRequestsDoNotCount(t *testing.T) {
+	forEachLimiter(t, func(t *testing.T, l Limiter) {
+		const window = 100 * time.Millisecond
+		allow(t, l, "key", 1, window)
+		for range 5 {
+			allow(t, l, "key", 1, window)
+		}
+
+		time.Sleep(window + 10*time.Millisecond)
+		if result := allow(t, l, "key", 1, window); !result.Allowed {
+			t.Errorf("still limited after the window: %+v", result)
+		}
+	})
+}
+
+func TestMemoryLimiter_SweepsQuietKeys(t *testing.T) {
+	l := NewMemoryLimiter().(*memoryLimiter)
+	const window = 50 * time.Millisecond
+
+	allow(t, l, "quiet", 5, window)
+	allow(t, l, "busy", 5, time.Hour)
+	time.Sleep(window + 10*time.Millisecond)
+
+	// Force the next call to sweep
+	l.mu.Lock()
+	l.nextSweep = time.Time{}
+	l.mu.Unlock()
+	allow(t, l, "other", 5, time.Hour)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.requests["quiet"]; ok {
+		t.Error("quiet key kept after its window")
+	}
+	if _, ok := l.requests["busy"]; !ok {
+		t.Error("key within its window swept")
+	}
+	if !l.nextSweep.After(time.Now()) {
+		t.Error("next sweep not scheduled")
+	}
+}
+
+func TestMemoryLimiter_SweepIsThrottled(t *testing.T) {
+	l := NewMemoryLimiter().(*memoryLimiter)
+	const window = 50 * time.Millisecond
+
+	allow(t, l, "first", 5, time.Hour) // schedules the next sweep
+	allow(t, l, "quiet", 5, window)
+	time.Sleep(window + 10*time.Millisecond)
+	allow(t, l, "other", 5, time.Hour)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.requests["quiet"]; !ok {
+		t.Error("swept before the sweep interval elapsed")
+	}
+}
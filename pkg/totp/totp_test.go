@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B, truncated to six digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+}
+
+func TestCode_RFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if code != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestCode_LowercaseSecret(t *testing.T) {
+	code, err := Code(strings.ToLower(rfcSecret), Step(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Errorf("Code = %q, %v, want 287082", code, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := Step(at)
+
+	got, ok := Validate(rfcSecret, "050471", at)
+	if !ok || got != step {
+		t.Fatalf("Validate = %d, %v, want %d", got, ok, step)
+	}
+
+	// The matched step is returned so callers can refuse it next time
+	previous, _ := Code(rfcSecret, step-1)
+	if got, ok := Validate(rfcSecret, previous, at); !ok || got != step-1 {
+		t.Errorf("previous step: Validate = %d, %v, want %d", got, ok, step-1)
+	}
+	next, _ := Code(rfcSecret, step+1)
+	if got, ok := Validate(rfcSecret, next, at); !ok || got != step+1 {
+		t.Errorf("next step: Validate = %d, %v, want %d", got, ok, step+1)
+	}
+}
+
+func TestValidate_Rejects(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := Step(at)
+	outsideSkew, _ := Code(rfcSecret, step-2)
+
+	for name, code := range map[string]string{
+		"outside skew": outsideSkew,
+		"wrong code":   "000000",
+		"too short":    "05047",
+		"too long":     "0504710",
+		"empty":        "",
+	} {
+		if _, ok := Validate(rfcSecret, code, at); ok {
+			t.Errorf("%s: code %q accepted", name, code)
+		}
+	}
+
+	if _, ok := Validate("not base32!", "050471", at); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	first, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	second, _ := GenerateSecret()
+	if first == second {
+		t.Error("secrets repeat")
+	}
+	if key, err := encoding.DecodeString(first); err != nil || len(key) != secretBytes {
+		t.Errorf("secret decodes to %d bytes, %v, want %d", len(key), err, secretBytes)
+	}
+}